@@ -3,13 +3,22 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os" // Added for os.MkdirAll
 	"time"
 
 	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/backup"
 	"diet-fitness-backend/internal/db" // Will now import sqlite.go functions
+	"diet-fitness-backend/internal/jobs"
+	"diet-fitness-backend/internal/logging"
+	"diet-fitness-backend/internal/mail"
+	"diet-fitness-backend/internal/middleware"
+	"diet-fitness-backend/internal/oidc"
+	"diet-fitness-backend/internal/password"
+	"diet-fitness-backend/internal/store"
 	"diet-fitness-backend/routes"
 
 	"github.com/gorilla/mux"
@@ -19,32 +28,91 @@ func main() {
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		log.Fatalf("Error loading configuration: %v", err)
+		fatal("Error loading configuration", err)
 	}
 
-	// Initialize database connection (now uses SQLite)
+	// Structured logging at LOG_LEVEL in LOG_FORMAT, with request IDs and sensitive values redacted
+	if err := logging.Setup(cfg); err != nil {
+		fatal("Error configuring logging", err)
+	}
+
+	// Initialize database connection (SQLite, or PostgreSQL when DATABASE_URL is set)
 	database, err := db.InitDB(cfg) // This calls InitDB from internal/db/sqlite.go
 	if err != nil {
-		log.Fatalf("Error connecting to database: %v", err)
+		fatal("Error connecting to database", err)
 	}
 	defer database.Close()
 	// Success message is handled within db.InitDB
 
+	// Plain reads get their own read-only pool with SQLite, so they don't queue behind writes
+	readPool, err := db.OpenReadPool(cfg)
+	if err != nil {
+		fatal("Error opening database read pool", err)
+	}
+	if readPool != nil {
+		defer readPool.Close()
+	}
+
+	// Repositories for users, plans, workouts and uploads
+	var st store.Store = store.NewSQLite(database).ReadFrom(readPool)
+	if db.DialectOf(cfg) == db.Postgres {
+		st = store.NewPostgres(database)
+	}
+
 	// Create uploads directory if it doesn't exist
 	if err := os.MkdirAll(cfg.UploadDir, 0755); err != nil {
-		log.Fatalf("Failed to create upload directory: %v", err)
+		fatal("Failed to create upload directory", err)
+	}
+	slog.Info("Upload directory created or verified", "dir", cfg.UploadDir)
+
+	// Start the background job workers (health data imports, exports)
+	runner, err := jobs.NewRunner(database, cfg.JobWorkers)
+	if err != nil {
+		fatal("Error starting job runner", err)
+	}
+	defer runner.Shutdown()
+
+	// Outgoing mail (password resets, email verification)
+	mailer, err := mail.New(cfg)
+	if err != nil {
+		fatal("Error configuring mail", err)
 	}
-	fmt.Printf("Upload directory created/verified at: %s\n", cfg.UploadDir)
+
+	// JWT signing keys; rotated on schedule and published at /.well-known/jwks.json
+	keys, err := auth.NewKeyManager(database, cfg.JWTAlgorithm, cfg.JWTKeyRotation, cfg.JWTSecret, cfg.JWTAcceptHS256)
+	if err != nil {
+		fatal("Error loading JWT signing keys", err)
+	}
+	defer keys.Close()
+
+	// OpenID Connect sign-in providers
+	providers, err := oidc.FromConfig(cfg)
+	if err != nil {
+		fatal("Error configuring sign-in providers", err)
+	}
+
+	// Password policy, including the local breached password corpus if one is installed
+	policy, err := password.NewPolicy(cfg)
+	if err != nil {
+		fatal("Error configuring password policy", err)
+	}
+
+	// Online database backups, on schedule and on request
+	backups, err := backup.NewManager(database, cfg)
+	if err != nil {
+		fatal("Error configuring database backups", err)
+	}
+	defer backups.Close()
 
 	// Initialize router
 	r := mux.NewRouter()
 
 	// Register API routes
-	routes.RegisterAPIRoutes(r, database, cfg) // Pass db and cfg to routes
+	routes.RegisterAPIRoutes(r, database, st, cfg, runner, mailer, keys, providers, policy, backups) // Pass db, the store, cfg, the job runner, mailer, signing keys, sign-in providers, password policy and backups to routes
 
 	// Set up HTTP server
 	srv := &http.Server{
-		Handler: r,
+		Handler: middleware.RequestID(middleware.AccessLog(cfg.TrustProxyHeaders)(r)), // Every request gets an ID and an access log line
 		Addr:    fmt.Sprintf(":%s", cfg.ServerPort),
 		// Good practice: Set timeouts to avoid Slowloris attacks and keepalives
 		WriteTimeout: 15 * time.Second,
@@ -52,6 +120,12 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
-	fmt.Printf("Server starting on port %s...\n", cfg.ServerPort)
-	log.Fatal(srv.ListenAndServe())
+	slog.Info("Server starting", "port", cfg.ServerPort)
+	fatal("Server stopped", srv.ListenAndServe())
+}
+
+// fatal logs err and exits. Deferred cleanups don't run, as with log.Fatal.
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
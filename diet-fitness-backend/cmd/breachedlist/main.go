@@ -0,0 +1,135 @@
+// --- diet-fitness-backend/cmd/breachedlist/main.go ---
+// Command breachedlist builds the local breached password corpus checked when users choose a password.
+// The input is either the Have I Been Pwned "Pwned Passwords" SHA-1 download ("HASH:COUNT" lines) or a
+// plain list of passwords, one per line; the output is one file per 5-character hash prefix.
+//
+//	go run ./cmd/breachedlist -in pwned-passwords-sha1-ordered-by-hash.txt -out ./data/breached
+//	go run ./cmd/breachedlist -common -out ./data/breached   # only the built-in common passwords
+//
+// Running it again adds to the existing corpus.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"diet-fitness-backend/internal/password"
+)
+
+// flushEvery bounds how many hashes are held in memory before being appended to the prefix files
+const flushEvery = 1_000_000
+
+var sha1Line = regexp.MustCompile(`^[0-9A-Fa-f]{40}(:\d+)?$`)
+
+type builder struct {
+	dir     string
+	pending map[string][]string // Prefix to "SUFFIX:COUNT" lines
+	held    int
+	total   int
+}
+
+func main() {
+	in := flag.String("in", "", "input file of HASH:COUNT lines or plain passwords; - reads standard input")
+	out := flag.String("out", "./data/breached", "corpus directory (BREACHED_PASSWORDS_DIR)")
+	common := flag.Bool("common", false, "also add the common passwords built into the strength estimator")
+	flag.Parse()
+	if *in == "" && !*common {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatalf("Error creating %s: %v", *out, err)
+	}
+	b := &builder{dir: *out, pending: make(map[string][]string)}
+
+	if *common {
+		for _, pw := range password.CommonPasswords() {
+			b.addPassword(pw)
+		}
+	}
+	if *in != "" {
+		var r io.Reader = os.Stdin
+		if *in != "-" {
+			f, err := os.Open(*in)
+			if err != nil {
+				log.Fatalf("Error opening %s: %v", *in, err)
+			}
+			defer f.Close()
+			r = f
+		}
+		if err := b.read(r); err != nil {
+			log.Fatalf("Error reading %s: %v", *in, err)
+		}
+	}
+	if err := b.flush(); err != nil {
+		log.Fatalf("Error writing corpus: %v", err)
+	}
+	fmt.Printf("Added %d hashes to %s\n", b.total, *out)
+}
+
+// read adds each line, telling hashes from plain passwords by their shape
+func (b *builder) read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		if sha1Line.MatchString(line) {
+			hash, count, _ := strings.Cut(line, ":")
+			hash = strings.ToUpper(hash)
+			if count == "" {
+				count = "1"
+			}
+			b.add(hash[:password.PrefixLength], hash[password.PrefixLength:], count)
+		} else {
+			b.addPassword(line)
+		}
+		if b.held >= flushEvery {
+			if err := b.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+func (b *builder) addPassword(pw string) {
+	prefix, suffix := password.Hash(pw)
+	b.add(prefix, suffix, "1")
+}
+
+func (b *builder) add(prefix, suffix, count string) {
+	b.pending[prefix] = append(b.pending[prefix], suffix+":"+count)
+	b.held++
+	b.total++
+}
+
+// flush appends the held hashes to their prefix files, sorted within each batch
+func (b *builder) flush() error {
+	for prefix, lines := range b.pending {
+		sort.Strings(lines)
+		f, err := os.OpenFile(password.PrefixFile(b.dir, prefix), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		_, err = f.WriteString(strings.Join(lines, "\n") + "\n")
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	b.pending = make(map[string][]string)
+	b.held = 0
+	return nil
+}
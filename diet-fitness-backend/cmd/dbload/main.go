@@ -0,0 +1,189 @@
+// --- diet-fitness-backend/cmd/dbload/main.go ---
+// Command dbload puts concurrent load on a scratch database through the store, the way the API does when
+// many users log workouts and open their plans at once, and reports throughput, latency and errors.
+// The connection settings come from the environment and .env like the server's, so the effect of a
+// setting can be measured by overriding it:
+//
+//	go run ./cmd/dbload -duration 20s -writers 16 -readers 64
+//	SQLITE_JOURNAL_MODE=DELETE SQLITE_BUSY_TIMEOUT=0 DB_READ_CONNS=0 DB_MAX_OPEN_CONNS=0 go run ./cmd/dbload
+//
+// The exit status is 1 if any operation failed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/db"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+)
+
+// stats collects the latencies and errors of one kind of operation
+type stats struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+	locked    int // Errors that were "database is locked" or "database table is locked"
+	lastError error
+}
+
+func (s *stats) record(started time.Time, err error) {
+	elapsed := time.Since(started)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		s.errors++
+		s.lastError = err
+		if strings.Contains(err.Error(), "is locked") {
+			s.locked++
+		}
+		return
+	}
+	s.latencies = append(s.latencies, elapsed)
+}
+
+func (s *stats) report(name string, elapsed time.Duration) {
+	sort.Slice(s.latencies, func(i, j int) bool { return s.latencies[i] < s.latencies[j] })
+	percentile := func(p float64) time.Duration {
+		if len(s.latencies) == 0 {
+			return 0
+		}
+		return s.latencies[int(p*float64(len(s.latencies)-1))]
+	}
+	fmt.Printf("%-7s %7d ok %8.1f/s  p50 %-9s p95 %-9s p99 %-9s max %-9s errors %d (locked %d)\n",
+		name, len(s.latencies), float64(len(s.latencies))/elapsed.Seconds(),
+		percentile(0.50).Round(time.Microsecond), percentile(0.95).Round(time.Microsecond),
+		percentile(0.99).Round(time.Microsecond), percentile(1).Round(time.Microsecond), s.errors, s.locked)
+	if s.lastError != nil {
+		fmt.Printf("        last error: %v\n", s.lastError)
+	}
+}
+
+func main() {
+	if !run() {
+		os.Exit(1)
+	}
+}
+
+// run applies the load and reports whether every operation succeeded
+func run() bool {
+	duration := flag.Duration("duration", 10*time.Second, "how long to run")
+	writers := flag.Int("writers", 8, "goroutines logging workouts")
+	readers := flag.Int("readers", 32, "goroutines loading plans and recent workouts")
+	users := flag.Int("users", 50, "accounts the load is spread over")
+	path := flag.String("db", "", "SQLite file to use; defaults to a fresh one in a temporary directory")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if *path == "" {
+		dir, err := os.MkdirTemp("", "dbload")
+		if err != nil {
+			log.Fatalf("Error creating scratch directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		*path = filepath.Join(dir, "load.db")
+	}
+	cfg.SQLiteDBPath, cfg.DatabaseURL = *path, ""
+
+	database, err := db.InitDB(cfg)
+	if err != nil {
+		log.Fatalf("Error opening database: %v", err)
+	}
+	defer database.Close()
+	readPool, err := db.OpenReadPool(cfg)
+	if err != nil {
+		log.Fatalf("Error opening read pool: %v", err)
+	}
+	if readPool != nil {
+		defer readPool.Close()
+	}
+	st := store.NewSQLite(database).ReadFrom(readPool)
+
+	ctx := context.Background()
+	tag := time.Now().UnixNano()
+	userIDs := make([]int, *users)
+	for i := range userIDs {
+		id, err := st.Users().Create(ctx, fmt.Sprintf("load%d.%d@dbload.test", i, tag), "hash")
+		if err != nil {
+			log.Fatalf("Error creating user: %v", err)
+		}
+		exercises := []models.PlanExercise{
+			{Name: "Back Squat", MuscleGroup: "quads", Scheme: "linear", Sets: 3, TargetReps: 5, LoadKg: 60, IncrementKg: 2.5},
+			{Name: "Bench Press", MuscleGroup: "chest", Scheme: "linear", Sets: 3, TargetReps: 5, LoadKg: 40, IncrementKg: 2.5},
+		}
+		if _, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: id, Title: "Load", Exercises: exercises}); err != nil {
+			log.Fatalf("Error creating plan: %v", err)
+		}
+		userIDs[i] = id
+	}
+
+	fmt.Printf("Running %d writers and %d readers for %s (journal %s, synchronous %s, busy timeout %s, main pool %d, read pool %d)\n",
+		*writers, *readers, *duration, cfg.SQLiteJournalMode, cfg.SQLiteSynchronous, cfg.SQLiteBusyTimeout, cfg.DBMaxOpenConns, cfg.DBReadConns)
+
+	var writes, reads stats
+	deadline := time.Now().Add(*duration)
+	var wg sync.WaitGroup
+	for w := 0; w < *writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; time.Now().Before(deadline); i += *writers {
+				started := time.Now()
+				writes.record(started, logWorkout(ctx, st, userIDs[i%len(userIDs)]))
+			}
+		}(w)
+	}
+	for r := 0; r < *readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := r; time.Now().Before(deadline); i += *readers {
+				started := time.Now()
+				userID := userIDs[i%len(userIDs)]
+				_, err := st.Plans().Active(ctx, userID)
+				if err == nil {
+					_, err = st.Workouts().Recent(ctx, userID, 10)
+				}
+				reads.record(started, err)
+			}
+		}(r)
+	}
+	wg.Wait()
+
+	writes.report("writes", *duration)
+	reads.report("reads", *duration)
+	return writes.errors+reads.errors == 0
+}
+
+// logWorkout records a session against the user's active plan and progresses its first exercise, like
+// POST /api/workouts
+func logWorkout(ctx context.Context, st store.Store, userID int) error {
+	plan, err := st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if plan == nil || len(plan.Exercises) == 0 {
+		return fmt.Errorf("user %d has no active plan", userID)
+	}
+	squat := plan.Exercises[0]
+	session := models.WorkoutSession{UserID: userID, PlanID: &plan.ID, PerformedAt: time.Now().UTC(), SessionRPE: 7, DurationMin: 50}
+	for set := 1; set <= squat.Sets; set++ {
+		session.Sets = append(session.Sets, models.WorkoutSet{PlanExerciseID: &squat.ID, ExerciseName: squat.Name, MuscleGroup: squat.MuscleGroup,
+			SetNumber: set, Reps: squat.TargetReps, LoadKg: squat.LoadKg, RPE: 7})
+	}
+	squat.LoadKg += squat.IncrementKg
+	return st.Workouts().Record(ctx, &session, []models.PlanExercise{squat})
+}
@@ -0,0 +1,93 @@
+// --- diet-fitness-backend/cmd/restore/main.go ---
+// Command restore puts a database backup back in place. The backup is decrypted with
+// BACKUP_ENCRYPTION_KEY and decompressed as needed, and must pass PRAGMA integrity_check before the
+// current database is touched; the current database is kept under a .pre-restore name.
+// Stop the server first: it would keep writing to the database that was moved aside.
+//
+//	go run ./cmd/restore -from ./data/backups/fitplan-20240301T020000Z.db.gz.enc
+//	go run ./cmd/restore -from backup.db.gz -verify    # only check the backup
+//	go run ./cmd/restore -list
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/backup"
+	"diet-fitness-backend/internal/db"
+)
+
+func main() {
+	from := flag.String("from", "", "backup file to restore")
+	to := flag.String("to", "", "database to replace; defaults to SQLITE_DB_PATH")
+	verify := flag.Bool("verify", false, "decode and check the backup without restoring it")
+	list := flag.Bool("list", false, "list the backups in BACKUP_DIR")
+	flag.Parse()
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("Error loading configuration: %v", err)
+	}
+	if db.DialectOf(cfg) != db.SQLite {
+		log.Fatal(backup.ErrUnsupported)
+	}
+	key, err := backup.ParseKey(cfg.BackupEncryptionKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *list {
+		cfg.BackupInterval = 0 // Only listing; no schedule
+		backups, err := backup.NewManager(nil, cfg)
+		if err != nil {
+			log.Fatal(err)
+		}
+		list, err := backups.List()
+		if err != nil {
+			log.Fatalf("Error listing backups: %v", err)
+		}
+		for _, b := range list {
+			fmt.Printf("%s  %12d  %s\n", b.CreatedAt.Format("2006-01-02 15:04:05Z"), b.SizeBytes, filepath.Join(cfg.BackupDir, b.Name))
+		}
+		return
+	}
+	if *from == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *verify {
+		tmp, err := os.CreateTemp("", "restore-verify-*.db")
+		if err != nil {
+			log.Fatal(err)
+		}
+		tmp.Close()
+		defer os.Remove(tmp.Name())
+		if err := backup.Decode(*from, tmp.Name(), key); err != nil {
+			log.Fatalf("Backup %s is unusable: %v", *from, err)
+		}
+		if err := backup.Verify(tmp.Name()); err != nil {
+			os.Remove(tmp.Name())
+			log.Fatalf("Backup %s is unusable: %v", *from, err)
+		}
+		fmt.Printf("Backup %s is intact\n", *from)
+		return
+	}
+
+	dst := *to
+	if dst == "" {
+		dst = cfg.SQLiteDBPath
+	}
+	previous, err := backup.Restore(*from, dst, key)
+	if err != nil {
+		log.Fatalf("Restore of %s failed: %v", dst, err)
+	}
+	fmt.Printf("Restored %s from %s\n", dst, *from)
+	if previous != "" {
+		fmt.Printf("The previous database was moved to %s\n", previous)
+	}
+}
@@ -2,8 +2,11 @@
 package config
 
 import (
-	"fmt"
+	"log/slog"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,9 +14,77 @@ import (
 // Config holds all application configurations
 type Config struct {
 	SQLiteDBPath string // Path to your SQLite database file
-	JWTSecret    string
-	ServerPort   string
-	UploadDir    string
+	DatabaseURL  string // postgres:// URL to use PostgreSQL instead; empty keeps SQLite at SQLiteDBPath
+
+	// SQLite connection settings, applied to every pooled connection
+	SQLiteJournalMode string        // WAL lets readers carry on while a write is in progress
+	SQLiteSynchronous string        // NORMAL is durable enough with WAL and saves an fsync per commit
+	SQLiteBusyTimeout time.Duration // How long a statement waits for a lock before failing with "database is locked"
+	SQLiteForeignKeys bool          // Enforce REFERENCES constraints
+
+	// Connection pools
+	DBMaxOpenConns    int           // Connections in the main pool, which serves writes and transactions
+	DBReadConns       int           // Connections in the read-only SQLite pool for plain reads; 0 reads through the main pool
+	DBConnMaxLifetime time.Duration // Replace connections after this long; 0 keeps them open
+
+	// Online SQLite backups, restored with cmd/restore
+	BackupDir           string
+	BackupInterval      time.Duration // Time between scheduled backups; 0 only backs up on request
+	BackupKeep          int           // Newest backups kept; 0 keeps them all
+	BackupGzip          bool
+	BackupEncryptionKey string // Base64 AES-256 key; backups are stored unencrypted without one
+	JWTSecret           string // Signs tokens with JWT_ALGORITHM=HS256; otherwise only verifies older HS256 tokens
+	ServerPort          string
+	LogLevel            string // debug, info, warn or error
+	LogFormat           string // json or text
+	UploadDir           string
+	ImportDir           string // Private scratch space for uploaded export archives; never served publicly
+	ExportDir           string // Private directory for personal data export zips awaiting download
+	JobWorkers          int    // Number of background workers for imports and exports
+	AppBaseURL          string // Frontend URL used in links sent by email
+	APIBaseURL          string // Public URL of this server, for OpenID Connect callbacks
+
+	// JWT signing: "EdDSA" or "RS256" use rotating key pairs published at /.well-known/jwks.json;
+	// "HS256" signs with JWT_SECRET
+	JWTAlgorithm   string
+	JWTKeyRotation time.Duration // How long each signing key is used before the next one takes over
+	JWTAcceptHS256 bool          // Keep accepting tokens signed with JWT_SECRET; disable once they have expired
+
+	RequireVerifiedEmail bool     // Block plan generation until the user has verified their email address
+	AdminEmails          []string // Accounts granted the admin role at startup, so a fresh install can assign roles
+
+	// Login throttling: failures double the wait before the next attempt until the lockout kicks in
+	LoginMaxFailures   int           // Failed logins per email before it is locked
+	LoginIPMaxFailures int           // Failed logins per client address before it is locked
+	LoginLockout       time.Duration // Lockout length, and the window failures are counted in
+	TrustProxyHeaders  bool          // Take the client address from X-Forwarded-For; only behind a proxy that sets it
+
+	// Password policy for registration, resets and changes
+	PasswordMinLength    int    // Minimum length in characters
+	PasswordMinScore     int    // Minimum strength score, 0 (anything) to 4 (very hard to guess)
+	BreachedPasswordsDir string // Local breached password hash corpus built by cmd/breachedlist; checked if present
+
+	// OpenID Connect providers users can sign in with, from OIDC_PROVIDERS and OIDC_<NAME>_* settings
+	OIDCProviders []OIDCProviderConfig
+
+	// Outgoing mail; MAIL_DRIVER is "log" (default, local development) or "smtp"
+	MailDriver   string
+	MailDir      string // With the log driver, messages are also written here as .eml files
+	MailFrom     string
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// OIDCProviderConfig describes one OpenID Connect provider. Its callback URL is
+// APIBaseURL + "/api/auth/oidc/<name>/callback".
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string // Defaults to openid, email and profile
 }
 
 // LoadConfig reads configuration from .env file or environment variables
@@ -21,19 +92,69 @@ func LoadConfig() (*Config, error) {
 	// Load .env file. If it doesn't exist, it's fine (e.g., in production where env vars are set directly)
 	err := godotenv.Load()
 	if err != nil && !os.IsNotExist(err) {
-		fmt.Printf("Warning: Could not load .env file: %v. Relying on environment variables.\n", err)
+		slog.Warn("Could not load .env file; relying on environment variables", "err", err)
 	}
 
 	cfg := &Config{
-		SQLiteDBPath: getEnv("SQLITE_DB_PATH", "./data/fitplan.db"),                          // Default SQLite path
-		JWTSecret:    getEnv("JWT_SECRET", "default-jwt-secret-please-change-in-production"), // Fallback for dev
-		ServerPort:   getEnv("SERVER_PORT", "8080"),
-		UploadDir:    getEnv("UPLOAD_DIR", "./uploads"),
+		SQLiteDBPath:         getEnv("SQLITE_DB_PATH", "./data/fitplan.db"), // Default SQLite path
+		DatabaseURL:          getEnv("DATABASE_URL", ""),
+		SQLiteJournalMode:    strings.ToUpper(getEnv("SQLITE_JOURNAL_MODE", "WAL")),
+		SQLiteSynchronous:    strings.ToUpper(getEnv("SQLITE_SYNCHRONOUS", "NORMAL")),
+		SQLiteBusyTimeout:    getEnvDuration("SQLITE_BUSY_TIMEOUT", 5*time.Second),
+		SQLiteForeignKeys:    getEnvBool("SQLITE_FOREIGN_KEYS", true),
+		DBMaxOpenConns:       getEnvInt("DB_MAX_OPEN_CONNS", 4),
+		DBReadConns:          getEnvInt("DB_READ_CONNS", 8),
+		DBConnMaxLifetime:    getEnvDuration("DB_CONN_MAX_LIFETIME", 0),
+		BackupDir:            getEnv("BACKUP_DIR", "./data/backups"),
+		BackupInterval:       getEnvDuration("BACKUP_INTERVAL", 24*time.Hour),
+		BackupKeep:           getEnvInt("BACKUP_KEEP", 7),
+		BackupGzip:           getEnvBool("BACKUP_GZIP", true),
+		BackupEncryptionKey:  getEnv("BACKUP_ENCRYPTION_KEY", ""),
+		JWTSecret:            getEnv("JWT_SECRET", "default-jwt-secret-please-change-in-production"), // Fallback for dev
+		ServerPort:           getEnv("SERVER_PORT", "8080"),
+		LogLevel:             getEnv("LOG_LEVEL", "info"),
+		LogFormat:            strings.ToLower(getEnv("LOG_FORMAT", "json")),
+		UploadDir:            getEnv("UPLOAD_DIR", "./uploads"),
+		ImportDir:            getEnv("IMPORT_DIR", "./data/imports"),
+		ExportDir:            getEnv("EXPORT_DIR", "./data/exports"),
+		JobWorkers:           getEnvInt("JOB_WORKERS", 2),
+		AppBaseURL:           getEnv("APP_BASE_URL", "http://localhost:3000"),
+		APIBaseURL:           getEnv("API_BASE_URL", "http://localhost:8080"),
+		JWTAlgorithm:         getEnv("JWT_ALGORITHM", "EdDSA"),
+		JWTKeyRotation:       getEnvDuration("JWT_KEY_ROTATION", 30*24*time.Hour),
+		JWTAcceptHS256:       getEnvBool("JWT_ACCEPT_HS256", true),
+		RequireVerifiedEmail: getEnvBool("REQUIRE_VERIFIED_EMAIL", false),
+		AdminEmails:          getEnvList("ADMIN_EMAILS"),
+		LoginMaxFailures:     getEnvInt("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:   getEnvInt("LOGIN_IP_MAX_FAILURES", 50),
+		LoginLockout:         getEnvDuration("LOGIN_LOCKOUT", 15*time.Minute),
+		TrustProxyHeaders:    getEnvBool("TRUST_PROXY_HEADERS", false),
+		PasswordMinLength:    getEnvInt("PASSWORD_MIN_LENGTH", 10),
+		PasswordMinScore:     getEnvInt("PASSWORD_MIN_SCORE", 3),
+		BreachedPasswordsDir: getEnv("BREACHED_PASSWORDS_DIR", "./data/breached"),
+		MailDriver:           getEnv("MAIL_DRIVER", "log"),
+		MailDir:              getEnv("MAIL_DIR", "./data/mail"),
+		MailFrom:             getEnv("MAIL_FROM", ""),
+		SMTPHost:             getEnv("SMTP_HOST", ""),
+		SMTPPort:             getEnv("SMTP_PORT", "587"),
+		SMTPUsername:         getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:         getEnv("SMTP_PASSWORD", ""),
+	}
+
+	for _, name := range getEnvList("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		cfg.OIDCProviders = append(cfg.OIDCProviders, OIDCProviderConfig{
+			Name:         strings.ToLower(name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "")),
+		})
 	}
 
 	// Basic validation for critical config
 	if cfg.JWTSecret == "default-jwt-secret-please-change-in-production" {
-		fmt.Println("WARNING: JWT_SECRET is using a default value. Please set a strong secret in your .env file or environment variables for security.")
+		slog.Warn("JWT_SECRET is using a default value. Please set a strong secret in your .env file or environment variables for security.")
 	}
 
 	return cfg, nil
@@ -46,3 +167,56 @@ func getEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+// Helper function to get an integer environment variable or fallback to a default
+func getEnvInt(key string, fallback int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("Invalid integer setting; using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
+// Helper function to get a boolean environment variable or fallback to a default
+func getEnvBool(key string, fallback bool) bool {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("Invalid boolean setting; using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return b
+}
+
+// Helper function to get a duration environment variable (e.g. "720h") or fallback to a default
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("Invalid duration setting; using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return d
+}
+
+// Helper function to get a comma-separated environment variable as a list; empty entries are dropped
+func getEnvList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,273 @@
+// --- diet-fitness-backend/internal/account/account.go ---
+package account
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// table describes where a user's rows live. Child tables without a user_id column are reached through their parent.
+// Every table holding user data must be listed here so it is both exported and erased.
+type table struct {
+	name   string
+	query  string // Selects the user's rows for export; password hashes and token hashes are never exported
+	delete string // Erases the user's rows; statements run in list order
+}
+
+// tables is ordered children first so deletes never leave dangling references
+var tables = []table{
+	// Comments the user wrote anywhere, and every comment on the user's own sessions
+	{"session_comments",
+		"SELECT * FROM session_comments WHERE ? IN (author_id, (SELECT user_id FROM workout_sessions WHERE id = session_id)) ORDER BY id",
+		"DELETE FROM session_comments WHERE ? IN (author_id, (SELECT user_id FROM workout_sessions WHERE id = session_id))"},
+	{"workout_sets",
+		"SELECT s.* FROM workout_sets s JOIN workout_sessions w ON w.id = s.session_id WHERE w.user_id = ? ORDER BY s.id",
+		"DELETE FROM workout_sets WHERE session_id IN (SELECT id FROM workout_sessions WHERE user_id = ?)"},
+	{"workout_sessions", "SELECT * FROM workout_sessions WHERE user_id = ? ORDER BY id", "DELETE FROM workout_sessions WHERE user_id = ?"},
+	{"plan_exercises",
+		"SELECT e.* FROM plan_exercises e JOIN workout_plans p ON p.id = e.plan_id WHERE p.user_id = ? ORDER BY e.id",
+		"DELETE FROM plan_exercises WHERE plan_id IN (SELECT id FROM workout_plans WHERE user_id = ?)"},
+	{"workout_plans", "SELECT * FROM workout_plans WHERE user_id = ? ORDER BY id", "DELETE FROM workout_plans WHERE user_id = ?"},
+	{"profile", "SELECT * FROM user_profiles WHERE user_id = ?", "DELETE FROM user_profiles WHERE user_id = ?"},
+	{"activities", "SELECT * FROM activities WHERE user_id = ? ORDER BY start_time", "DELETE FROM activities WHERE user_id = ?"},
+	{"body_weights", "SELECT * FROM body_weights WHERE user_id = ? ORDER BY measured_at", "DELETE FROM body_weights WHERE user_id = ?"},
+	{"daily_steps", "SELECT * FROM daily_steps WHERE user_id = ? ORDER BY day", "DELETE FROM daily_steps WHERE user_id = ?"},
+	{"heart_rate_samples", "SELECT * FROM heart_rate_samples WHERE user_id = ? ORDER BY measured_at", "DELETE FROM heart_rate_samples WHERE user_id = ?"},
+	{"sleep_sessions", "SELECT * FROM sleep_sessions WHERE user_id = ? ORDER BY start_time", "DELETE FROM sleep_sessions WHERE user_id = ?"},
+	{"diary_entries", "SELECT * FROM diary_entries WHERE user_id = ? ORDER BY entry_date, id", "DELETE FROM diary_entries WHERE user_id = ?"},
+	{"custom_foods", "SELECT * FROM foods WHERE user_id = ? ORDER BY id", "DELETE FROM foods WHERE user_id = ?"},
+	{"jobs", "SELECT * FROM jobs WHERE user_id = ? ORDER BY id", "DELETE FROM jobs WHERE user_id = ?"},
+	{"user_tokens", "SELECT id, purpose, expires_at, used_at, created_at FROM user_tokens WHERE user_id = ? ORDER BY id",
+		"DELETE FROM user_tokens WHERE user_id = ?"},
+	{"mfa", "SELECT enabled_at, created_at FROM user_mfa WHERE user_id = ?", "DELETE FROM user_mfa WHERE user_id = ?"},
+	{"mfa_recovery_codes", "SELECT id, used_at, created_at FROM mfa_recovery_codes WHERE user_id = ? ORDER BY id",
+		"DELETE FROM mfa_recovery_codes WHERE user_id = ?"},
+	{"coaching", "SELECT * FROM coach_clients WHERE ? IN (coach_id, client_id) ORDER BY id", "DELETE FROM coach_clients WHERE ? IN (coach_id, client_id)"},
+	{"roles", "SELECT r.name AS role, ur.created_at FROM user_roles ur JOIN roles r ON r.id = ur.role_id WHERE ur.user_id = ? ORDER BY r.name",
+		"DELETE FROM user_roles WHERE user_id = ?"},
+	{"uploads", "SELECT * FROM uploads WHERE user_id = ? ORDER BY id", "DELETE FROM uploads WHERE user_id = ?"},
+	{"identities", "SELECT provider, subject, email, created_at, last_login_at FROM user_identities WHERE user_id = ? ORDER BY id",
+		"DELETE FROM user_identities WHERE user_id = ?"},
+	{"oidc_states", "SELECT provider, expires_at, created_at FROM oidc_states WHERE user_id = ?", "DELETE FROM oidc_states WHERE user_id = ?"},
+	{"api_keys", "SELECT id, name, prefix, scopes, expires_at, last_used_at, created_at, revoked_at FROM api_keys WHERE user_id = ? ORDER BY id",
+		"DELETE FROM api_keys WHERE user_id = ?"},
+	{"sessions", "SELECT id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at FROM user_sessions WHERE user_id = ? ORDER BY id",
+		"DELETE FROM user_sessions WHERE user_id = ?"},
+	{"login_attempts", "SELECT email, ip, outcome, created_at FROM login_attempts WHERE user_id = ? ORDER BY id",
+		"DELETE FROM login_attempts WHERE user_id = ?"},
+	{"account", "SELECT id, email, email_verified_at, created_at FROM users WHERE id = ?", "DELETE FROM users WHERE id = ?"},
+}
+
+// ExportSummary describes a finished data export
+type ExportSummary struct {
+	File   string         `json:"file"` // Name of the zip inside the export directory
+	Bytes  int64          `json:"bytes"`
+	Rows   map[string]int `json:"rows"` // Rows exported per entity
+	Images int            `json:"images"`
+}
+
+// Export writes a zip to dir holding one JSON file per entity plus the user's uploaded images.
+// The zip is written under a temporary name and renamed once complete.
+func Export(ctx context.Context, db *sql.DB, userID int, uploadDir, dir string, progress func(percent float64, message string)) (ExportSummary, error) {
+	summary := ExportSummary{
+		File: fmt.Sprintf("export_user_%d_%d.zip", userID, time.Now().UnixNano()),
+		Rows: map[string]int{},
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return summary, fmt.Errorf("error creating export directory: %w", err)
+	}
+	final := filepath.Join(dir, summary.File)
+	tmp := final + ".partial"
+
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return summary, fmt.Errorf("error creating export file: %w", err)
+	}
+	defer os.Remove(tmp) // No-op after the rename
+	zw := zip.NewWriter(out)
+
+	for i, t := range tables {
+		if err := ctx.Err(); err != nil {
+			out.Close()
+			return summary, err
+		}
+		progress(float64(i)/float64(len(tables))*80, "Exporting "+t.name)
+		n, err := exportTable(db, zw, userID, t)
+		if err != nil {
+			out.Close()
+			return summary, fmt.Errorf("error exporting %s: %w", t.name, err)
+		}
+		summary.Rows[t.name] = n
+	}
+
+	progress(80, "Adding uploaded images")
+	files, err := UploadedFiles(db, userID, uploadDir)
+	if err != nil {
+		out.Close()
+		return summary, err
+	}
+	for _, path := range files {
+		if err := addFile(zw, "uploads/"+filepath.Base(path), path); err != nil {
+			if os.IsNotExist(err) {
+				continue // Removed from disk after it was recorded
+			}
+			out.Close()
+			return summary, fmt.Errorf("error adding %s: %w", filepath.Base(path), err)
+		}
+		summary.Images++
+	}
+
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return summary, fmt.Errorf("error finishing export zip: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return summary, fmt.Errorf("error finishing export zip: %w", err)
+	}
+	if err := os.Rename(tmp, final); err != nil {
+		return summary, fmt.Errorf("error finishing export zip: %w", err)
+	}
+	if info, err := os.Stat(final); err == nil {
+		summary.Bytes = info.Size()
+	}
+	progress(100, "Export ready")
+	return summary, nil
+}
+
+// exportTable writes the user's rows of one table as a JSON array of objects keyed by column name
+func exportTable(db *sql.DB, zw *zip.Writer, userID int, t table) (int, error) {
+	rows, err := db.Query(t.query, userID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	records := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, err
+		}
+		record := make(map[string]interface{}, len(columns))
+		for i, c := range columns {
+			record[c] = jsonValue(values[i])
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	f, err := createEntry(zw, t.name+".json")
+	if err != nil {
+		return 0, err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return len(records), enc.Encode(records)
+}
+
+// jsonValue turns driver values into something readable: text instead of bytes, and embedded JSON columns as JSON
+func jsonValue(v interface{}) interface{} {
+	if b, ok := v.([]byte); ok {
+		v = string(b)
+	}
+	if s, ok := v.(string); ok && len(s) > 1 && (s[0] == '[' || s[0] == '{') && json.Valid([]byte(s)) {
+		return json.RawMessage(s)
+	}
+	return v
+}
+
+func addFile(zw *zip.Writer, name, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := createEntry(zw, name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// createEntry adds a compressed, timestamped file to the zip (zip.Writer.Create leaves the date empty)
+func createEntry(zw *zip.Writer, name string) (io.Writer, error) {
+	return zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+}
+
+// UploadedFiles lists the user's files in the upload directory: those recorded in the uploads table plus
+// images uploaded before uploads were recorded, which are recognised by their user_<id>_ name prefix.
+func UploadedFiles(db *sql.DB, userID int, uploadDir string) ([]string, error) {
+	seen := map[string]bool{}
+	var files []string
+
+	rows, err := db.Query("SELECT filename FROM uploads WHERE user_id = ?", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing uploads: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error listing uploads: %w", err)
+		}
+		path := filepath.Join(uploadDir, filepath.Base(name))
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing uploads: %w", err)
+	}
+
+	legacy, err := filepath.Glob(filepath.Join(uploadDir, fmt.Sprintf("user_%d_*", userID)))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range legacy {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// Delete erases every row the user owns, including the users row itself. Run it inside a transaction.
+func Delete(tx *sql.Tx, userID int) error {
+	for _, t := range tables {
+		if _, err := tx.Exec(t.delete, userID); err != nil {
+			return fmt.Errorf("error deleting %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+// RemoveFiles deletes files from disk, ignoring ones that are already gone, and returns the first other error
+func RemoveFiles(paths []string) error {
+	var first error
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,315 @@
+// --- diet-fitness-backend/internal/activityfile/activityfile.go ---
+package activityfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// Supported file formats
+const (
+	FormatGPX = "gpx"
+	FormatTCX = "tcx"
+	FormatFIT = "fit"
+)
+
+// ErrUnknownFormat is returned when a file is neither GPX, TCX nor FIT
+var ErrUnknownFormat = errors.New("unrecognised activity file format; expected GPX, TCX or FIT")
+
+// ErrNoData is returned when a file parses but contains no usable samples
+var ErrNoData = errors.New("activity file contains no timestamped samples")
+
+const (
+	earthRadiusM        = 6371008.8
+	elevationHysteresis = 1.0 // Metres an ascent must exceed before it counts, to filter GPS/barometer noise
+	splitDistanceM      = 1000.0
+)
+
+// point is a single track sample; every format is normalised into these
+type point struct {
+	Time      time.Time
+	Lat, Lon  float64
+	HasPos    bool
+	Elevation float64
+	HasEle    bool
+	DistanceM float64 // Cumulative distance as reported by the device; 0 when unknown
+	HeartRate int
+}
+
+// track is the format-independent result of parsing a file
+type track struct {
+	sport  string
+	device string
+	start  time.Time
+	points []point
+
+	// Device-reported totals take precedence over values derived from the points
+	totalDistanceM float64
+	totalDurationS float64
+	totalAscentM   float64
+}
+
+// DetectFormat guesses the file format from its extension, falling back to sniffing the content
+func DetectFormat(filename string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gpx":
+		return FormatGPX
+	case ".tcx":
+		return FormatTCX
+	case ".fit":
+		return FormatFIT
+	}
+	if len(data) >= 12 && string(data[8:12]) == ".FIT" {
+		return FormatFIT
+	}
+	head := data
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	switch {
+	case bytes.Contains(head, []byte("<gpx")):
+		return FormatGPX
+	case bytes.Contains(head, []byte("<TrainingCenterDatabase")):
+		return FormatTCX
+	}
+	return ""
+}
+
+// Parse decodes a GPX, TCX or FIT file into an activity summary with splits and heart-rate samples
+func Parse(filename string, data []byte) (models.Activity, error) {
+	format := DetectFormat(filename, data)
+	var (
+		t   track
+		err error
+	)
+	switch format {
+	case FormatGPX:
+		t, err = parseGPX(data)
+	case FormatTCX:
+		t, err = parseTCX(data)
+	case FormatFIT:
+		t, err = parseFIT(data)
+	default:
+		return models.Activity{}, ErrUnknownFormat
+	}
+	if err != nil {
+		return models.Activity{}, fmt.Errorf("error parsing %s file: %w", strings.ToUpper(format), err)
+	}
+
+	activity, err := summarize(t)
+	if err != nil {
+		return models.Activity{}, err
+	}
+	activity.Source = format
+	return activity, nil
+}
+
+// NormalizeSport maps the various sport names used by devices onto our small set
+func NormalizeSport(s string) string {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "running", "run", "trail_running", "treadmill_running":
+		return "running"
+	case "cycling", "biking", "bike", "ride", "road_biking", "mountain_biking", "indoor_cycling":
+		return "cycling"
+	case "walking", "walk":
+		return "walking"
+	case "hiking", "hike":
+		return "hiking"
+	case "swimming", "swim", "open_water_swimming", "lap_swimming":
+		return "swimming"
+	}
+	return "other"
+}
+
+func summarize(t track) (models.Activity, error) {
+	points := make([]point, 0, len(t.points))
+	for _, p := range t.points {
+		if !p.Time.IsZero() {
+			points = append(points, p)
+		}
+	}
+	if len(points) == 0 {
+		return models.Activity{}, ErrNoData
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+
+	start := t.start
+	if start.IsZero() || points[0].Time.Before(start) {
+		start = points[0].Time
+	}
+
+	// Cumulative distance per point: trust the device when it reports one, otherwise integrate GPS positions
+	hasDeviceDistance := false
+	for _, p := range points {
+		if p.DistanceM > 0 {
+			hasDeviceDistance = true
+			break
+		}
+	}
+	distances := make([]float64, len(points))
+	var last *point
+	for i := range points {
+		p := &points[i]
+		switch {
+		case hasDeviceDistance:
+			distances[i] = p.DistanceM
+			if i > 0 && distances[i] < distances[i-1] {
+				distances[i] = distances[i-1] // Some devices emit 0 for samples without a fix
+			}
+		case i > 0:
+			distances[i] = distances[i-1]
+			if p.HasPos && last != nil {
+				distances[i] += haversine(last.Lat, last.Lon, p.Lat, p.Lon)
+			}
+		}
+		if p.HasPos {
+			last = p
+		}
+	}
+
+	a := models.Activity{
+		Sport:     NormalizeSport(t.sport),
+		StartTime: start.UTC().Truncate(time.Second),
+		Device:    strings.TrimSpace(t.device),
+		Splits:    []models.ActivitySplit{},
+	}
+	if a.Device == "" {
+		a.Device = "unknown"
+	}
+
+	a.DistanceM = distances[len(distances)-1]
+	if t.totalDistanceM > 0 {
+		a.DistanceM = t.totalDistanceM
+	}
+	a.DurationS = points[len(points)-1].Time.Sub(start).Seconds()
+	if t.totalDurationS > 0 {
+		a.DurationS = t.totalDurationS
+	}
+
+	// Elevation gain with a small hysteresis so noise doesn't accumulate into phantom climbing
+	gains := make([]float64, len(points)) // Cumulative gain per point, used for split elevation
+	ref, haveRef := 0.0, false
+	for i, p := range points {
+		if i > 0 {
+			gains[i] = gains[i-1]
+		}
+		if !p.HasEle {
+			continue
+		}
+		switch {
+		case !haveRef:
+			ref, haveRef = p.Elevation, true
+		case p.Elevation > ref+elevationHysteresis:
+			gains[i] += p.Elevation - ref
+			ref = p.Elevation
+		case p.Elevation < ref:
+			ref = p.Elevation
+		}
+	}
+	a.ElevationGainM = math.Round(gains[len(gains)-1]*10) / 10
+	if t.totalAscentM > 0 {
+		a.ElevationGainM = t.totalAscentM
+	}
+
+	// Heart-rate samples, one per second at most
+	hrTotal, lastOffset := 0, -1
+	for _, p := range points {
+		if p.HeartRate <= 0 || p.HeartRate > 250 {
+			continue
+		}
+		offset := int(p.Time.Sub(start).Seconds())
+		if offset == lastOffset {
+			continue
+		}
+		lastOffset = offset
+		a.HeartRate = append(a.HeartRate, models.HeartRateSample{OffsetS: offset, BPM: p.HeartRate})
+		hrTotal += p.HeartRate
+		if p.HeartRate > a.MaxHeartRate {
+			a.MaxHeartRate = p.HeartRate
+		}
+	}
+	if len(a.HeartRate) > 0 {
+		a.AvgHeartRate = int(math.Round(float64(hrTotal) / float64(len(a.HeartRate))))
+	}
+
+	a.Splits = splits(points, distances, gains, start)
+	a.DistanceM = math.Round(a.DistanceM*10) / 10
+	a.DurationS = math.Round(a.DurationS)
+	return a, nil
+}
+
+// splits cuts the track into 1 km segments, interpolating the time at each kilometre boundary
+func splits(points []point, distances, gains []float64, start time.Time) []models.ActivitySplit {
+	result := []models.ActivitySplit{}
+	if len(points) < 2 || distances[len(distances)-1] <= 0 {
+		return result
+	}
+
+	splitStartT, splitStartD, splitStartGain := start, 0.0, 0.0
+	hrSum, hrCount := 0, 0
+	flush := func(endT time.Time, endD, endGain float64) {
+		d := endD - splitStartD
+		if d <= 1 {
+			return
+		}
+		s := models.ActivitySplit{
+			Index:      len(result) + 1,
+			DistanceM:  math.Round(d*10) / 10,
+			DurationS:  math.Round(endT.Sub(splitStartT).Seconds()*10) / 10,
+			ElevationM: math.Round((endGain-splitStartGain)*10) / 10,
+		}
+		s.PaceSecPerKm = math.Round(s.DurationS / (d / 1000))
+		if hrCount > 0 {
+			s.AvgHeartRate = int(math.Round(float64(hrSum) / float64(hrCount)))
+		}
+		result = append(result, s)
+		splitStartT, splitStartD, splitStartGain = endT, endD, endGain
+		hrSum, hrCount = 0, 0
+	}
+
+	for i := 1; i < len(points); i++ {
+		if points[i].HeartRate > 0 {
+			hrSum += points[i].HeartRate
+			hrCount++
+		}
+		for next := splitStartD + splitDistanceM; distances[i] >= next; next = splitStartD + splitDistanceM {
+			// Interpolate the moment the boundary was crossed between the previous and current sample
+			frac := 1.0
+			if span := distances[i] - distances[i-1]; span > 0 {
+				frac = (next - distances[i-1]) / span
+			}
+			crossT := points[i-1].Time.Add(time.Duration(frac * float64(points[i].Time.Sub(points[i-1].Time))))
+			crossGain := gains[i-1] + frac*(gains[i]-gains[i-1])
+			flush(crossT, next, crossGain)
+		}
+	}
+	last := len(points) - 1
+	flush(points[last].Time, distances[last], gains[last])
+	return result
+}
+
+// haversine returns the great-circle distance in metres between two coordinates
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := math.Pi / 180
+	dLat := (lat2 - lat1) * toRad
+	dLon := (lon2 - lon1) * toRad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1*toRad)*math.Cos(lat2*toRad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusM * math.Asin(math.Sqrt(a))
+}
+
+// parseTime accepts the RFC 3339 timestamps used by GPX and TCX
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
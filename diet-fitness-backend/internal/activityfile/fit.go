@@ -0,0 +1,322 @@
+// --- diet-fitness-backend/internal/activityfile/fit.go ---
+package activityfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// FIT is Garmin's binary format. This decoder understands just enough of the protocol
+// (definition/data messages, compressed timestamps, developer fields) to read
+// file_id, device_info, session and record messages; everything else is skipped.
+
+// fitEpoch is the FIT timestamp origin (1989-12-31T00:00:00Z)
+var fitEpoch = time.Date(1989, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// Global message numbers we care about
+const (
+	fitMsgFileID     = 0
+	fitMsgSession    = 18
+	fitMsgRecord     = 20
+	fitMsgDeviceInfo = 23
+)
+
+const fitFieldTimestamp = 253
+
+// fitSports maps the FIT sport enum onto our sport names
+var fitSports = map[int64]string{1: "running", 2: "cycling", 5: "swimming", 11: "walking", 17: "hiking"}
+
+// fitManufacturers names the most common manufacturers; others are reported by number
+var fitManufacturers = map[int64]string{
+	1: "garmin", 15: "dynastream", 23: "suunto", 32: "wahoo_fitness",
+	123: "polar", 260: "zwift", 265: "strava", 294: "coros",
+}
+
+type fitFieldDef struct {
+	num      byte
+	size     int
+	baseType byte
+}
+
+type fitDefinition struct {
+	global    uint16
+	bigEndian bool
+	fields    []fitFieldDef
+	devSize   int // Total size of developer fields, which we skip
+}
+
+var errFITTruncated = errors.New("truncated FIT file")
+
+func parseFIT(data []byte) (track, error) {
+	if len(data) < 12 {
+		return track{}, errFITTruncated
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || len(data) < headerSize || string(data[8:12]) != ".FIT" {
+		return track{}, errors.New("missing FIT file header")
+	}
+	end := headerSize + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end > len(data) {
+		return track{}, errFITTruncated
+	}
+
+	var (
+		t             track
+		defs          = map[byte]*fitDefinition{}
+		lastTimestamp uint32
+		manufacturer  int64 = -1
+		product       int64 = -1
+		serial        int64 = -1
+	)
+
+	pos := headerSize
+	for pos < end {
+		header := data[pos]
+		pos++
+
+		var (
+			def        *fitDefinition
+			compressed bool
+		)
+		switch {
+		case header&0x80 != 0:
+			// Compressed timestamp header: 2-bit local type, 5-bit offset from the last full timestamp
+			def = defs[(header>>5)&0x03]
+			offset := uint32(header & 0x1f)
+			ts := lastTimestamp&^0x1f + offset
+			if offset < lastTimestamp&0x1f {
+				ts += 0x20
+			}
+			lastTimestamp = ts
+			compressed = true
+		case header&0x40 != 0:
+			d, n, err := readFITDefinition(data[pos:end], header&0x20 != 0)
+			if err != nil {
+				return track{}, err
+			}
+			defs[header&0x0f] = d
+			pos += n
+			continue
+		default:
+			def = defs[header&0x0f]
+		}
+		if def == nil {
+			return track{}, fmt.Errorf("FIT data message at offset %d has no definition", pos-1)
+		}
+
+		values, n, err := readFITData(def, data[pos:end])
+		if err != nil {
+			return track{}, err
+		}
+		pos += n
+
+		if ts, ok := values[fitFieldTimestamp]; ok {
+			lastTimestamp = uint32(ts)
+		} else if compressed {
+			values[fitFieldTimestamp] = int64(lastTimestamp)
+		}
+
+		switch def.global {
+		case fitMsgFileID:
+			if v, ok := values[1]; ok {
+				manufacturer = v
+			}
+			if v, ok := values[2]; ok {
+				product = v
+			}
+			if v, ok := values[3]; ok {
+				serial = v
+			}
+		case fitMsgDeviceInfo:
+			// device_index 0 is the device that created the file
+			if idx, ok := values[0]; ok && idx == 0 && serial < 0 {
+				if v, ok := values[3]; ok {
+					serial = v
+				}
+			}
+		case fitMsgSession:
+			if v, ok := values[2]; ok {
+				t.start = fitTime(v)
+			}
+			if v, ok := values[5]; ok {
+				t.sport = fitSports[v]
+			}
+			if v, ok := values[7]; ok {
+				t.totalDurationS = float64(v) / 1000
+			}
+			if v, ok := values[9]; ok {
+				t.totalDistanceM = float64(v) / 100
+			}
+			if v, ok := values[22]; ok {
+				t.totalAscentM = float64(v)
+			}
+		case fitMsgRecord:
+			ts, ok := values[fitFieldTimestamp]
+			if !ok {
+				continue
+			}
+			p := point{Time: fitTime(ts)}
+			lat, okLat := values[0]
+			lon, okLon := values[1]
+			if okLat && okLon {
+				p.Lat, p.Lon, p.HasPos = semicircles(lat), semicircles(lon), true
+			}
+			if v, ok := values[78]; ok { // enhanced_altitude
+				p.Elevation, p.HasEle = float64(v)/5-500, true
+			} else if v, ok := values[2]; ok {
+				p.Elevation, p.HasEle = float64(v)/5-500, true
+			}
+			if v, ok := values[3]; ok {
+				p.HeartRate = int(v)
+			}
+			if v, ok := values[5]; ok {
+				p.DistanceM = float64(v) / 100
+			}
+			t.points = append(t.points, p)
+		}
+	}
+
+	if manufacturer >= 0 {
+		name, ok := fitManufacturers[manufacturer]
+		if !ok {
+			name = fmt.Sprintf("manufacturer-%d", manufacturer)
+		}
+		t.device = name
+		if product >= 0 {
+			t.device += fmt.Sprintf("-%d", product)
+		}
+		if serial > 0 {
+			t.device += fmt.Sprintf("-%d", serial)
+		}
+	}
+	return t, nil
+}
+
+func readFITDefinition(b []byte, hasDevFields bool) (*fitDefinition, int, error) {
+	if len(b) < 5 {
+		return nil, 0, errFITTruncated
+	}
+	d := &fitDefinition{bigEndian: b[1] == 1}
+	if d.bigEndian {
+		d.global = binary.BigEndian.Uint16(b[2:4])
+	} else {
+		d.global = binary.LittleEndian.Uint16(b[2:4])
+	}
+	numFields := int(b[4])
+	pos := 5
+	if len(b) < pos+numFields*3 {
+		return nil, 0, errFITTruncated
+	}
+	for i := 0; i < numFields; i++ {
+		d.fields = append(d.fields, fitFieldDef{num: b[pos], size: int(b[pos+1]), baseType: b[pos+2]})
+		pos += 3
+	}
+	if hasDevFields {
+		if len(b) < pos+1 {
+			return nil, 0, errFITTruncated
+		}
+		numDev := int(b[pos])
+		pos++
+		if len(b) < pos+numDev*3 {
+			return nil, 0, errFITTruncated
+		}
+		for i := 0; i < numDev; i++ {
+			d.devSize += int(b[pos+1])
+			pos += 3
+		}
+	}
+	return d, pos, nil
+}
+
+// readFITData decodes the numeric fields of a data message; invalid (unset) values are left out
+func readFITData(d *fitDefinition, b []byte) (map[byte]int64, int, error) {
+	values := map[byte]int64{}
+	pos := 0
+	for _, f := range d.fields {
+		if len(b) < pos+f.size {
+			return nil, 0, errFITTruncated
+		}
+		if v, ok := fitValue(b[pos:pos+f.size], f.baseType, d.bigEndian); ok {
+			values[f.num] = v
+		}
+		pos += f.size
+	}
+	if len(b) < pos+d.devSize {
+		return nil, 0, errFITTruncated
+	}
+	return values, pos + d.devSize, nil
+}
+
+// fitValue reads the first element of a field; arrays, strings and floats are not needed here
+func fitValue(b []byte, baseType byte, bigEndian bool) (int64, bool) {
+	var order binary.ByteOrder = binary.LittleEndian
+	if bigEndian {
+		order = binary.BigEndian
+	}
+	switch baseType & 0x1f {
+	case 0x00, 0x02, 0x0d: // enum, uint8, byte
+		if len(b) < 1 || b[0] == 0xff {
+			return 0, false
+		}
+		return int64(b[0]), true
+	case 0x0a: // uint8z
+		if len(b) < 1 || b[0] == 0 {
+			return 0, false
+		}
+		return int64(b[0]), true
+	case 0x01: // sint8
+		if len(b) < 1 || b[0] == 0x7f {
+			return 0, false
+		}
+		return int64(int8(b[0])), true
+	case 0x03: // sint16
+		if len(b) < 2 {
+			return 0, false
+		}
+		v := order.Uint16(b)
+		if v == 0x7fff {
+			return 0, false
+		}
+		return int64(int16(v)), true
+	case 0x04, 0x0b: // uint16, uint16z
+		if len(b) < 2 {
+			return 0, false
+		}
+		v := order.Uint16(b)
+		if v == 0xffff || (baseType&0x1f == 0x0b && v == 0) {
+			return 0, false
+		}
+		return int64(v), true
+	case 0x05: // sint32
+		if len(b) < 4 {
+			return 0, false
+		}
+		v := order.Uint32(b)
+		if v == 0x7fffffff {
+			return 0, false
+		}
+		return int64(int32(v)), true
+	case 0x06, 0x0c: // uint32, uint32z
+		if len(b) < 4 {
+			return 0, false
+		}
+		v := order.Uint32(b)
+		if v == 0xffffffff || (baseType&0x1f == 0x0c && v == 0) {
+			return 0, false
+		}
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func fitTime(v int64) time.Time {
+	return fitEpoch.Add(time.Duration(v) * time.Second)
+}
+
+// semicircles converts FIT's 32-bit angle representation to degrees
+func semicircles(v int64) float64 {
+	return float64(v) * (180 / math.Pow(2, 31))
+}
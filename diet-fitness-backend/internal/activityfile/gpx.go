@@ -0,0 +1,51 @@
+// --- diet-fitness-backend/internal/activityfile/gpx.go ---
+package activityfile
+
+import (
+	"encoding/xml"
+)
+
+// gpxFile covers the parts of GPX 1.1 we use, including the Garmin TrackPointExtension heart rate.
+// Namespaced elements match on their local name, so any extension prefix works.
+type gpxFile struct {
+	Creator  string `xml:"creator,attr"`
+	Metadata struct {
+		Time string `xml:"time"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Lat       float64  `xml:"lat,attr"`
+				Lon       float64  `xml:"lon,attr"`
+				Elevation *float64 `xml:"ele"`
+				Time      string   `xml:"time"`
+				HeartRate int      `xml:"extensions>TrackPointExtension>hr"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+func parseGPX(data []byte) (track, error) {
+	var f gpxFile
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return track{}, err
+	}
+
+	t := track{device: f.Creator, start: parseTime(f.Metadata.Time)}
+	for _, trk := range f.Tracks {
+		if t.sport == "" {
+			t.sport = trk.Type
+		}
+		for _, seg := range trk.Segments {
+			for _, p := range seg.Points {
+				pt := point{Time: parseTime(p.Time), Lat: p.Lat, Lon: p.Lon, HasPos: true, HeartRate: p.HeartRate}
+				if p.Elevation != nil {
+					pt.Elevation, pt.HasEle = *p.Elevation, true
+				}
+				t.points = append(t.points, pt)
+			}
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,77 @@
+// --- diet-fitness-backend/internal/activityfile/tcx.go ---
+package activityfile
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// tcxFile covers the parts of the Garmin Training Center (TCX v2) schema we use
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		ID    string `xml:"Id"`
+		Laps  []struct {
+			StartTime        string  `xml:"StartTime,attr"`
+			TotalTimeSeconds float64 `xml:"TotalTimeSeconds"`
+			DistanceMeters   float64 `xml:"DistanceMeters"`
+			Tracks           []struct {
+				Points []struct {
+					Time     string `xml:"Time"`
+					Position *struct {
+						Lat float64 `xml:"LatitudeDegrees"`
+						Lon float64 `xml:"LongitudeDegrees"`
+					} `xml:"Position"`
+					AltitudeMeters *float64 `xml:"AltitudeMeters"`
+					DistanceMeters float64  `xml:"DistanceMeters"`
+					HeartRate      int      `xml:"HeartRateBpm>Value"`
+				} `xml:"Trackpoint"`
+			} `xml:"Track"`
+		} `xml:"Lap"`
+		Creator struct {
+			Name      string `xml:"Name"`
+			UnitID    string `xml:"UnitId"`
+			ProductID string `xml:"ProductID"`
+		} `xml:"Creator"`
+	} `xml:"Activities>Activity"`
+}
+
+func parseTCX(data []byte) (track, error) {
+	var f tcxFile
+	if err := xml.Unmarshal(data, &f); err != nil {
+		return track{}, err
+	}
+	if len(f.Activities) == 0 {
+		return track{}, ErrNoData
+	}
+
+	// A TCX file may hold several activities; we import the first, like most tools do
+	a := f.Activities[0]
+	t := track{sport: a.Sport, start: parseTime(a.ID)}
+	device := strings.TrimSpace(a.Creator.Name)
+	if a.Creator.UnitID != "" {
+		device += " " + a.Creator.UnitID
+	}
+	t.device = strings.TrimSpace(device)
+
+	for _, lap := range a.Laps {
+		if ls := parseTime(lap.StartTime); !ls.IsZero() && (t.start.IsZero() || ls.Before(t.start)) {
+			t.start = ls
+		}
+		t.totalDistanceM += lap.DistanceMeters
+		t.totalDurationS += lap.TotalTimeSeconds
+		for _, trk := range lap.Tracks {
+			for _, p := range trk.Points {
+				pt := point{Time: parseTime(p.Time), DistanceM: p.DistanceMeters, HeartRate: p.HeartRate}
+				if p.Position != nil {
+					pt.Lat, pt.Lon, pt.HasPos = p.Position.Lat, p.Position.Lon, true
+				}
+				if p.AltitudeMeters != nil {
+					pt.Elevation, pt.HasEle = *p.AltitudeMeters, true
+				}
+				t.points = append(t.points, pt)
+			}
+		}
+	}
+	return t, nil
+}
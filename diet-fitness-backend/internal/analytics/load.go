@@ -0,0 +1,321 @@
+// --- diet-fitness-backend/internal/analytics/load.go ---
+package analytics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// Thresholds used to flag overreaching
+const (
+	ACWRWarning       = 1.3 // Upper edge of the commonly cited 0.8-1.3 "sweet spot"
+	ACWRHigh          = 1.5 // Spikes above this are associated with elevated injury risk
+	MonotonyWarning   = 2.0 // Foster's monotony threshold
+	HardSetsPerWeek   = 20  // Weekly hard sets per muscle beyond which recovery usually suffers
+	WeeklySpikeFactor = 1.5 // A week more than 50% above the previous 4-week average is a spike
+	hardSetMinRPE     = 7.0 // Sets at or above this RPE count as hard sets
+	defaultRPE        = 5.0 // Assumed session RPE when nothing was reported
+	minutesPerSet     = 3   // Assumed session duration per set when no duration was logged
+	HistoryDays       = 28  // Days of history needed before the chronic load is meaningful
+	acuteDays         = 7
+)
+
+const dateLayout = "2006-01-02"
+
+// Set is a logged set reduced to what the load metrics need
+type Set struct {
+	MuscleGroup string
+	Reps        int
+	LoadKg      float64
+	RPE         float64
+}
+
+// Session is a logged session reduced to what the load metrics need
+type Session struct {
+	PerformedAt time.Time
+	SessionRPE  float64
+	DurationMin int
+	Sets        []Set
+}
+
+// Load is the session RPE training load (Foster): perceived exertion × minutes.
+// Missing values are estimated from the mean set RPE and the number of sets.
+func (s Session) Load() float64 {
+	rpe := s.SessionRPE
+	if rpe <= 0 {
+		total, n := 0.0, 0
+		for _, set := range s.Sets {
+			if set.RPE > 0 {
+				total += set.RPE
+				n++
+			}
+		}
+		rpe = defaultRPE
+		if n > 0 {
+			rpe = total / float64(n)
+		}
+	}
+	minutes := s.DurationMin
+	if minutes <= 0 {
+		minutes = len(s.Sets) * minutesPerSet
+	}
+	return rpe * float64(minutes)
+}
+
+// IsHard reports whether a set counts towards weekly hard sets; sets without an RPE are assumed to be working sets
+func (s Set) IsHard() bool {
+	return s.Reps > 0 && (s.RPE == 0 || s.RPE >= hardSetMinRPE)
+}
+
+// WeekStart returns the Monday (UTC) of the week containing t
+func WeekStart(t time.Time) time.Time {
+	d := day(t)
+	offset := (int(d.Weekday()) + 6) % 7 // Monday = 0
+	return d.AddDate(0, 0, -offset)
+}
+
+func day(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// TrainingLoad computes the weekly and daily load series between from and to (inclusive, by UTC day).
+// sessions should include the HistoryDays before from so chronic load and spike detection have a baseline.
+func TrainingLoad(sessions []Session, from, to time.Time) models.TrainingLoadReport {
+	from, to = day(from), day(to)
+	start := from.AddDate(0, 0, -HistoryDays)
+	if ws := WeekStart(start); ws.Before(start) {
+		start = ws
+	}
+
+	// Bucket everything by day first; every other series is derived from these buckets
+	days := int(to.Sub(start).Hours()/24) + 1
+	daily := make([]float64, days)
+	type weekAgg struct {
+		sessions int
+		tonnage  float64
+		hardSets map[string]int
+	}
+	weeks := map[time.Time]*weekAgg{}
+	var firstSession time.Time
+	for _, s := range sessions {
+		d := day(s.PerformedAt)
+		if d.Before(start) || d.After(to) {
+			continue
+		}
+		if firstSession.IsZero() || d.Before(firstSession) {
+			firstSession = d
+		}
+		daily[int(d.Sub(start).Hours()/24)] += s.Load()
+
+		ws := WeekStart(d)
+		agg, ok := weeks[ws]
+		if !ok {
+			agg = &weekAgg{hardSets: map[string]int{}}
+			weeks[ws] = agg
+		}
+		agg.sessions++
+		for _, set := range s.Sets {
+			agg.tonnage += float64(set.Reps) * set.LoadKg
+			if set.IsHard() {
+				muscle := set.MuscleGroup
+				if muscle == "" {
+					muscle = "other"
+				}
+				agg.hardSets[muscle]++
+			}
+		}
+	}
+
+	report := models.TrainingLoadReport{
+		From:   from.Format(dateLayout),
+		To:     to.Format(dateLayout),
+		Weekly: []models.WeeklyTrainingLoad{},
+		Daily:  []models.DailyTrainingLoad{},
+		Alerts: []models.TrainingAlert{},
+	}
+
+	// Daily series with rolling acute (7-day sum) and chronic (28-day weekly average) loads
+	previousACWR := 0.0
+	for i := range daily {
+		d := start.AddDate(0, 0, i)
+		acute := windowSum(daily, i, acuteDays)
+		chronic := windowSum(daily, i, HistoryDays) / (HistoryDays / acuteDays)
+		point := models.DailyTrainingLoad{Date: d.Format(dateLayout), Load: round2(daily[i]), Acute: round2(acute), Chronic: round2(chronic)}
+
+		var acwr float64
+		if !firstSession.IsZero() && d.Sub(firstSession) >= (HistoryDays-1)*24*time.Hour && chronic > 0 {
+			acwr = acute / chronic
+			v := round2(acwr)
+			point.ACWR = &v
+		}
+		if d.Before(from) {
+			previousACWR = acwr
+			continue
+		}
+		report.Daily = append(report.Daily, point)
+
+		// Only flag the day a spike starts, not every day it persists
+		if acwr > ACWRWarning && previousACWR <= ACWRWarning {
+			severity, threshold := "warning", ACWRWarning
+			if acwr > ACWRHigh {
+				severity, threshold = "high", ACWRHigh
+			}
+			report.Alerts = append(report.Alerts, models.TrainingAlert{
+				Date:      point.Date,
+				Metric:    "acwr",
+				Value:     round2(acwr),
+				Threshold: threshold,
+				Severity:  severity,
+				Message:   fmt.Sprintf("Acute:chronic workload ratio is %.2f; this week's load is well above what you are used to.", acwr),
+			})
+		}
+		previousACWR = acwr
+	}
+
+	// Weekly series; weeks before from only serve as the baseline for spike detection
+	var history []models.WeeklyTrainingLoad
+	for ws := WeekStart(start); !ws.After(to); ws = ws.AddDate(0, 0, 7) {
+		week := models.WeeklyTrainingLoad{WeekStart: ws.Format(dateLayout), HardSets: map[string]int{}}
+		if agg, ok := weeks[ws]; ok {
+			week.Sessions = agg.sessions
+			week.TonnageKg = round2(agg.tonnage)
+			week.HardSets = agg.hardSets
+		}
+
+		// Monotony over the days of the week that have elapsed (and are inside the analysed range)
+		var loads []float64
+		for d := ws; d.Before(ws.AddDate(0, 0, 7)) && !d.After(to); d = d.AddDate(0, 0, 1) {
+			if d.Before(start) {
+				continue
+			}
+			loads = append(loads, daily[int(d.Sub(start).Hours()/24)])
+		}
+		total, mean, sd := stats(loads)
+		week.SessionLoad = round2(total)
+		if sd > 0 {
+			week.Monotony = round2(mean / sd)
+			week.Strain = round2(total * mean / sd)
+		}
+
+		if !ws.Before(WeekStart(from)) {
+			report.Weekly = append(report.Weekly, week)
+			report.Alerts = append(report.Alerts, weeklyAlerts(week, lastN(history, 4))...)
+		}
+		history = append(history, week)
+	}
+
+	sort.SliceStable(report.Alerts, func(i, j int) bool { return report.Alerts[i].Date < report.Alerts[j].Date })
+	return report
+}
+
+// weeklyAlerts flags monotony, strain spikes and excessive or spiking hard sets per muscle for one week
+func weeklyAlerts(week models.WeeklyTrainingLoad, previous []models.WeeklyTrainingLoad) []models.TrainingAlert {
+	var alerts []models.TrainingAlert
+	if week.Sessions > 1 && week.Monotony > MonotonyWarning {
+		alerts = append(alerts, models.TrainingAlert{
+			Date:      week.WeekStart,
+			Metric:    "monotony",
+			Value:     week.Monotony,
+			Threshold: MonotonyWarning,
+			Severity:  "warning",
+			Message:   fmt.Sprintf("Training monotony of %.2f; vary hard and easy days to recover better.", week.Monotony),
+		})
+	}
+
+	if len(previous) > 0 {
+		strainAvg := 0.0
+		for _, p := range previous {
+			strainAvg += p.Strain
+		}
+		strainAvg /= float64(len(previous))
+		if strainAvg > 0 && week.Strain > strainAvg*WeeklySpikeFactor {
+			alerts = append(alerts, models.TrainingAlert{
+				Date:      week.WeekStart,
+				Metric:    "strain",
+				Value:     week.Strain,
+				Threshold: round2(strainAvg * WeeklySpikeFactor),
+				Severity:  "warning",
+				Message:   fmt.Sprintf("Weekly strain of %.0f is more than %.0f%% above your recent average.", week.Strain, (WeeklySpikeFactor-1)*100),
+			})
+		}
+	}
+
+	muscles := make([]string, 0, len(week.HardSets))
+	for m := range week.HardSets {
+		muscles = append(muscles, m)
+	}
+	sort.Strings(muscles)
+	for _, m := range muscles {
+		sets := week.HardSets[m]
+		if sets > HardSetsPerWeek {
+			alerts = append(alerts, models.TrainingAlert{
+				Date:      week.WeekStart,
+				Metric:    "hard_sets:" + m,
+				Value:     float64(sets),
+				Threshold: HardSetsPerWeek,
+				Severity:  "high",
+				Message:   fmt.Sprintf("%d hard sets for %s this week exceeds %d.", sets, m, HardSetsPerWeek),
+			})
+			continue
+		}
+		if len(previous) == 0 {
+			continue
+		}
+		avg := 0.0
+		for _, p := range previous {
+			avg += float64(p.HardSets[m])
+		}
+		avg /= float64(len(previous))
+		if avg >= 1 && float64(sets) > avg*WeeklySpikeFactor {
+			alerts = append(alerts, models.TrainingAlert{
+				Date:      week.WeekStart,
+				Metric:    "hard_sets:" + m,
+				Value:     float64(sets),
+				Threshold: round2(avg * WeeklySpikeFactor),
+				Severity:  "warning",
+				Message:   fmt.Sprintf("%d hard sets for %s is a jump from your recent average of %.1f.", sets, m, avg),
+			})
+		}
+	}
+	return alerts
+}
+
+// windowSum sums the n values ending at index i
+func windowSum(values []float64, i, n int) float64 {
+	total := 0.0
+	for j := max(0, i-n+1); j <= i; j++ {
+		total += values[j]
+	}
+	return total
+}
+
+func stats(values []float64) (total, mean, sd float64) {
+	if len(values) == 0 {
+		return 0, 0, 0
+	}
+	for _, v := range values {
+		total += v
+	}
+	mean = total / float64(len(values))
+	for _, v := range values {
+		sd += (v - mean) * (v - mean)
+	}
+	sd = math.Sqrt(sd / float64(len(values)))
+	return total, mean, sd
+}
+
+func lastN(weeks []models.WeeklyTrainingLoad, n int) []models.WeeklyTrainingLoad {
+	if len(weeks) <= n {
+		return weeks
+	}
+	return weeks[len(weeks)-n:]
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
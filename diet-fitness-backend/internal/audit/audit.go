@@ -0,0 +1,97 @@
+// --- diet-fitness-backend/internal/audit/audit.go ---
+// Package audit records who did what: sign-ins, credential and role changes, plan generation,
+// uploads, deletions and data exports. Events go to the append-only audit_log table with the actor,
+// the client address and the request ID, and administrators read them through /api/admin/audit.
+// Secrets and free text such as prompts are never recorded.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/logging"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+)
+
+// Actions recorded in the audit log
+const (
+	ActionLogin            = "login"                      // A token was issued; Detail is the sign-in method
+	ActionLoginFailed      = "login_failed"               // Detail is the reason, e.g. bad_password or bad_mfa_code
+	ActionPasswordChanged  = "password_changed"           // By the signed-in user
+	ActionPasswordReset    = "password_reset"             // Through an emailed reset link
+	ActionRoleGranted      = "role_granted"               // Detail is the role
+	ActionRoleRevoked      = "role_revoked"               // Detail is the role
+	ActionPlanGenerated    = "plan_generated"             // Target is the new active plan
+	ActionPlanUpdated      = "plan_updated"               // The user edited their active plan; target is the plan
+	ActionPlanAssigned     = "plan_assigned"              // A coach set a client's plan; target is the plan, Detail the client
+	ActionUpload           = "upload"                     // Target is the stored upload
+	ActionAccountDeleted   = "account_deleted"            // Target is the deleted user
+	ActionSessionRevoked   = "session_revoked"            // A signed-in device was signed out
+	ActionAPIKeyCreated    = "api_key_created"            // Target is the key; Detail is its scopes
+	ActionAPIKeyRevoked    = "api_key_revoked"            // Target is the key
+	ActionIdentityUnlinked = "identity_unlinked"          // Target is the provider account that was removed
+	ActionMFAEnrollStarted = "mfa_enroll_started"         // A new TOTP secret was issued, not yet confirmed
+	ActionMFAEnabled       = "mfa_enabled"                // Two-factor authentication was turned on
+	ActionMFADisabled      = "mfa_disabled"               // Two-factor authentication was turned off
+	ActionCodesRegenerated = "recovery_codes_regenerated" // New recovery codes replaced the old ones
+	ActionExportRequested  = "export_requested"           // Target is the export job
+	ActionExportDownloaded = "export_downloaded"          // Target is the export job
+	ActionDiaryExported    = "diary_exported"             // The meal diary was downloaded as CSV
+	ActionKeyRotated       = "signing_key_rotated"        // Target is the new key; Detail is "retired_previous" when older keys were dropped
+	ActionBackupCreated    = "backup_created"             // An admin took a database backup; target is the backup file
+	ActionImportRequested  = "import_requested"           // Target is the health import job; Detail is the source
+	ActionActivityImported = "activity_imported"          // Target is the new activity; Detail is the file format
+	ActionDiaryImported    = "diary_imported"             // Detail is the CSV format and the entries written and replaced
+)
+
+// Types of target an event can name
+const (
+	TargetUser     = "user"
+	TargetPlan     = "plan"
+	TargetUpload   = "upload"
+	TargetSession  = "session"
+	TargetAPIKey   = "api_key"
+	TargetIdentity = "identity"
+	TargetJob      = "job"
+	TargetKey      = "signing_key"
+	TargetBackup   = "backup"
+	TargetActivity = "activity"
+)
+
+// Log records events about HTTP requests
+type Log struct {
+	events     store.Audit
+	trustProxy bool
+}
+
+// New creates a Log writing to events. Client addresses are resolved like the login throttle does,
+// honouring X-Forwarded-For only with TRUST_PROXY_HEADERS.
+func New(events store.Audit, cfg *config.Config) *Log {
+	return &Log{events: events, trustProxy: cfg.TrustProxyHeaders}
+}
+
+// Record appends e, filling in the client address, the request ID and, unless e names one, the
+// signed-in actor. The action has already happened by the time it is recorded, so a failure is
+// logged rather than returned.
+func (l *Log) Record(r *http.Request, e models.AuditEvent) {
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok && e.ActorID == nil {
+		actorID := principal.UserID
+		e.ActorID = &actorID
+		e.AuthMethod = string(principal.Method)
+	}
+	e.IP = auth.ClientIP(r, l.trustProxy)
+	e.RequestID = logging.RequestID(r.Context())
+	// Recorded even if the client went away mid-request
+	if err := l.events.Record(context.WithoutCancel(r.Context()), &e); err != nil {
+		slog.ErrorContext(r.Context(), "Error recording audit event", "action", e.Action, "err", err)
+	}
+}
+
+// Actor returns a user ID as an event's actor, for requests made before anyone is signed in
+func Actor(userID int) *int {
+	return &userID
+}
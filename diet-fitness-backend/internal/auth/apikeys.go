@@ -0,0 +1,159 @@
+// --- diet-fitness-backend/internal/auth/apikeys.go ---
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// APIKeyPrefix starts every personal API key, so keys are told apart from JWTs and easy to spot in leaks
+const APIKeyPrefix = "fpk_"
+
+// apiKeyTouchInterval limits how often last_used_at is written
+const apiKeyTouchInterval = time.Minute
+
+// Scopes an API key can be granted
+const (
+	ScopeWorkoutsRead    = "workouts:read"
+	ScopeWorkoutsWrite   = "workouts:write"
+	ScopeActivitiesRead  = "activities:read"
+	ScopeActivitiesWrite = "activities:write"
+	ScopeDiaryRead       = "diary:read"
+	ScopeDiaryWrite      = "diary:write"
+	ScopeWeightsRead     = "weights:read"
+	ScopeWeightsWrite    = "weights:write"
+	ScopePlanRead        = "plan:read"
+	ScopePlanWrite       = "plan:write"
+	ScopeProfileRead     = "profile:read"
+	ScopeProfileWrite    = "profile:write"
+)
+
+// ScopeDescriptions documents each API key scope
+var ScopeDescriptions = map[string]string{
+	ScopeWorkoutsRead:    "Read logged workouts, training load and session comments",
+	ScopeWorkoutsWrite:   "Log workouts and comment on sessions",
+	ScopeActivitiesRead:  "Read cardio activities",
+	ScopeActivitiesWrite: "Create and import cardio activities",
+	ScopeDiaryRead:       "Read and export the meal diary and search foods",
+	ScopeDiaryWrite:      "Add and import diary entries",
+	ScopeWeightsRead:     "Read body weight measurements",
+	ScopeWeightsWrite:    "Record body weight measurements",
+	ScopePlanRead:        "Read the active workout plan",
+	ScopePlanWrite:       "Generate plans and change plan exercises",
+	ScopeProfileRead:     "Read the physiological profile and heart-rate zones",
+	ScopeProfileWrite:    "Update the physiological profile",
+}
+
+// apiKeyRoutes lists the endpoints API keys may call, by method and route template, with the scope each
+// needs. Everything else, including account, security and key management, takes a login token.
+var apiKeyRoutes = map[string]string{
+	"GET /api/workouts":                       ScopeWorkoutsRead,
+	"POST /api/workouts":                      ScopeWorkoutsWrite,
+	"GET /api/analytics/training-load":        ScopeWorkoutsRead,
+	"GET /api/workouts/{id:[0-9]+}/comments":  ScopeWorkoutsRead,
+	"POST /api/workouts/{id:[0-9]+}/comments": ScopeWorkoutsWrite,
+	"GET /api/activities":                     ScopeActivitiesRead,
+	"GET /api/activities/{id:[0-9]+}":         ScopeActivitiesRead,
+	"POST /api/activities":                    ScopeActivitiesWrite,
+	"POST /api/activities/import":             ScopeActivitiesWrite,
+	"GET /api/diary":                          ScopeDiaryRead,
+	"GET /api/diary/export":                   ScopeDiaryRead,
+	"GET /api/foods":                          ScopeDiaryRead,
+	"POST /api/diary":                         ScopeDiaryWrite,
+	"POST /api/diary/import":                  ScopeDiaryWrite,
+	"GET /api/weights":                        ScopeWeightsRead,
+	"POST /api/weights":                       ScopeWeightsWrite,
+	"GET /api/plan":                           ScopePlanRead,
+	"POST /api/generate-plan":                 ScopePlanWrite,
+	"PUT /api/plan/exercises":                 ScopePlanWrite,
+	"GET /api/profile":                        ScopeProfileRead,
+	"GET /api/profile/heart-rate-zones":       ScopeProfileRead,
+	"PUT /api/profile":                        ScopeProfileWrite,
+}
+
+// NewAPIKey returns a new key, the short prefix shown in key listings and the hash to store
+func NewAPIKey() (key, prefix, hash string, err error) {
+	token, _, err := NewOpaqueToken()
+	if err != nil {
+		return "", "", "", err
+	}
+	key = APIKeyPrefix + token
+	return key, key[:len(APIKeyPrefix)+8], HashToken(key), nil
+}
+
+// APIKeyMiddleware authenticates requests bearing a personal API key and hands every other request
+// to fallback (the JWT middleware). Key requests only reach endpoints their scopes cover, act with
+// the plain user role and never carry a login session.
+func APIKeyMiddleware(db *sql.DB, fallback mux.MiddlewareFunc) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		jwtHandler := fallback(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			parts := strings.Split(r.Header.Get("Authorization"), " ")
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" || !strings.HasPrefix(parts[1], APIKeyPrefix) {
+				jwtHandler.ServeHTTP(w, r)
+				return
+			}
+
+			var (
+				keyID, userID       int
+				email, scopes       string
+				expiresAt, lastUsed sql.NullTime
+			)
+			err := db.QueryRow(`SELECT k.id, k.user_id, u.email, k.scopes, k.expires_at, k.last_used_at
+				FROM api_keys k JOIN users u ON u.id = k.user_id WHERE k.key_hash = ? AND k.revoked_at IS NULL`,
+				HashToken(parts[1])).Scan(&keyID, &userID, &email, &scopes, &expiresAt, &lastUsed)
+			if err == sql.ErrNoRows {
+				writeError(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error loading API key", "err", err)
+				writeError(w, "Server error", http.StatusInternalServerError)
+				return
+			}
+			now := time.Now().UTC()
+			if expiresAt.Valid && now.After(expiresAt.Time) {
+				writeError(w, "API key has expired", http.StatusUnauthorized)
+				return
+			}
+
+			principal := &Principal{
+				UserID:   userID,
+				Email:    email,
+				Access:   Access{Roles: []string{RoleUser}},
+				APIKeyID: keyID,
+				Method:   AuthMethodAPIKey,
+				Scopes:   strings.Split(scopes, ","),
+			}
+
+			needed, allowed := "", false
+			if route := mux.CurrentRoute(r); route != nil {
+				if tmpl, err := route.GetPathTemplate(); err == nil {
+					needed, allowed = apiKeyRoutes[r.Method+" "+tmpl]
+				}
+			}
+			if !allowed {
+				writeError(w, "API keys can't be used for this endpoint; log in instead", http.StatusForbidden)
+				return
+			}
+			if !principal.HasScope(needed) {
+				writeError(w, fmt.Sprintf("API key lacks the %s scope", needed), http.StatusForbidden)
+				return
+			}
+
+			if !lastUsed.Valid || now.Sub(lastUsed.Time) >= apiKeyTouchInterval {
+				if _, err := db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", now, keyID); err != nil {
+					slog.ErrorContext(r.Context(), "Error updating API key", "api_key_id", keyID, "err", err)
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// --- diet-fitness-backend/internal/auth/email.go ---
+package auth
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// ErrInvalidEmail is returned for strings that aren't a plain, deliverable-looking email address
+var ErrInvalidEmail = errors.New("email address is invalid")
+
+// NormalizeEmail validates an address and returns it trimmed and lower-cased, so that
+// "Jane@Example.com " and "jane@example.com" are the same account.
+// Display names ("Jane <jane@example.com>") and addresses without a dotted domain are rejected.
+func NormalizeEmail(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || len(s) > 254 {
+		return "", ErrInvalidEmail
+	}
+	addr, err := mail.ParseAddress(s)
+	if err != nil || addr.Name != "" || addr.Address != s {
+		return "", ErrInvalidEmail
+	}
+	at := strings.LastIndex(s, "@")
+	local, domain := s[:at], s[at+1:]
+	if len(local) > 64 || !strings.Contains(domain, ".") || strings.HasPrefix(domain, ".") ||
+		strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") || strings.HasPrefix(domain, "[") {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(s), nil
+}
@@ -2,9 +2,9 @@
 package auth
 
 import (
-	"context"
+	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 	"time"
@@ -15,17 +15,24 @@ import (
 
 // Claims represents the JWT claims
 type Claims struct {
-	UserID int    `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    int    `json:"user_id"`
+	Email     string `json:"email"`
+	SessionID int    `json:"sid,omitempty"` // Row in user_sessions; signing the session out revokes the token
+	Access           // Roles and permissions as of login
 	jwt.RegisteredClaims
 }
 
-// GenerateJWT generates a new JWT token
-func GenerateJWT(userID int, email, secret string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour) // Token valid for 24 hours
+// TokenTTL is how long a JWT stays valid
+const TokenTTL = 24 * time.Hour
+
+// GenerateJWT generates a new JWT token for a login session, carrying the user's roles and permissions
+func (m *KeyManager) GenerateJWT(userID int, email string, sessionID int, access Access) (string, error) {
+	expirationTime := time.Now().Add(TokenTTL)
 	claims := &Claims{
-		UserID: userID,
-		Email:  email,
+		UserID:    userID,
+		Email:     email,
+		SessionID: sessionID,
+		Access:    access,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -35,71 +42,95 @@ func GenerateJWT(userID int, email, secret string) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(secret))
+	tokenString, err := m.Sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("error signing token: %w", err)
 	}
 	return tokenString, nil
 }
 
-// JWTMiddleware validates the JWT token from the Authorization header
-func JWTMiddleware(jwtSecret string) mux.MiddlewareFunc {
+// JWTMiddleware validates the JWT token from the Authorization header against the key manager's keys.
+// Tokens of deleted accounts, of signed-out sessions and tokens issued before the user's last password
+// reset are rejected.
+func JWTMiddleware(db *sql.DB, keys *KeyManager) mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
 			if authHeader == "" {
-				http.Error(w, `{"message": "Authorization header missing"}`, http.StatusUnauthorized)
+				writeError(w, "Authorization header missing", http.StatusUnauthorized)
 				return
 			}
 
 			// Expecting "Bearer <token>"
 			parts := strings.Split(authHeader, " ")
 			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				http.Error(w, `{"message": "Invalid Authorization header format"}`, http.StatusUnauthorized)
+				writeError(w, "Invalid Authorization header format", http.StatusUnauthorized)
 				return
 			}
 			tokenString := parts[1]
 
 			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return []byte(jwtSecret), nil
-			})
+			token, err := jwt.ParseWithClaims(tokenString, claims, keys.Keyfunc)
 
 			if err != nil {
 				if err == jwt.ErrSignatureInvalid {
-					http.Error(w, `{"message": "Invalid token signature"}`, http.StatusUnauthorized)
+					writeError(w, "Invalid token signature", http.StatusUnauthorized)
 					return
 				}
-				log.Printf("JWT parsing error: %v", err) // Log the specific error for debugging
-				http.Error(w, `{"message": "Invalid or expired token"}`, http.StatusUnauthorized)
+				slog.DebugContext(r.Context(), "Rejected JWT", "err", err)
+				writeError(w, "Invalid or expired token", http.StatusUnauthorized)
 				return
 			}
 
 			if !token.Valid {
-				http.Error(w, `{"message": "Invalid token"}`, http.StatusUnauthorized)
+				writeError(w, "Invalid token", http.StatusUnauthorized)
 				return
 			}
 
-			// Add user ID and email to the request context for subsequent handlers
-			ctx := context.WithValue(r.Context(), "userID", claims.UserID)
-			ctx = context.WithValue(ctx, "userEmail", claims.Email)
+			// A signed token outlives its account and password changes; check both
+			var validAfter sql.NullTime
+			if err := db.QueryRow("SELECT tokens_valid_after FROM users WHERE id = ?", claims.UserID).Scan(&validAfter); err != nil {
+				if err != sql.ErrNoRows {
+					slog.ErrorContext(r.Context(), "Error checking token user", "user_id", claims.UserID, "err", err)
+					writeError(w, "Server error", http.StatusInternalServerError)
+					return
+				}
+				writeError(w, "Account no longer exists", http.StatusUnauthorized)
+				return
+			}
+			if validAfter.Valid && (claims.IssuedAt == nil || claims.IssuedAt.Time.Before(validAfter.Time)) {
+				writeError(w, "Token has been revoked; please log in again", http.StatusUnauthorized)
+				return
+			}
+
+			// Tokens issued before sessions were recorded carry no sid; they simply run out
+			if claims.SessionID != 0 {
+				if err := CheckSession(db, claims.UserID, claims.SessionID); err != nil {
+					if err == ErrSessionRevoked {
+						writeError(w, "Session has been signed out; please log in again", http.StatusUnauthorized)
+						return
+					}
+					slog.ErrorContext(r.Context(), "Error checking session", "session_id", claims.SessionID, "err", err)
+					writeError(w, "Server error", http.StatusInternalServerError)
+					return
+				}
+			}
+
+			var signedInAt time.Time
+			if claims.IssuedAt != nil {
+				signedInAt = claims.IssuedAt.Time
+			}
+
+			// Hand the identity to subsequent handlers
+			ctx := WithPrincipal(r.Context(), &Principal{
+				UserID:     claims.UserID,
+				Email:      claims.Email,
+				Access:     claims.Access,
+				SessionID:  claims.SessionID,
+				Method:     AuthMethodToken,
+				SignedInAt: signedInAt,
+			})
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
-
-// GetUserIDFromContext retrieves the user ID from the request context
-func GetUserIDFromContext(r *http.Request) (int, bool) {
-	userID, ok := r.Context().Value("userID").(int)
-	return userID, ok
-}
-
-// GetUserEmailFromContext retrieves the user email from the request context
-func GetUserEmailFromContext(r *http.Request) (string, bool) {
-	userEmail, ok := r.Context().Value("userEmail").(string)
-	return userEmail, ok
-}
@@ -0,0 +1,367 @@
+// --- diet-fitness-backend/internal/auth/keys.go ---
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing algorithms accepted for JWT_ALGORITHM
+const (
+	AlgEdDSA = "EdDSA"
+	AlgRS256 = "RS256"
+	AlgHS256 = "HS256" // Shared secret; no key rotation and nothing to publish
+)
+
+const keyCheckInterval = 5 * time.Minute
+
+// ErrSharedSecret is returned by Rotate when tokens are signed with JWT_SECRET
+var ErrSharedSecret = errors.New("HS256 uses a shared secret; change JWT_SECRET to rotate it")
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"` // OKP keys
+	X         string `json:"x,omitempty"`   // OKP keys
+	N         string `json:"n,omitempty"`   // RSA keys
+	E         string `json:"e,omitempty"`   // RSA keys
+}
+
+// JWKSet is the document served at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+type signingKey struct {
+	kid        string
+	alg        string
+	private    crypto.Signer
+	activeFrom time.Time // New keys are published before they sign, so verifiers caching the JWKS already know them
+	createdAt  time.Time
+}
+
+// KeyManager signs and verifies JWTs. With an asymmetric algorithm it keeps its keys in the
+// signing_keys table, so every instance sharing the database uses the same set. The newest active
+// key signs; older keys keep verifying until the tokens they signed have expired.
+type KeyManager struct {
+	db          *sql.DB
+	alg         string
+	rotateEvery time.Duration
+	secret      []byte // HS256 signing secret, or the secret legacy tokens were signed with
+	acceptHS256 bool   // Verify HS256 tokens without a kid, for a smooth switch from the shared secret
+
+	mu   sync.RWMutex
+	keys []*signingKey // Sorted by activeFrom
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewKeyManager loads the signing keys, creating the first one if needed, and starts the rotation
+// schedule. Call Close on shutdown.
+func NewKeyManager(db *sql.DB, alg string, rotateEvery time.Duration, secret string, acceptHS256 bool) (*KeyManager, error) {
+	if alg != AlgEdDSA && alg != AlgRS256 && alg != AlgHS256 {
+		return nil, fmt.Errorf("unknown JWT algorithm %q; use %s, %s or %s", alg, AlgEdDSA, AlgRS256, AlgHS256)
+	}
+	if rotateEvery < time.Hour {
+		return nil, fmt.Errorf("JWT key rotation period must be at least an hour")
+	}
+	m := &KeyManager{db: db, alg: alg, rotateEvery: rotateEvery, secret: []byte(secret), acceptHS256: acceptHS256 || alg == AlgHS256}
+	if alg == AlgHS256 {
+		return m, nil
+	}
+
+	if err := m.refresh(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.done = make(chan struct{})
+	go m.schedule(ctx)
+	return m, nil
+}
+
+// Close stops the rotation schedule
+func (m *KeyManager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+}
+
+// Rotate creates a new signing key that is used right away. Previous keys still verify the tokens
+// they signed unless retire is set: after a suspected leak, retire deletes every other key, so they
+// leave the JWKS and stop verifying (on other instances at their next check, within minutes), and
+// revokes all tokens issued so far, signing everyone out.
+func (m *KeyManager) Rotate(retire bool) (string, error) {
+	if m.alg == AlgHS256 {
+		return "", ErrSharedSecret
+	}
+	kid, err := m.createKey(time.Now().UTC())
+	if err != nil {
+		return "", err
+	}
+	if retire {
+		if err := m.retireAllBut(kid); err != nil {
+			return "", err
+		}
+	}
+	return kid, m.refresh()
+}
+
+// retireAllBut deletes every signing key except kid and revokes the tokens they may have signed
+func (m *KeyManager) retireAllBut(kid string) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error retiring signing keys: %w", err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec("DELETE FROM signing_keys WHERE kid <> ?", kid); err != nil {
+		return fmt.Errorf("error retiring signing keys: %w", err)
+	}
+	if err := RevokeAllTokens(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error retiring signing keys: %w", err)
+	}
+	return nil
+}
+
+// Sign returns the signed, compact form of the claims
+func (m *KeyManager) Sign(claims jwt.Claims) (string, error) {
+	if m.alg == AlgHS256 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(m.secret)
+	}
+
+	key := m.currentKey(time.Now())
+	if key == nil {
+		return "", fmt.Errorf("no active signing key")
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.alg), claims)
+	token.Header["kid"] = key.kid
+	return token.SignedString(key.private)
+}
+
+// Keyfunc picks the verification key named by the token's kid header
+func (m *KeyManager) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok && m.acceptHS256 {
+			return m.secret, nil
+		}
+		return nil, fmt.Errorf("token has no key ID")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.kid == kid {
+			if token.Method.Alg() != k.alg {
+				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+			}
+			return k.private.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWKS returns the public keys that verify current tokens, including keys about to start signing
+func (m *KeyManager) JWKS() JWKSet {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range m.keys {
+		jwk := JWK{KeyID: k.kid, Algorithm: k.alg, Use: "sig"}
+		switch pub := k.private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve, jwk.X = "OKP", "Ed25519", base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func (m *KeyManager) schedule(ctx context.Context) {
+	defer close(m.done)
+	ticker := time.NewTicker(keyCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.refresh(); err != nil {
+				slog.ErrorContext(ctx, "Error rotating JWT signing keys", "err", err)
+			}
+		}
+	}
+}
+
+// refresh reloads the keys (other instances may have rotated), creates the next key once the
+// current one is due for rotation and deletes keys no unexpired token can reference
+func (m *KeyManager) refresh() error {
+	keys, err := m.loadKeys()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+
+	// Publish the successor ahead of time so verifiers refreshing their JWKS cache pick it up first
+	prepublish := time.Hour
+	if m.rotateEvery/2 < prepublish {
+		prepublish = m.rotateEvery / 2
+	}
+	var newest *signingKey
+	if len(keys) > 0 {
+		newest = keys[len(keys)-1]
+	}
+	switch {
+	case newest == nil || newest.alg != m.alg:
+		// First start, or JWT_ALGORITHM changed: sign with the new key straight away
+		if _, err := m.createKey(now); err != nil {
+			return err
+		}
+	case !newest.activeFrom.Add(m.rotateEvery - prepublish).After(now):
+		if _, err := m.createKey(newest.activeFrom.Add(m.rotateEvery)); err != nil {
+			return err
+		}
+	default:
+		m.setKeys(keys, now)
+		return nil
+	}
+
+	keys, err = m.loadKeys()
+	if err != nil {
+		return err
+	}
+	m.setKeys(keys, now)
+	return nil
+}
+
+// setKeys keeps the keys still needed for verification and prunes the rest from the database
+func (m *KeyManager) setKeys(keys []*signingKey, now time.Time) {
+	var keep []*signingKey
+	for i, k := range keys {
+		// A key stops signing when its successor activates; its tokens live for TokenTTL after that
+		if i+1 < len(keys) && keys[i+1].activeFrom.Add(TokenTTL+time.Minute).Before(now) {
+			if _, err := m.db.Exec("DELETE FROM signing_keys WHERE kid = ?", k.kid); err != nil {
+				slog.Error("Error deleting expired JWT key", "kid", k.kid, "err", err)
+			}
+			continue
+		}
+		keep = append(keep, k)
+	}
+	m.mu.Lock()
+	m.keys = keep
+	m.mu.Unlock()
+}
+
+// currentKey is the most recently activated key
+func (m *KeyManager) currentKey(now time.Time) *signingKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var current *signingKey
+	for _, k := range m.keys {
+		if !k.activeFrom.After(now) {
+			current = k
+		}
+	}
+	return current
+}
+
+func (m *KeyManager) createKey(activeFrom time.Time) (string, error) {
+	var (
+		private crypto.Signer
+		err     error
+	)
+	switch m.alg {
+	case AlgEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	case AlgRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error generating %s key: %w", m.alg, err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", fmt.Errorf("error encoding %s key: %w", m.alg, err)
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("error generating key ID: %w", err)
+	}
+	kid := activeFrom.Format("20060102T1504") + "-" + hex.EncodeToString(suffix)
+	_, err = m.db.Exec("INSERT INTO signing_keys (kid, algorithm, private_key, active_from, created_at) VALUES (?, ?, ?, ?, ?)",
+		kid, m.alg, string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), activeFrom, time.Now().UTC())
+	if err != nil {
+		return "", fmt.Errorf("error storing signing key: %w", err)
+	}
+	slog.Info("Created JWT signing key", "kid", kid, "alg", m.alg, "active_from", activeFrom.Format(time.RFC3339))
+	return kid, nil
+}
+
+func (m *KeyManager) loadKeys() ([]*signingKey, error) {
+	rows, err := m.db.Query("SELECT kid, algorithm, private_key, active_from, created_at FROM signing_keys")
+	if err != nil {
+		return nil, fmt.Errorf("error loading signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*signingKey
+	for rows.Next() {
+		var (
+			k       signingKey
+			encoded string
+		)
+		if err := rows.Scan(&k.kid, &k.alg, &encoded, &k.activeFrom, &k.createdAt); err != nil {
+			return nil, fmt.Errorf("error loading signing keys: %w", err)
+		}
+		block, _ := pem.Decode([]byte(encoded))
+		if block == nil {
+			return nil, fmt.Errorf("signing key %s is not PEM encoded", k.kid)
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding signing key %s: %w", k.kid, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("signing key %s can't sign", k.kid)
+		}
+		k.private = signer
+		keys = append(keys, &k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading signing keys: %w", err)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].activeFrom.Before(keys[j].activeFrom) })
+	return keys, nil
+}
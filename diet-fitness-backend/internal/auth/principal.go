@@ -0,0 +1,78 @@
+// --- diet-fitness-backend/internal/auth/principal.go ---
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"diet-fitness-backend/internal/logging"
+	"diet-fitness-backend/internal/models"
+)
+
+// AuthMethod records how a request proved who it acts for
+type AuthMethod string
+
+const (
+	AuthMethodToken  AuthMethod = "token"   // A login JWT
+	AuthMethodAPIKey AuthMethod = "api_key" // A personal API key
+)
+
+// Principal is the authenticated identity behind a request. Every authentication middleware stores
+// one, so handlers don't need to know whether a token or an API key was used.
+type Principal struct {
+	UserID     int
+	Email      string
+	Access     Access     // Roles and the permissions they grant
+	SessionID  int        // Login session of the token; 0 for API keys and tokens issued before sessions
+	APIKeyID   int        // Key that authenticated the request; 0 for tokens
+	Method     AuthMethod // How the request authenticated
+	Scopes     []string   // API key scopes; nil for login tokens, which aren't limited by scope
+	SignedInAt time.Time  // When the login token was issued; zero for API keys
+}
+
+// HasScope reports whether the principal may use an API key scope. Login tokens may use them all.
+func (p *Principal) HasScope(scope string) bool {
+	if p.Method != AuthMethodAPIKey {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// principalKey is unexported so only this package can set or replace the principal
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the request's principal, if it was authenticated
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// RequirePrincipal returns the request's principal. If there is none, which means the route was
+// registered without an authentication middleware, it writes a 401 response and returns false.
+func RequirePrincipal(w http.ResponseWriter, r *http.Request) (*Principal, bool) {
+	p, ok := PrincipalFromContext(r.Context())
+	if !ok {
+		writeError(w, "Authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+	return p, true
+}
+
+// writeError writes a JSON error response in the shape the handlers use, with the request ID
+func writeError(w http.ResponseWriter, message string, code int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(models.ErrorResponse{Message: message, RequestID: w.Header().Get(logging.RequestIDHeader)})
+}
@@ -0,0 +1,158 @@
+// --- diet-fitness-backend/internal/auth/roles.go ---
+package auth
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// Roles. Every account implicitly holds RoleUser; the others are granted in user_roles.
+const (
+	RoleUser  = "user"
+	RoleCoach = "coach"
+	RoleAdmin = "admin"
+)
+
+// Permissions checked by RequirePermission
+const (
+	PermUsersRead     = "users:read"     // List accounts and their roles
+	PermRolesAssign   = "roles:assign"   // Grant and revoke roles
+	PermClientsManage = "clients:manage" // Invite and work with coaching clients
+	PermPlansAssign   = "plans:assign"   // Assign workout plans to clients
+	PermBackupsManage = "backups:manage" // Take and list database backups
+	PermAuditRead     = "audit:read"     // Read the audit log
+	PermKeysRotate    = "keys:rotate"    // Rotate the JWT signing key
+)
+
+// RoleDefinition is a role with its default permissions
+type RoleDefinition struct {
+	Name        string
+	Description string
+	Permissions []string
+}
+
+// DefaultRoles are seeded at startup. Default grants are restored on every start;
+// extra grants added to role_permissions by hand are kept.
+var DefaultRoles = []RoleDefinition{
+	{RoleUser, "Every account; manages its own data", nil},
+	{RoleCoach, "Works with clients who accept an invitation", []string{PermClientsManage, PermPlansAssign}},
+	{RoleAdmin, "Administers accounts and roles", []string{PermUsersRead, PermRolesAssign, PermClientsManage, PermPlansAssign, PermBackupsManage, PermAuditRead, PermKeysRotate}},
+}
+
+// PermissionDescriptions documents each permission in the permissions table
+var PermissionDescriptions = map[string]string{
+	PermUsersRead:     "List accounts and their roles",
+	PermRolesAssign:   "Grant and revoke roles",
+	PermClientsManage: "Invite and work with coaching clients",
+	PermPlansAssign:   "Assign workout plans to clients",
+	PermBackupsManage: "Take and list database backups",
+	PermAuditRead:     "Read the audit log",
+	PermKeysRotate:    "Rotate the JWT signing key",
+}
+
+// Access is what a user may do; it is embedded in their JWT at login
+type Access struct {
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+}
+
+// HasRole reports whether the access includes any of the roles
+func (a Access) HasRole(roles ...string) bool {
+	for _, have := range a.Roles {
+		for _, want := range roles {
+			if have == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPermission reports whether the access includes the permission
+func (a Access) HasPermission(perm string) bool {
+	for _, have := range a.Permissions {
+		if have == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadAccess reads a user's roles and the union of their permissions
+func LoadAccess(db *sql.DB, userID int) (Access, error) {
+	access := Access{Roles: []string{RoleUser}}
+	rows, err := db.Query(`SELECT r.name FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+		WHERE ur.user_id = ? AND r.name <> ? ORDER BY r.name`, userID, RoleUser)
+	if err != nil {
+		return access, fmt.Errorf("error loading roles: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return access, fmt.Errorf("error loading roles: %w", err)
+		}
+		access.Roles = append(access.Roles, name)
+	}
+	if err := rows.Err(); err != nil {
+		return access, fmt.Errorf("error loading roles: %w", err)
+	}
+
+	rows, err = db.Query(`SELECT DISTINCT p.name FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = ? OR r.id IN (SELECT role_id FROM user_roles WHERE user_id = ?)
+		ORDER BY p.name`, RoleUser, userID)
+	if err != nil {
+		return access, fmt.Errorf("error loading permissions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return access, fmt.Errorf("error loading permissions: %w", err)
+		}
+		access.Permissions = append(access.Permissions, name)
+	}
+	if err := rows.Err(); err != nil {
+		return access, fmt.Errorf("error loading permissions: %w", err)
+	}
+	return access, nil
+}
+
+// RequireRole only lets through users holding one of the roles. Use it after the authentication middleware.
+func RequireRole(roles ...string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !accessOf(r).HasRole(roles...) {
+				writeError(w, "You don't have access to this resource", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequirePermission only lets through users whose roles grant the permission. Use it after the authentication middleware.
+func RequirePermission(perm string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !accessOf(r).HasPermission(perm) {
+				writeError(w, "You don't have permission to do this", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// accessOf returns the roles and permissions of the request's principal; none if unauthenticated
+func accessOf(r *http.Request) Access {
+	if p, ok := PrincipalFromContext(r.Context()); ok {
+		return p.Access
+	}
+	return Access{}
+}
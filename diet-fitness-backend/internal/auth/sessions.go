@@ -0,0 +1,77 @@
+// --- diet-fitness-backend/internal/auth/sessions.go ---
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// sessionTouchInterval limits how often last_seen_at is written; a request a minute is precise enough
+const sessionTouchInterval = time.Minute
+
+// maxUserAgentLength truncates user agents before they are stored
+const maxUserAgentLength = 512
+
+// ErrSessionRevoked is returned for sessions that were signed out or belong to someone else
+var ErrSessionRevoked = errors.New("session has been revoked")
+
+// CreateSession records a login from the given device. Its ID goes into the JWT as the sid claim.
+func CreateSession(q Querier, userID int, userAgent, ip string) (int, error) {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+	}
+	now := time.Now().UTC()
+	// Sessions can't outlive their token, so expired ones are only history
+	if _, err := q.Exec("DELETE FROM user_sessions WHERE user_id = ? AND expires_at < ?", userID, now); err != nil {
+		return 0, fmt.Errorf("error removing expired sessions: %w", err)
+	}
+
+	var id int
+	err := q.QueryRow(`INSERT INTO user_sessions (user_id, user_agent, ip, created_at, last_seen_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING id`, userID, userAgent, ip, now, now, now.Add(TokenTTL)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating session: %w", err)
+	}
+	return id, nil
+}
+
+// CheckSession returns ErrSessionRevoked unless the session is the user's and still signed in,
+// and notes that it was just used
+func CheckSession(q Querier, userID, sessionID int) error {
+	var (
+		revokedAt  sql.NullTime
+		lastSeenAt time.Time
+	)
+	err := q.QueryRow("SELECT revoked_at, last_seen_at FROM user_sessions WHERE id = ? AND user_id = ?", sessionID, userID).
+		Scan(&revokedAt, &lastSeenAt)
+	if err == sql.ErrNoRows || (err == nil && revokedAt.Valid) {
+		return ErrSessionRevoked
+	}
+	if err != nil {
+		return fmt.Errorf("error loading session: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(lastSeenAt) >= sessionTouchInterval {
+		if _, err := q.Exec("UPDATE user_sessions SET last_seen_at = ? WHERE id = ?", now, sessionID); err != nil {
+			return fmt.Errorf("error updating session: %w", err)
+		}
+	}
+	return nil
+}
+
+// RevokeSession signs one of the user's sessions out. It returns ErrSessionRevoked if the session
+// isn't theirs or was already revoked.
+func RevokeSession(q Querier, userID, sessionID int) error {
+	res, err := q.Exec("UPDATE user_sessions SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+		time.Now().UTC(), sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrSessionRevoked
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// --- diet-fitness-backend/internal/auth/throttle.go ---
+package auth
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Outcomes recorded in login_attempts
+const (
+	LoginSucceeded       = "success"
+	LoginBadPassword     = "bad_password"
+	LoginUnknownAccount  = "unknown_account"
+	LoginBadMFACode      = "bad_mfa_code"
+	LoginMFARequired     = "mfa_required"    // Right password; the login isn't complete until the second factor passes
+	LoginThrottled       = "throttled"       // Rejected before the password was checked; doesn't count as a failure
+	LoginPending         = "pending"         // Credentials still being checked; counts as a failure until settled
+	LoginReauthenticated = "reauthenticated" // Credentials re-entered for a sensitive change; not a login, so earlier failures still count
+)
+
+// failedOutcomes count towards backoff and lockout
+var failedOutcomes = []interface{}{LoginBadPassword, LoginUnknownAccount, LoginBadMFACode, LoginPending}
+
+// loginAttemptRetention is how long attempts are kept for auditing
+const loginAttemptRetention = 30 * 24 * time.Hour
+
+// LoginLimits bounds password guessing. Each failure doubles the wait before the next attempt;
+// once the limit is reached the account (or address) is locked for the lockout period.
+type LoginLimits struct {
+	MaxFailures   int           // Consecutive failures per email before it is locked
+	IPMaxFailures int           // Failures per client address before it is locked; half of them are free
+	Lockout       time.Duration // Lockout length, and the window failures are counted in
+}
+
+// dummyHash is compared against when the email is unknown, so those logins take as long as wrong passwords
+var dummyHash = func() []byte {
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		panic(fmt.Sprintf("error generating dummy password: %v", err))
+	}
+	hash, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+	if err != nil {
+		panic(fmt.Sprintf("error hashing dummy password: %v", err))
+	}
+	return hash
+}()
+
+// CompareDummyPassword spends the same time as checking a real password and always fails
+func CompareDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// BeginLogin decides whether a login attempt may go ahead and, if it may, records it as pending in the
+// same transaction. A pending attempt counts as a failure until FinishLogin settles it, so parallel
+// attempts see each other and can't all pass one check. When the client must wait, the attempt is
+// recorded as throttled and attemptID is zero.
+func BeginLogin(db *sql.DB, limits LoginLimits, email, ip string) (attemptID int, wait time.Duration, err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, 0, fmt.Errorf("error starting login attempt: %w", err)
+	}
+	defer tx.Rollback()
+
+	// SQLite transactions on the main pool already hold the write lock; on PostgreSQL these rows make
+	// attempts on the same email or from the same address queue behind each other
+	now := time.Now().UTC()
+	for _, key := range []string{"email:" + email, "ip:" + ip} {
+		if _, err := tx.Exec(`INSERT INTO login_locks (lock_key, locked_at) VALUES (?, ?)
+			ON CONFLICT (lock_key) DO UPDATE SET locked_at = excluded.locked_at`, key, now); err != nil {
+			return 0, 0, fmt.Errorf("error locking login attempts: %w", err)
+		}
+	}
+
+	wait, err = CheckLogin(tx, limits, email, ip)
+	if err != nil {
+		return 0, 0, err
+	}
+	outcome := LoginPending
+	if wait > 0 {
+		outcome = LoginThrottled
+	}
+	if err := tx.QueryRow("INSERT INTO login_attempts (email, ip, outcome, created_at) VALUES (?, ?, ?, ?) RETURNING id",
+		email, ip, outcome, now).Scan(&attemptID); err != nil {
+		return 0, 0, fmt.Errorf("error recording login attempt: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("error recording login attempt: %w", err)
+	}
+	if wait > 0 {
+		return 0, wait, nil
+	}
+	return attemptID, 0, nil
+}
+
+// FinishLogin settles an attempt started by BeginLogin with its outcome, and drops attempts past
+// retention. userID is zero when the email doesn't belong to an account.
+func FinishLogin(q Querier, attemptID, userID int, outcome string) error {
+	var user sql.NullInt64
+	if userID != 0 {
+		user = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	if _, err := q.Exec("UPDATE login_attempts SET outcome = ?, user_id = ? WHERE id = ?", outcome, user, attemptID); err != nil {
+		return fmt.Errorf("error recording login attempt: %w", err)
+	}
+	cutoff := time.Now().UTC().Add(-loginAttemptRetention)
+	if _, err := q.Exec("DELETE FROM login_attempts WHERE created_at < ?", cutoff); err != nil {
+		return fmt.Errorf("error pruning login attempts: %w", err)
+	}
+	if _, err := q.Exec("DELETE FROM login_locks WHERE locked_at < ?", cutoff); err != nil {
+		return fmt.Errorf("error pruning login locks: %w", err)
+	}
+	return nil
+}
+
+// CheckLogin returns how long the client must wait before trying this email again, or zero if it may
+// try now. Unknown emails are throttled exactly like real ones so lockouts don't reveal which exist.
+func CheckLogin(q Querier, limits LoginLimits, email, ip string) (time.Duration, error) {
+	now := time.Now().UTC()
+	since := now.Add(-limits.Lockout)
+
+	// A successful login clears the email's earlier failures
+	accountSince := since
+	var lastSuccess time.Time
+	err := q.QueryRow("SELECT created_at FROM login_attempts WHERE email = ? AND outcome = ? ORDER BY created_at DESC LIMIT 1",
+		email, LoginSucceeded).Scan(&lastSuccess)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, fmt.Errorf("error loading last login: %w", err)
+	}
+	if lastSuccess.After(accountSince) {
+		accountSince = lastSuccess
+	}
+
+	failures, last, err := recentFailures(q, "email", email, accountSince)
+	if err != nil {
+		return 0, err
+	}
+	wait := remaining(last, backoff(failures, 0, limits.MaxFailures, limits.Lockout), now)
+
+	failures, last, err = recentFailures(q, "ip", ip, since)
+	if err != nil {
+		return 0, err
+	}
+	if ipWait := remaining(last, backoff(failures, limits.IPMaxFailures/2, limits.IPMaxFailures, limits.Lockout), now); ipWait > wait {
+		wait = ipWait
+	}
+	return wait, nil
+}
+
+// recentFailures counts failed logins matching column (email or ip) since the given time and
+// returns when the latest happened
+func recentFailures(q Querier, column, value string, since time.Time) (int, time.Time, error) {
+	var (
+		failures int
+		last     time.Time
+	)
+	where := " FROM login_attempts WHERE " + column + " = ? AND outcome IN (?, ?, ?, ?) AND created_at > ?"
+	args := append(append([]interface{}{value}, failedOutcomes...), since)
+	if err := q.QueryRow("SELECT COUNT(*)"+where, args...).Scan(&failures); err != nil {
+		return 0, last, fmt.Errorf("error counting failed logins: %w", err)
+	}
+	if failures == 0 {
+		return 0, last, nil
+	}
+	if err := q.QueryRow("SELECT created_at"+where+" ORDER BY created_at DESC LIMIT 1", args...).Scan(&last); err != nil {
+		return 0, last, fmt.Errorf("error loading last failed login: %w", err)
+	}
+	return failures, last, nil
+}
+
+// backoff is the wait after the given number of failures: nothing for the first free ones, then one
+// second doubling with each failure, and the full lockout once limit is reached
+func backoff(failures, free, limit int, lockout time.Duration) time.Duration {
+	if failures >= limit {
+		return lockout
+	}
+	if failures <= free {
+		return 0
+	}
+	shift := failures - free - 1
+	if shift > 30 {
+		return lockout
+	}
+	if d := time.Second << shift; d < lockout {
+		return d
+	}
+	return lockout
+}
+
+// remaining is how much of the wait since the last failure is left
+func remaining(last time.Time, wait time.Duration, now time.Time) time.Duration {
+	if wait == 0 {
+		return 0
+	}
+	if d := last.Add(wait).Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// ClientIP is the address the request came from. X-Forwarded-For is only honoured when a trusted proxy
+// sets it; the proxy appends the address it saw, so the last entry is the one to use.
+func ClientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			hops := strings.Split(forwarded, ",")
+			if ip := strings.TrimSpace(hops[len(hops)-1]); ip != "" {
+				return ip
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
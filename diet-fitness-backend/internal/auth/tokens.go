@@ -0,0 +1,171 @@
+// --- diet-fitness-backend/internal/auth/tokens.go ---
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Purposes of single-use tokens stored in user_tokens
+const (
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailVerification = "email_verification"
+	PurposeMFAChallenge      = "mfa_challenge" // Issued after the password or provider sign-in when the second factor is still due
+)
+
+// ErrInvalidToken is returned for tokens that are unknown, expired or already used
+var ErrInvalidToken = errors.New("token is invalid or has expired")
+
+// Querier is the subset of *sql.DB and *sql.Tx the token helpers need
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// NewOpaqueToken returns a random URL-safe token and the hash to store in its place
+func NewOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", fmt.Errorf("error generating token: %w", err)
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken hashes an opaque token for storage. The tokens carry 256 bits of randomness,
+// so an unsalted SHA-256 is enough; a database leak doesn't reveal usable tokens.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueUserToken creates a single-use token for the user, replacing any unused token with the same purpose
+func IssueUserToken(q Querier, userID int, purpose string, ttl time.Duration) (string, error) {
+	return issueUserToken(q, userID, purpose, "", ttl)
+}
+
+// IssueMFAChallenge creates the challenge a user redeems with their second factor. firstFactor is how
+// they signed in, "password" or "oidc:<provider>", and ends up in the audit log of the login.
+func IssueMFAChallenge(q Querier, userID int, firstFactor string, ttl time.Duration) (string, error) {
+	return issueUserToken(q, userID, PurposeMFAChallenge, firstFactor, ttl)
+}
+
+func issueUserToken(q Querier, userID int, purpose, firstFactor string, ttl time.Duration) (string, error) {
+	token, hash, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	if _, err := q.Exec("DELETE FROM user_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose); err != nil {
+		return "", fmt.Errorf("error replacing earlier tokens: %w", err)
+	}
+	now := time.Now().UTC()
+	_, err = q.Exec("INSERT INTO user_tokens (user_id, purpose, token_hash, first_factor, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		userID, purpose, hash, firstFactor, now.Add(ttl), now)
+	if err != nil {
+		return "", fmt.Errorf("error storing token: %w", err)
+	}
+	return token, nil
+}
+
+// ConsumeUserToken marks a token as used and returns its user. Each token works exactly once.
+func ConsumeUserToken(q Querier, purpose, token string) (int, error) {
+	id, userID, err := CheckUserToken(q, purpose, token)
+	if err != nil {
+		return 0, err
+	}
+	if err := UseUserToken(q, id); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// CheckUserToken validates a token without using it up, for flows that may need several attempts.
+// It returns the token's row ID and its user.
+func CheckUserToken(q Querier, purpose, token string) (id, userID int, err error) {
+	id, userID, _, err = checkUserToken(q, purpose, token)
+	return id, userID, err
+}
+
+// CheckMFAChallenge is CheckUserToken for challenges from IssueMFAChallenge, also returning how the
+// user signed in. Challenges issued before this was recorded came from a password.
+func CheckMFAChallenge(q Querier, token string) (id, userID int, firstFactor string, err error) {
+	id, userID, firstFactor, err = checkUserToken(q, PurposeMFAChallenge, token)
+	if err == nil && firstFactor == "" {
+		firstFactor = "password"
+	}
+	return id, userID, firstFactor, err
+}
+
+func checkUserToken(q Querier, purpose, token string) (id, userID int, firstFactor string, err error) {
+	var (
+		expiresAt time.Time
+		usedAt    sql.NullTime
+	)
+	err = q.QueryRow("SELECT id, user_id, first_factor, expires_at, used_at FROM user_tokens WHERE token_hash = ? AND purpose = ?",
+		HashToken(token), purpose).Scan(&id, &userID, &firstFactor, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return 0, 0, "", ErrInvalidToken
+	}
+	if err != nil {
+		return 0, 0, "", fmt.Errorf("error loading token: %w", err)
+	}
+	if usedAt.Valid || time.Now().UTC().After(expiresAt) {
+		return 0, 0, "", ErrInvalidToken
+	}
+	return id, userID, firstFactor, nil
+}
+
+// UseUserToken marks a checked token as used
+func UseUserToken(q Querier, id int) error {
+	// The used_at guard makes concurrent redemptions race safely: only one update wins
+	res, err := q.Exec("UPDATE user_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL", time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error using token: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// RecordTokenFailure counts a failed attempt against a token and burns it once maxAttempts is reached
+func RecordTokenFailure(q Querier, id, maxAttempts int) error {
+	_, err := q.Exec(`UPDATE user_tokens SET attempts = attempts + 1,
+		used_at = CASE WHEN attempts + 1 >= ? THEN ? ELSE used_at END WHERE id = ?`, maxAttempts, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error recording failed attempt: %w", err)
+	}
+	return nil
+}
+
+// RevokeTokens makes every JWT issued to the user before now invalid and signs out their sessions
+func RevokeTokens(q Querier, userID int) error {
+	now := time.Now().UTC()
+	// JWT issued-at times have one-second resolution
+	_, err := q.Exec("UPDATE users SET tokens_valid_after = ? WHERE id = ?", now.Truncate(time.Second), userID)
+	if err != nil {
+		return fmt.Errorf("error revoking tokens: %w", err)
+	}
+	if _, err := q.Exec("UPDATE user_sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL", now, userID); err != nil {
+		return fmt.Errorf("error revoking sessions: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllTokens is RevokeTokens for every user, signing everyone out
+func RevokeAllTokens(q Querier) error {
+	now := time.Now().UTC()
+	if _, err := q.Exec("UPDATE users SET tokens_valid_after = ?", now.Truncate(time.Second)); err != nil {
+		return fmt.Errorf("error revoking tokens: %w", err)
+	}
+	if _, err := q.Exec("UPDATE user_sessions SET revoked_at = ? WHERE revoked_at IS NULL", now); err != nil {
+		return fmt.Errorf("error revoking sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,286 @@
+// --- diet-fitness-backend/internal/backup/backup.go ---
+// Package backup takes online snapshots of the SQLite database and restores them. A snapshot is made
+// with VACUUM INTO, which writes a consistent, compacted copy while the server keeps serving requests,
+// and is then optionally gzipped and encrypted. Restore recognizes each layer from the file contents,
+// so it doesn't rely on the file name.
+package backup
+
+import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/db"
+)
+
+const (
+	filePrefix = "fitplan-"
+	timeLayout = "20060102T150405Z"
+)
+
+// ErrUnsupported is returned when the database isn't SQLite; PostgreSQL has pg_dump for this
+var ErrUnsupported = errors.New("online backups are only available for SQLite; use pg_dump for PostgreSQL")
+
+// Info describes a backup file
+type Info struct {
+	Name       string    `json:"name"`
+	SizeBytes  int64     `json:"size_bytes"`
+	CreatedAt  time.Time `json:"created_at"`
+	Compressed bool      `json:"compressed"`
+	Encrypted  bool      `json:"encrypted"`
+}
+
+// Manager takes backups on demand and on a schedule, and prunes old ones
+type Manager struct {
+	db       *sql.DB
+	dir      string
+	keep     int
+	compress bool
+	key      []byte
+	enabled  bool // False with PostgreSQL
+
+	mu sync.Mutex // One backup at a time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewManager prepares the backup directory and starts the schedule when BACKUP_INTERVAL is set.
+// Call Close on shutdown.
+func NewManager(database *sql.DB, cfg *config.Config) (*Manager, error) {
+	key, err := ParseKey(cfg.BackupEncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager{db: database, dir: cfg.BackupDir, keep: cfg.BackupKeep, compress: cfg.BackupGzip, key: key,
+		enabled: db.DialectOf(cfg) == db.SQLite}
+	if !m.enabled {
+		slog.Info("Database backups disabled", "reason", ErrUnsupported.Error())
+		return m, nil
+	}
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory %s: %w", m.dir, err)
+	}
+	if key == nil {
+		slog.Warn("BACKUP_ENCRYPTION_KEY is not set; backups are stored unencrypted")
+	}
+
+	if cfg.BackupInterval > 0 {
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancel = cancel
+		m.done = make(chan struct{})
+		go m.schedule(ctx, cfg.BackupInterval)
+		slog.Info("Database backups scheduled", "interval", cfg.BackupInterval.String(), "dir", m.dir, "keep", m.keep)
+	}
+	return m, nil
+}
+
+// Close stops the schedule, waiting for a backup in progress
+func (m *Manager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+}
+
+func (m *Manager) schedule(ctx context.Context, every time.Duration) {
+	defer close(m.done)
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := m.Run(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Scheduled database backup failed", "err", err)
+				continue
+			}
+			slog.InfoContext(ctx, "Scheduled database backup written", "backup", info.Name, "size_bytes", info.SizeBytes)
+		}
+	}
+}
+
+// Run takes a backup now, then deletes the oldest ones beyond the retention count
+func (m *Manager) Run(ctx context.Context) (Info, error) {
+	if !m.enabled {
+		return Info{}, ErrUnsupported
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	name := filePrefix + now.Format(timeLayout) + ".db"
+	if m.compress {
+		name += ".gz"
+	}
+	if m.key != nil {
+		name += ".enc"
+	}
+	final := filepath.Join(m.dir, name)
+	if _, err := os.Stat(final); err == nil {
+		return Info{}, fmt.Errorf("backup %s already exists; try again in a second", name)
+	}
+
+	// VACUUM INTO refuses to overwrite, and a leftover from a crashed run would otherwise block it
+	snapshot := filepath.Join(m.dir, "."+name+".snapshot")
+	os.Remove(snapshot)
+	defer os.Remove(snapshot)
+	if _, err := m.db.ExecContext(ctx, "VACUUM INTO ?", snapshot); err != nil {
+		return Info{}, fmt.Errorf("error taking snapshot: %w", err)
+	}
+
+	if err := encode(snapshot, final, m.compress, m.key); err != nil {
+		return Info{}, err
+	}
+	stat, err := os.Stat(final)
+	if err != nil {
+		return Info{}, err
+	}
+	if err := m.prune(); err != nil {
+		slog.ErrorContext(ctx, "Error pruning old backups", "err", err)
+	}
+	return Info{Name: name, SizeBytes: stat.Size(), CreatedAt: now, Compressed: m.compress, Encrypted: m.key != nil}, nil
+}
+
+// encode writes the snapshot to dst through the optional gzip and encryption layers. The file appears
+// under its final name only once it is complete.
+func encode(snapshot, dst string, compress bool, key []byte) error {
+	if !compress && key == nil {
+		if err := os.Chmod(snapshot, 0600); err != nil {
+			return err
+		}
+		return os.Rename(snapshot, dst)
+	}
+
+	in, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp) // No-op once renamed
+
+	// Layers are closed innermost first so each flushes into the next
+	var w io.Writer = out
+	var closers []io.Closer
+	if key != nil {
+		enc, err := newEncryptWriter(w, key)
+		if err != nil {
+			out.Close()
+			return fmt.Errorf("error starting encryption: %w", err)
+		}
+		w = enc
+		closers = append([]io.Closer{enc}, closers...)
+	}
+	if compress {
+		gz := gzip.NewWriter(w)
+		w = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+	_, err = io.Copy(w, in)
+	for _, c := range closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing backup: %w", err)
+	}
+	return os.Rename(tmp, dst)
+}
+
+// List returns the backups in the backup directory, newest first
+func (m *Manager) List() ([]Info, error) {
+	if !m.enabled {
+		return nil, ErrUnsupported
+	}
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	backups := []Info{}
+	for _, e := range entries {
+		info, ok := parseName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		stat, err := e.Info()
+		if err != nil {
+			continue // Pruned meanwhile
+		}
+		info.SizeBytes = stat.Size()
+		backups = append(backups, info)
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].CreatedAt.After(backups[j].CreatedAt) })
+	return backups, nil
+}
+
+// prune deletes the oldest backups beyond the retention count; a count of 0 keeps them all
+func (m *Manager) prune() error {
+	if m.keep <= 0 {
+		return nil
+	}
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+	for _, b := range backups[min(m.keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(m.dir, b.Name)); err != nil {
+			return err
+		}
+		slog.Info("Deleted old database backup", "backup", b.Name)
+	}
+	return nil
+}
+
+// parseName recognizes the files Run writes, e.g. fitplan-20240301T020000Z.db.gz.enc
+func parseName(name string) (Info, bool) {
+	rest, found := strings.CutPrefix(name, filePrefix)
+	if !found {
+		return Info{}, false
+	}
+	stamp, suffix, found := strings.Cut(rest, ".db")
+	if !found {
+		return Info{}, false
+	}
+	createdAt, err := time.Parse(timeLayout, stamp)
+	if err != nil {
+		return Info{}, false
+	}
+	info := Info{Name: name, CreatedAt: createdAt}
+	switch suffix {
+	case "":
+	case ".gz":
+		info.Compressed = true
+	case ".enc":
+		info.Encrypted = true
+	case ".gz.enc":
+		info.Compressed, info.Encrypted = true, true
+	default:
+		return Info{}, false
+	}
+	return info, true
+}
@@ -0,0 +1,216 @@
+// --- diet-fitness-backend/internal/backup/crypt.go ---
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted backups are AES-256-GCM in 64 KiB chunks, so files of any size stream through a fixed
+// buffer. The file starts with encryptedMagic and a random nonce prefix; each chunk is its ciphertext
+// length followed by the ciphertext, sealed under the prefix and the chunk's index. The last chunk is
+// sealed with different additional data, so a truncated file fails to decrypt instead of restoring
+// a partial database.
+const (
+	encryptedMagic = "FPBKENC1"
+	chunkSize      = 64 * 1024
+	prefixSize     = 8
+	// KeySize is the length of the BACKUP_ENCRYPTION_KEY in bytes, before base64 encoding
+	KeySize = 32
+)
+
+var (
+	lastChunk = []byte{1}
+	midChunk  = []byte{0}
+)
+
+// ParseKey decodes a base64 encryption key, as generated by `openssl rand -base64 32`; an empty
+// string means backups aren't encrypted
+func ParseKey(encoded string) ([]byte, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("backup encryption key is not valid base64: %w", err)
+	}
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("backup encryption key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return key, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index uint32) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], index)
+	return nonce
+}
+
+// encryptWriter seals everything written to it; Close writes the final chunk
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint32
+	buf    []byte
+}
+
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, encryptedMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, so Close always has a last chunk to seal
+		if len(e.buf) == chunkSize {
+			if err := e.seal(midChunk); err != nil {
+				return written, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(lastChunk)
+}
+
+func (e *encryptWriter) seal(kind []byte) error {
+	if e.index == ^uint32(0) {
+		return errors.New("backup too large to encrypt")
+	}
+	sealed := e.aead.Seal(nil, chunkNonce(e.prefix, e.index), e.buf, kind)
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(sealed)))
+	if _, err := e.w.Write(length[:]); err != nil {
+		return err
+	}
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.index++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// decryptReader opens the chunks written by encryptWriter
+type decryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	index  uint32
+	plain  []byte
+	done   bool
+}
+
+func newDecryptReader(r io.Reader, key []byte) (*decryptReader, error) {
+	if key == nil {
+		return nil, errors.New("backup is encrypted; set BACKUP_ENCRYPTION_KEY")
+	}
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, len(encryptedMagic)+prefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("reading encryption header: %w", err)
+	}
+	if string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, errors.New("not an encrypted backup")
+	}
+	return &decryptReader{r: r, aead: aead, prefix: header[len(encryptedMagic):]}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	var length [4]byte
+	if _, err := io.ReadFull(d.r, length[:]); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errors.New("encrypted backup is truncated")
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > chunkSize+uint32(d.aead.Overhead()) {
+		return errors.New("encrypted backup is corrupt")
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return errors.New("encrypted backup is truncated")
+	}
+	nonce := chunkNonce(d.prefix, d.index)
+	plain, err := d.aead.Open(nil, nonce, sealed, midChunk)
+	if err != nil {
+		plain, err = d.aead.Open(nil, nonce, sealed, lastChunk)
+		if err != nil {
+			return errors.New("backup can't be decrypted: wrong key or corrupt file")
+		}
+		d.done = true
+		// Anything after the last chunk means the file was tampered with
+		if n, _ := d.r.Read(make([]byte, 1)); n > 0 {
+			return errors.New("encrypted backup has data after its last chunk")
+		}
+	}
+	d.index++
+	d.plain = plain
+	return nil
+}
+
+// peek returns a buffered reader over r along with its first bytes, for recognizing the file format
+func peek(r io.Reader, n int) (*bufio.Reader, []byte, error) {
+	br := bufio.NewReaderSize(r, chunkSize)
+	head, err := br.Peek(n)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, nil, err
+	}
+	return br, bytes.Clone(head), nil
+}
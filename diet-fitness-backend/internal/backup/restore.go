@@ -0,0 +1,154 @@
+// --- diet-fitness-backend/internal/backup/restore.go ---
+package backup
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+const sqliteMagic = "SQLite format 3\x00"
+
+// Decode writes the database contained in a backup file to dst, undoing encryption and compression.
+// key may be nil for unencrypted backups.
+func Decode(src, dst string, key []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r, head, err := peek(in, len(encryptedMagic))
+	if err != nil {
+		return err
+	}
+	var plain io.Reader = r
+	if string(head) == encryptedMagic {
+		dec, err := newDecryptReader(r, key)
+		if err != nil {
+			return err
+		}
+		if plain, head, err = peek(dec, 2); err != nil {
+			return err
+		}
+	}
+	if len(head) >= 2 && head[0] == 0x1f && head[1] == 0x8b {
+		gz, err := gzip.NewReader(plain)
+		if err != nil {
+			return fmt.Errorf("error reading compressed backup: %w", err)
+		}
+		defer gz.Close()
+		plain = gz
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, plain)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("error decoding backup: %w", err)
+	}
+	return nil
+}
+
+// Verify checks that path is an intact SQLite database with this application's schema, using
+// PRAGMA integrity_check
+func Verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, len(sqliteMagic))
+	_, err = io.ReadFull(f, header)
+	f.Close()
+	if err != nil || string(header) != sqliteMagic {
+		return errors.New("not a SQLite database")
+	}
+
+	database, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	rows, err := database.Query("PRAGMA integrity_check")
+	if err != nil {
+		return fmt.Errorf("error running integrity check: %w", err)
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			return err
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error running integrity check: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+
+	var users int
+	if err := database.QueryRow("SELECT COUNT(*) FROM users").Scan(&users); err != nil {
+		return fmt.Errorf("not a database of this application: %w", err)
+	}
+	return nil
+}
+
+// Restore replaces the database at dst with the one in the backup file src. The backup is decoded
+// next to dst and verified before anything is touched; the current database, with its WAL files, is
+// then moved aside under a .pre-restore name rather than deleted. The server must be stopped first.
+// It returns the path the previous database was moved to, or "" if there was none.
+func Restore(src, dst string, key []byte) (string, error) {
+	tmp := dst + ".restoring"
+	if err := Decode(src, tmp, key); err != nil {
+		return "", err
+	}
+	if err := Verify(tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	previous := ""
+	if _, err := os.Stat(dst); err == nil {
+		previous = dst + ".pre-restore-" + time.Now().UTC().Format(timeLayout)
+		if err := os.Rename(dst, previous); err != nil {
+			os.Remove(tmp)
+			return "", fmt.Errorf("error moving the current database aside: %w", err)
+		}
+		// The WAL belongs to the old database; left in place, SQLite would replay it into the restored one
+		for _, suffix := range []string{"-wal", "-shm"} {
+			if _, err := os.Stat(dst + suffix); err == nil {
+				if err := os.Rename(dst+suffix, previous+suffix); err != nil {
+					os.Rename(previous, dst)
+					os.Remove(tmp)
+					return "", fmt.Errorf("error moving %s aside: %w", dst+suffix, err)
+				}
+			}
+		}
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return previous, fmt.Errorf("error moving the restored database into place: %w", err)
+	}
+	return previous, nil
+}
@@ -0,0 +1,91 @@
+// --- diet-fitness-backend/internal/db/dialect.go ---
+package db
+
+import (
+	"strconv"
+	"strings"
+
+	"diet-fitness-backend/config"
+)
+
+// Dialect is the SQL engine behind the application database
+type Dialect string
+
+const (
+	SQLite   Dialect = "sqlite"
+	Postgres Dialect = "postgres"
+)
+
+// DialectOf returns the engine the configuration selects: PostgreSQL for a postgres:// DATABASE_URL, SQLite otherwise
+func DialectOf(cfg *config.Config) Dialect {
+	url := strings.ToLower(cfg.DatabaseURL)
+	if strings.HasPrefix(url, "postgres://") || strings.HasPrefix(url, "postgresql://") {
+		return Postgres
+	}
+	return SQLite
+}
+
+// Name is the engine's name for log and error messages
+func (d Dialect) Name() string {
+	if d == Postgres {
+		return "PostgreSQL"
+	}
+	return "SQLite"
+}
+
+// Rebind rewrites the ? placeholders used throughout the code base into the dialect's own style,
+// $1, $2, ... for PostgreSQL. Question marks inside quoted strings, identifiers and comments are left alone.
+func (d Dialect) Rebind(query string) string {
+	if d != Postgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 8)
+	n := 0
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(query[i+1:], c)
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end+2])
+			i += end + 1
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				b.WriteString(query[i:])
+				return b.String()
+			}
+			b.WriteString(query[i : i+end])
+			i += end - 1
+		case c == '?':
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// postgresTypes maps the SQLite column types of the schema onto their PostgreSQL equivalents.
+// REAL is single precision in PostgreSQL, so it becomes DOUBLE PRECISION like SQLite's 8-byte REAL.
+var postgresTypes = strings.NewReplacer(
+	"INTEGER PRIMARY KEY AUTOINCREMENT", "SERIAL PRIMARY KEY",
+	" DATETIME", " TIMESTAMPTZ",
+	" REAL", " DOUBLE PRECISION",
+	" BLOB", " BYTEA",
+)
+
+// DDL translates a CREATE TABLE or column definition written for SQLite into the dialect
+func (d Dialect) DDL(definition string) string {
+	if d != Postgres {
+		return definition
+	}
+	return postgresTypes.Replace(" " + definition)[1:]
+}
@@ -0,0 +1,133 @@
+// --- diet-fitness-backend/internal/db/postgres.go ---
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // Registers the PostgreSQL driver as "pgx"
+)
+
+// postgresDriver is the database/sql driver name registered by the PostgreSQL driver package
+const postgresDriver = "pgx"
+
+// openPostgres connects to PostgreSQL through a connector that rebinds the ? placeholders of every
+// statement, so the queries written for SQLite run unchanged
+func openPostgres(url string) (*sql.DB, error) {
+	probe, err := sql.Open(postgresDriver, url)
+	if err != nil {
+		return nil, fmt.Errorf("error opening PostgreSQL database: %w", err)
+	}
+	drv := probe.Driver()
+	probe.Close()
+	return OpenRebound(drv, url, Postgres)
+}
+
+// OpenRebound opens a database on drv whose statements have their placeholders rewritten for dialect
+func OpenRebound(drv driver.Driver, dsn string, dialect Dialect) (*sql.DB, error) {
+	var connector driver.Connector = dsnConnector{dsn: dsn, driver: drv}
+	if dc, ok := drv.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("error opening database: %w", err)
+		}
+		connector = c
+	}
+	return sql.OpenDB(reboundConnector{Connector: connector, dialect: dialect}), nil
+}
+
+// dsnConnector adapts drivers that only implement driver.Driver
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.driver.Open(c.dsn) }
+func (c dsnConnector) Driver() driver.Driver                        { return c.driver }
+
+type reboundConnector struct {
+	driver.Connector
+	dialect Dialect
+}
+
+func (c reboundConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &reboundConn{conn: conn, dialect: c.dialect}, nil
+}
+
+// reboundConn rewrites queries on the way to the wrapped connection and passes everything else through.
+// Optional interfaces the wrapped connection lacks report driver.ErrSkip, so database/sql falls back
+// to the same path it would take without the wrapper.
+type reboundConn struct {
+	conn    driver.Conn
+	dialect Dialect
+}
+
+func (c *reboundConn) Prepare(query string) (driver.Stmt, error) {
+	return c.conn.Prepare(c.dialect.Rebind(query))
+}
+
+func (c *reboundConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if p, ok := c.conn.(driver.ConnPrepareContext); ok {
+		return p.PrepareContext(ctx, c.dialect.Rebind(query))
+	}
+	return c.Prepare(query)
+}
+
+func (c *reboundConn) Close() error { return c.conn.Close() }
+
+func (c *reboundConn) Begin() (driver.Tx, error) { return c.conn.Begin() }
+
+func (c *reboundConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.conn.Begin()
+}
+
+func (c *reboundConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.conn.(driver.ExecerContext); ok {
+		return e.ExecContext(ctx, c.dialect.Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *reboundConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if q, ok := c.conn.(driver.QueryerContext); ok {
+		return q.QueryContext(ctx, c.dialect.Rebind(query), args)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *reboundConn) Ping(ctx context.Context) error {
+	if p, ok := c.conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *reboundConn) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := c.conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *reboundConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *reboundConn) IsValid() bool {
+	if v, ok := c.conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
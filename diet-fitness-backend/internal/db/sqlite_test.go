@@ -0,0 +1,234 @@
+// --- diet-fitness-backend/internal/db/sqlite_test.go ---
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+)
+
+// openTestDB opens a fresh SQLite database with the default settings: the main pool and the read pool
+func openTestDB(t *testing.T) (*sql.DB, *sql.DB) {
+	t.Helper()
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		t.Fatalf("loading configuration: %v", err)
+	}
+	cfg.SQLiteDBPath, cfg.DatabaseURL = filepath.Join(t.TempDir(), "test.db"), ""
+	main, err := InitDB(cfg)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { main.Close() })
+	read, err := OpenReadPool(cfg)
+	if err != nil {
+		t.Fatalf("opening read pool: %v", err)
+	}
+	if read == nil {
+		t.Fatal("no read pool with the default settings")
+	}
+	t.Cleanup(func() { read.Close() })
+	return main, read
+}
+
+func createCounter(t *testing.T, main *sql.DB) {
+	t.Helper()
+	if _, err := main.Exec(`CREATE TABLE counter (id INTEGER PRIMARY KEY, n INTEGER NOT NULL); INSERT INTO counter (id, n) VALUES (1, 0);`); err != nil {
+		t.Fatalf("creating counter: %v", err)
+	}
+}
+
+func TestSQLiteUsesWAL(t *testing.T) {
+	main, _ := openTestDB(t)
+	var mode string
+	if err := main.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.EqualFold(mode, "wal") {
+		t.Fatalf("journal mode = %s, want wal", mode)
+	}
+}
+
+// Transactions that read and then write are the ones that fail with "database is locked" under deferred
+// transactions: two of them hold read locks and neither can upgrade. Started IMMEDIATE they queue on the
+// write lock instead, so every increment lands.
+func TestSQLiteConcurrentReadModifyWrite(t *testing.T) {
+	main, _ := openTestDB(t)
+	createCounter(t, main)
+
+	const workers, increments = 16, 25
+	ctx := context.Background()
+	errs := make(chan error, workers*increments)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				errs <- increment(ctx, main)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("increment failed: %v", err)
+		}
+	}
+
+	var n int
+	if err := main.QueryRow("SELECT n FROM counter WHERE id = 1").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != workers*increments {
+		t.Fatalf("counter = %d, want %d: updates were lost", n, workers*increments)
+	}
+}
+
+func increment(ctx context.Context, main *sql.DB) error {
+	tx, err := main.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow("SELECT n FROM counter WHERE id = 1").Scan(&n); err != nil {
+		return err
+	}
+	time.Sleep(time.Millisecond) // Work between the read and the write, so transactions overlap
+	if _, err := tx.Exec("UPDATE counter SET n = ? WHERE id = 1", n+1); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// With WAL a reader in the middle of a transaction neither blocks a writer's commit nor sees it
+func TestSQLiteReadersDoNotBlockWriters(t *testing.T) {
+	main, read := openTestDB(t)
+	createCounter(t, main)
+
+	ctx := context.Background()
+	readTx, err := read.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer readTx.Rollback()
+	var before int
+	if err := readTx.QueryRow("SELECT n FROM counter WHERE id = 1").Scan(&before); err != nil {
+		t.Fatal(err)
+	}
+
+	writeCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := increment(writeCtx, main); err != nil {
+		t.Fatalf("write blocked by an open reader: %v", err)
+	}
+
+	var during int
+	if err := readTx.QueryRow("SELECT n FROM counter WHERE id = 1").Scan(&during); err != nil {
+		t.Fatal(err)
+	}
+	if during != before {
+		t.Fatalf("reader saw %d mid-transaction, want its snapshot %d", during, before)
+	}
+	readTx.Rollback()
+
+	var after int
+	if err := read.QueryRow("SELECT n FROM counter WHERE id = 1").Scan(&after); err != nil {
+		t.Fatal(err)
+	}
+	if after != before+1 {
+		t.Fatalf("read pool sees %d after the commit, want %d", after, before+1)
+	}
+}
+
+func TestSQLiteReadPoolIsReadOnly(t *testing.T) {
+	main, read := openTestDB(t)
+	createCounter(t, main)
+	_, err := read.Exec("UPDATE counter SET n = 99 WHERE id = 1")
+	if err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Fatalf("write through the read pool: err = %v, want a read-only error", err)
+	}
+}
+
+// Workouts logged through the store while others read plans, as the API does under load: nothing fails
+// with "database is locked" and every session is recorded.
+func TestSQLiteStoreUnderConcurrentLoad(t *testing.T) {
+	main, read := openTestDB(t)
+	st := store.NewSQLite(main).ReadFrom(read)
+	ctx := context.Background()
+
+	const users, writers, readers, sessionsPerWriter = 4, 8, 16, 10
+	userIDs := make([]int, users)
+	for i := range userIDs {
+		id, err := st.Users().Create(ctx, fmt.Sprintf("load%d@sqlite.test", i), "hash")
+		if err != nil {
+			t.Fatal(err)
+		}
+		exercises := []models.PlanExercise{{Name: "Back Squat", MuscleGroup: "quads", Scheme: "linear", Sets: 3, TargetReps: 5, LoadKg: 60, IncrementKg: 2.5}}
+		if _, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: id, Title: "Load", Exercises: exercises}); err != nil {
+			t.Fatal(err)
+		}
+		userIDs[i] = id
+	}
+
+	errs := make(chan error, writers*sessionsPerWriter+readers)
+	done := make(chan struct{})
+	var writersWG, readersWG sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		writersWG.Add(1)
+		go func(w int) {
+			defer writersWG.Done()
+			for i := 0; i < sessionsPerWriter; i++ {
+				userID := userIDs[(w+i)%users]
+				session := models.WorkoutSession{UserID: userID, PerformedAt: time.Now().UTC(), SessionRPE: 7, DurationMin: 45,
+					Sets: []models.WorkoutSet{{ExerciseName: "Back Squat", MuscleGroup: "quads", SetNumber: 1, Reps: 5, LoadKg: 60, RPE: 7}}}
+				errs <- st.Workouts().Record(ctx, &session, nil)
+			}
+		}(w)
+	}
+	for r := 0; r < readers; r++ {
+		readersWG.Add(1)
+		go func(r int) {
+			defer readersWG.Done()
+			for i := r; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := st.Plans().Active(ctx, userIDs[i%users]); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}(r)
+	}
+	writersWG.Wait()
+	close(done)
+	readersWG.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("operation failed under load: %v", err)
+		}
+	}
+
+	var sessions int
+	if err := main.QueryRow("SELECT COUNT(*) FROM workout_sessions").Scan(&sessions); err != nil {
+		t.Fatal(err)
+	}
+	if sessions != writers*sessionsPerWriter {
+		t.Fatalf("%d sessions recorded, want %d", sessions, writers*sessionsPerWriter)
+	}
+}
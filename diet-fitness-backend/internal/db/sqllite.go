@@ -4,59 +4,734 @@ package db
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/url"
 	"os"
 	"path/filepath" // Import for creating parent directories
+	"strconv"
+	"strings"
+	"time"
 
 	"diet-fitness-backend/config" // Import your config package
+	"diet-fitness-backend/internal/auth"
 
 	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
-// InitDB initializes and returns an SQLite database connection
+// InitDB initializes and returns the database connection: SQLite at SQLiteDBPath, or PostgreSQL when
+// DatabaseURL is a postgres:// URL
 func InitDB(cfg *config.Config) (*sql.DB, error) {
-	// Ensure the directory for the DB file exists
-	dbDir := filepath.Dir(cfg.SQLiteDBPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return nil, fmt.Errorf("failed to create database directory %s: %w", dbDir, err)
+	dialect := DialectOf(cfg)
+	if dialect != Postgres && cfg.DatabaseURL != "" {
+		return nil, fmt.Errorf("unsupported DATABASE_URL: only postgres:// URLs are supported; leave it empty for SQLite")
 	}
+	var (
+		db  *sql.DB
+		err error
+	)
+	if dialect == Postgres {
+		db, err = openPostgres(cfg.DatabaseURL)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		// Ensure the directory for the DB file exists
+		dbDir := filepath.Dir(cfg.SQLiteDBPath)
+		if err := os.MkdirAll(dbDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create database directory %s: %w", dbDir, err)
+		}
 
-	db, err := sql.Open("sqlite3", cfg.SQLiteDBPath)
-	if err != nil {
-		return nil, fmt.Errorf("error opening SQLite database: %w", err)
+		db, err = sql.Open("sqlite3", sqliteDSN(cfg, false))
+		if err != nil {
+			return nil, fmt.Errorf("error opening SQLite database: %w", err)
+		}
 	}
+	configurePool(db, cfg, cfg.DBMaxOpenConns)
 
 	// Ping the database to verify connection
 	if err = db.Ping(); err != nil {
 		db.Close() // Close the connection if ping fails
-		return nil, fmt.Errorf("error connecting to the SQLite database: %w", err)
+		return nil, fmt.Errorf("error connecting to the %s database: %w", dialect.Name(), err)
 	}
 
-	log.Println("SQLite database connection established successfully.")
+	slog.Info("Database connection established", "dialect", dialect.Name())
+	if dialect == SQLite {
+		var journalMode string
+		if err := db.QueryRow("PRAGMA journal_mode").Scan(&journalMode); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("error reading SQLite journal mode: %w", err)
+		}
+		// WAL can't be used on some network file systems, where SQLite silently keeps the old mode
+		if cfg.SQLiteJournalMode != "" && !strings.EqualFold(journalMode, cfg.SQLiteJournalMode) {
+			slog.Warn("SQLite journal mode differs from the configured one", "journal_mode", journalMode, "configured", cfg.SQLiteJournalMode)
+		}
+		slog.Info("SQLite configured", "journal_mode", journalMode, "synchronous", cfg.SQLiteSynchronous, "busy_timeout", cfg.SQLiteBusyTimeout.String(), "foreign_keys", cfg.SQLiteForeignKeys)
+	}
 
 	// Initialize schema (create tables if they don't exist)
-	if err := createSchema(db); err != nil {
+	if err := createSchema(db, dialect); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create %s schema: %w", dialect.Name(), err)
+	}
+	slog.Info("Schema created or verified", "dialect", dialect.Name())
+
+	if err := grantBootstrapAdmins(db, cfg.AdminEmails); err != nil {
 		db.Close()
-		return nil, fmt.Errorf("failed to create SQLite schema: %w", err)
+		return nil, err
 	}
-	log.Println("SQLite schema created/verified.")
 
 	return db, nil
 }
 
-// createSchema creates the necessary tables if they don't exist
-func createSchema(db *sql.DB) error {
-	usersTableSQL := `
+// OpenReadPool opens the read-only SQLite pool for plain reads, so they don't wait for connections of the
+// main pool that are busy writing. It returns nil when reads should go through the main pool: with
+// PostgreSQL, which manages concurrency itself, or when DBReadConns is 0. Call it after InitDB.
+func OpenReadPool(cfg *config.Config) (*sql.DB, error) {
+	if DialectOf(cfg) != SQLite || cfg.DBReadConns <= 0 {
+		return nil, nil
+	}
+	db, err := sql.Open("sqlite3", sqliteDSN(cfg, true))
+	if err != nil {
+		return nil, fmt.Errorf("error opening SQLite read pool: %w", err)
+	}
+	configurePool(db, cfg, cfg.DBReadConns)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to the SQLite read pool: %w", err)
+	}
+	slog.Info("SQLite read pool opened", "connections", cfg.DBReadConns)
+	return db, nil
+}
+
+// sqliteDSN builds the connection string carrying the configured pragmas. Transactions on the main pool
+// start with BEGIN IMMEDIATE: they take the write lock up front and wait for it under the busy timeout,
+// instead of failing with "database is locked" when a read transaction later tries to write.
+func sqliteDSN(cfg *config.Config, readOnly bool) string {
+	params := url.Values{}
+	params.Set("_busy_timeout", strconv.FormatInt(cfg.SQLiteBusyTimeout.Milliseconds(), 10))
+	if readOnly {
+		params.Set("mode", "ro")
+	} else {
+		if cfg.SQLiteJournalMode != "" {
+			params.Set("_journal_mode", cfg.SQLiteJournalMode)
+		}
+		if cfg.SQLiteSynchronous != "" {
+			params.Set("_synchronous", cfg.SQLiteSynchronous)
+		}
+		params.Set("_foreign_keys", strconv.FormatBool(cfg.SQLiteForeignKeys))
+		params.Set("_txlock", "immediate")
+	}
+	return "file:" + cfg.SQLiteDBPath + "?" + params.Encode()
+}
+
+// configurePool applies the pool limits; maxOpen 0 leaves the pool unbounded
+func configurePool(db *sql.DB, cfg *config.Config, maxOpen int) {
+	if maxOpen > 0 {
+		db.SetMaxOpenConns(maxOpen)
+		db.SetMaxIdleConns(maxOpen)
+	}
+	db.SetConnMaxLifetime(cfg.DBConnMaxLifetime)
+}
+
+// createSchema creates the necessary tables if they don't exist. The statements are written for SQLite
+// and translated for other dialects.
+func createSchema(db *sql.DB, dialect Dialect) error {
+	statements := []struct {
+		name string
+		sql  string
+	}{
+		{"users table", `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		email TEXT UNIQUE NOT NULL,
 		password_hash TEXT NOT NULL,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
+	);`},
+		// Structured workout plans; only one plan per user is active at a time
+		{"workout_plans table", `
+	CREATE TABLE IF NOT EXISTS workout_plans (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		title TEXT NOT NULL,
+		is_active INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Per-exercise prescription and progression state, rewritten after every logged session
+		{"plan_exercises table", `
+	CREATE TABLE IF NOT EXISTS plan_exercises (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		plan_id INTEGER NOT NULL REFERENCES workout_plans(id),
+		position INTEGER NOT NULL DEFAULT 0,
+		name TEXT NOT NULL,
+		muscle_group TEXT NOT NULL DEFAULT '',
+		scheme TEXT NOT NULL DEFAULT 'linear',
+		sets INTEGER NOT NULL DEFAULT 3,
+		target_reps INTEGER NOT NULL DEFAULT 5,
+		reps_min INTEGER NOT NULL DEFAULT 0,
+		reps_max INTEGER NOT NULL DEFAULT 0,
+		load_kg REAL NOT NULL DEFAULT 0,
+		target_rpe REAL NOT NULL DEFAULT 0,
+		one_rep_max_kg REAL NOT NULL DEFAULT 0,
+		wave_week INTEGER NOT NULL DEFAULT 0,
+		increment_kg REAL NOT NULL DEFAULT 0,
+		failure_streak INTEGER NOT NULL DEFAULT 0,
+		deload_after INTEGER NOT NULL DEFAULT 0,
+		deload_percent REAL NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		{"workout_sessions table", `
+	CREATE TABLE IF NOT EXISTS workout_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		plan_id INTEGER REFERENCES workout_plans(id),
+		performed_at DATETIME NOT NULL,
+		notes TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		{"workout_sets table", `
+	CREATE TABLE IF NOT EXISTS workout_sets (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL REFERENCES workout_sessions(id),
+		plan_exercise_id INTEGER REFERENCES plan_exercises(id),
+		exercise_name TEXT NOT NULL,
+		set_number INTEGER NOT NULL,
+		reps INTEGER NOT NULL,
+		load_kg REAL NOT NULL DEFAULT 0,
+		rpe REAL NOT NULL DEFAULT 0
+	);`},
+		// Physiological profile used for heart-rate zones and calorie estimates
+		{"user_profiles table", `
+	CREATE TABLE IF NOT EXISTS user_profiles (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id),
+		birth_date TEXT NOT NULL DEFAULT '',
+		sex TEXT NOT NULL DEFAULT '',
+		weight_kg REAL NOT NULL DEFAULT 0,
+		height_cm REAL NOT NULL DEFAULT 0,
+		resting_hr INTEGER NOT NULL DEFAULT 0,
+		max_hr INTEGER NOT NULL DEFAULT 0,
+		max_hr_formula TEXT NOT NULL DEFAULT '',
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Cardio activities; a file imported twice from the same device is recognised by its start time
+		{"activities table", `
+	CREATE TABLE IF NOT EXISTS activities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		source TEXT NOT NULL,
+		sport TEXT NOT NULL,
+		start_time DATETIME NOT NULL,
+		device TEXT NOT NULL DEFAULT '',
+		distance_m REAL NOT NULL DEFAULT 0,
+		duration_s REAL NOT NULL DEFAULT 0,
+		elevation_gain_m REAL NOT NULL DEFAULT 0,
+		avg_heart_rate INTEGER NOT NULL DEFAULT 0,
+		max_heart_rate INTEGER NOT NULL DEFAULT 0,
+		splits TEXT NOT NULL DEFAULT '[]',
+		heart_rate_samples TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, start_time, device)
+	);`},
+		// Health data brought in by the Apple Health and Google Fit importers; the unique keys make re-imports idempotent
+		{"body_weights table", `
+	CREATE TABLE IF NOT EXISTS body_weights (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		measured_at DATETIME NOT NULL,
+		weight_kg REAL NOT NULL,
+		source TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, measured_at, source)
+	);`},
+		{"daily_steps table", `
+	CREATE TABLE IF NOT EXISTS daily_steps (
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		day TEXT NOT NULL,
+		source TEXT NOT NULL,
+		steps INTEGER NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, day, source)
+	);`},
+		{"heart_rate_samples table", `
+	CREATE TABLE IF NOT EXISTS heart_rate_samples (
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		measured_at DATETIME NOT NULL,
+		source TEXT NOT NULL,
+		bpm INTEGER NOT NULL,
+		PRIMARY KEY (user_id, measured_at, source)
+	);`},
+		{"sleep_sessions table", `
+	CREATE TABLE IF NOT EXISTS sleep_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		start_time DATETIME NOT NULL,
+		end_time DATETIME NOT NULL,
+		stage TEXT NOT NULL,
+		source TEXT NOT NULL,
+		UNIQUE (user_id, start_time, stage, source)
+	);`},
+		// Background jobs (imports, exports) and their progress
+		{"jobs table", `
+	CREATE TABLE IF NOT EXISTS jobs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		kind TEXT NOT NULL,
+		status TEXT NOT NULL,
+		progress REAL NOT NULL DEFAULT 0,
+		message TEXT NOT NULL DEFAULT '',
+		result TEXT,
+		error TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		started_at DATETIME,
+		finished_at DATETIME
+	);`},
+		// Food catalog: shared foods have no user_id, custom foods belong to the user who created them
+		{"foods table", `
+	CREATE TABLE IF NOT EXISTS foods (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER REFERENCES users(id),
+		name TEXT NOT NULL,
+		brand TEXT NOT NULL DEFAULT '',
+		serving_unit TEXT NOT NULL DEFAULT 'serving',
+		calories REAL NOT NULL DEFAULT 0,
+		protein_g REAL NOT NULL DEFAULT 0,
+		carbs_g REAL NOT NULL DEFAULT 0,
+		fat_g REAL NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Meal diary; nutrition is copied onto each entry so later food edits don't rewrite history
+		{"diary_entries table", `
+	CREATE TABLE IF NOT EXISTS diary_entries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		entry_date TEXT NOT NULL,
+		meal TEXT NOT NULL,
+		food_id INTEGER REFERENCES foods(id),
+		name TEXT NOT NULL,
+		brand TEXT NOT NULL DEFAULT '',
+		quantity REAL NOT NULL DEFAULT 1,
+		unit TEXT NOT NULL DEFAULT 'serving',
+		calories REAL NOT NULL DEFAULT 0,
+		protein_g REAL NOT NULL DEFAULT 0,
+		carbs_g REAL NOT NULL DEFAULT 0,
+		fat_g REAL NOT NULL DEFAULT 0,
+		fiber_g REAL NOT NULL DEFAULT 0,
+		sugar_g REAL NOT NULL DEFAULT 0,
+		sodium_mg REAL NOT NULL DEFAULT 0,
+		source TEXT NOT NULL DEFAULT 'manual',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Uploaded images, so they can be exported and deleted with the account
+		{"uploads table", `
+	CREATE TABLE IF NOT EXISTS uploads (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		filename TEXT NOT NULL,
+		original_name TEXT NOT NULL DEFAULT '',
+		size_bytes INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Hashed single-use tokens (password reset links); the plain token only ever exists in the email
+		{"user_tokens table", `
+	CREATE TABLE IF NOT EXISTS user_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		purpose TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		expires_at DATETIME NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// TOTP second factor; enabled_at stays NULL until the user confirms a first code
+		{"user_mfa table", `
+	CREATE TABLE IF NOT EXISTS user_mfa (
+		user_id INTEGER PRIMARY KEY REFERENCES users(id),
+		totp_secret TEXT NOT NULL,
+		enabled_at DATETIME,
+		last_used_step INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		{"mfa_recovery_codes table", `
+	CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		code_hash TEXT NOT NULL,
+		used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (user_id, code_hash)
+	);`},
+		// Role-based access control; every account implicitly has the "user" role
+		{"roles table", `
+	CREATE TABLE IF NOT EXISTS roles (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT ''
+	);`},
+		{"permissions table", `
+	CREATE TABLE IF NOT EXISTS permissions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT ''
+	);`},
+		{"role_permissions table", `
+	CREATE TABLE IF NOT EXISTS role_permissions (
+		role_id INTEGER NOT NULL REFERENCES roles(id),
+		permission_id INTEGER NOT NULL REFERENCES permissions(id),
+		PRIMARY KEY (role_id, permission_id)
+	);`},
+		{"user_roles table", `
+	CREATE TABLE IF NOT EXISTS user_roles (
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		role_id INTEGER NOT NULL REFERENCES roles(id),
+		granted_by INTEGER, -- Admin who granted it; NULL for ADMIN_EMAILS at startup
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (user_id, role_id)
+	);`},
+		// JWT signing keys; a key is published in the JWKS before active_from and signs from then on
+		{"signing_keys table", `
+	CREATE TABLE IF NOT EXISTS signing_keys (
+		kid TEXT PRIMARY KEY,
+		algorithm TEXT NOT NULL,
+		private_key TEXT NOT NULL,
+		active_from DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Coach-client relationships; client_id is set once the invited address accepts.
+		// scopes is a comma-separated list of what the client lets the coach see and do.
+		{"coach_clients table", `
+	CREATE TABLE IF NOT EXISTS coach_clients (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		coach_id INTEGER NOT NULL REFERENCES users(id),
+		client_id INTEGER REFERENCES users(id),
+		invite_email TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		scopes TEXT NOT NULL DEFAULT '',
+		message TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		accepted_at DATETIME,
+		ended_at DATETIME
+	);`},
+		{"session_comments table", `
+	CREATE TABLE IF NOT EXISTS session_comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL REFERENCES workout_sessions(id),
+		author_id INTEGER NOT NULL REFERENCES users(id),
+		body TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Every password login, for throttling and auditing; user_id is NULL for unknown emails
+		{"login_attempts table", `
+	CREATE TABLE IF NOT EXISTS login_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL,
+		ip TEXT NOT NULL,
+		user_id INTEGER REFERENCES users(id),
+		outcome TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// One row per email and per client address, locked while a login attempt is being counted
+		{"login_locks table", `
+	CREATE TABLE IF NOT EXISTS login_locks (
+		lock_key TEXT PRIMARY KEY,
+		locked_at DATETIME NOT NULL
+	);`},
+		// Password reset requests per client address, for throttling
+		{"password_reset_requests table", `
+	CREATE TABLE IF NOT EXISTS password_reset_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ip TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`},
+		// Logins, one per issued token; revoked_at signs the device out
+		{"user_sessions table", `
+	CREATE TABLE IF NOT EXISTS user_sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_seen_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		revoked_at DATETIME
+	);`},
+		// Provider accounts linked to users for OpenID Connect sign-in
+		{"user_identities table", `
+	CREATE TABLE IF NOT EXISTS user_identities (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		provider TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		email TEXT NOT NULL DEFAULT '',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_login_at DATETIME,
+		UNIQUE (provider, subject)
+	);`},
+		// OpenID Connect logins in progress; user_id is set when linking a provider to a signed-in account
+		{"oidc_states table", `
+	CREATE TABLE IF NOT EXISTS oidc_states (
+		state_hash TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		nonce TEXT NOT NULL,
+		code_verifier TEXT NOT NULL,
+		user_id INTEGER REFERENCES users(id),
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`},
+		// Personal API keys for scripts and integrations; only the hash of the key is stored
+		{"api_keys table", `
+	CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL,
+		expires_at DATETIME,
+		last_used_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		revoked_at DATETIME
+	);`},
+		// Append-only record of security-relevant actions; actor_id has no foreign key so entries outlive
+		// deleted accounts
+		{"audit_log table", `
+	CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor_id INTEGER,
+		auth_method TEXT NOT NULL DEFAULT '',
+		action TEXT NOT NULL,
+		target_type TEXT NOT NULL DEFAULT '',
+		target_id TEXT NOT NULL DEFAULT '',
+		detail TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		request_id TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL
+	);`},
+		{"workout_sessions index", `CREATE INDEX IF NOT EXISTS idx_workout_sessions_user_performed ON workout_sessions(user_id, performed_at);`},
+		{"workout_sets index", `CREATE INDEX IF NOT EXISTS idx_workout_sets_session ON workout_sets(session_id);`},
+		{"foods index", `CREATE INDEX IF NOT EXISTS idx_foods_name ON foods(name);`},
+		{"diary_entries index", `CREATE INDEX IF NOT EXISTS idx_diary_entries_user_date ON diary_entries(user_id, entry_date);`},
+		{"coach_clients coach index", `CREATE INDEX IF NOT EXISTS idx_coach_clients_coach ON coach_clients(coach_id, status);`},
+		{"coach_clients client index", `CREATE INDEX IF NOT EXISTS idx_coach_clients_client ON coach_clients(client_id, status);`},
+		{"session_comments index", `CREATE INDEX IF NOT EXISTS idx_session_comments_session ON session_comments(session_id);`},
+		{"user_sessions index", `CREATE INDEX IF NOT EXISTS idx_user_sessions_user ON user_sessions(user_id, expires_at);`},
+		{"user_identities index", `CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);`},
+		{"api_keys index", `CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);`},
+		{"login_attempts email index", `CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts(email, created_at);`},
+		{"login_attempts ip index", `CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip, created_at);`},
+		{"login_attempts created index", `CREATE INDEX IF NOT EXISTS idx_login_attempts_created ON login_attempts(created_at);`},
+		{"password_reset_requests ip index", `CREATE INDEX IF NOT EXISTS idx_password_reset_requests_ip ON password_reset_requests(ip, created_at);`},
+		{"audit_log actor index", `CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_id, id);`},
+		{"audit_log action index", `CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, id);`},
+		{"audit_log target index", `CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target_type, target_id, id);`},
+		{"audit_log created index", `CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);`},
+	}
 
-	_, err := db.Exec(usersTableSQL)
-	if err != nil {
-		return fmt.Errorf("failed to create users table: %w", err)
+	for _, t := range statements {
+		if _, err := db.Exec(dialect.DDL(t.sql)); err != nil {
+			return fmt.Errorf("failed to create %s: %w", t.name, err)
+		}
+	}
+	if err := makeAppendOnly(db, dialect, "audit_log"); err != nil {
+		return err
+	}
+
+	// Columns added after a table was first released; CREATE TABLE IF NOT EXISTS leaves existing tables untouched
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+		backfill   string // Optional statement run once, right after the column is added
+	}{
+		{"workout_sessions", "session_rpe", "REAL NOT NULL DEFAULT 0", ""},
+		{"workout_sessions", "duration_min", "INTEGER NOT NULL DEFAULT 0", ""},
+		{"workout_sets", "muscle_group", "TEXT NOT NULL DEFAULT ''", ""},
+		{"users", "tokens_valid_after", "DATETIME", ""}, // JWTs issued earlier are rejected
+		// Accounts created before verification existed are treated as verified
+		{"users", "email_verified_at", "DATETIME", "UPDATE users SET email_verified_at = created_at"},
+		{"user_tokens", "attempts", "INTEGER NOT NULL DEFAULT 0", ""},   // Failed uses of an MFA challenge
+		{"user_tokens", "first_factor", "TEXT NOT NULL DEFAULT ''", ""}, // How an MFA challenge's holder signed in
+		{"workout_plans", "assigned_by", "INTEGER", ""},                 // Coach who assigned the plan
+		{"workout_plans", "sessions_per_week", "INTEGER NOT NULL DEFAULT 0", ""},
+	}
+	for _, c := range columns {
+		added, err := addColumnIfMissing(db, dialect, c.table, c.column, dialect.DDL(c.definition))
+		if err != nil {
+			return err
+		}
+		if added && c.backfill != "" {
+			if _, err := db.Exec(c.backfill); err != nil {
+				return fmt.Errorf("failed to backfill %s.%s: %w", c.table, c.column, err)
+			}
+		}
+	}
+	if err := seedRoles(db); err != nil {
+		return err
+	}
+	return seedFoods(db)
+}
+
+// makeAppendOnly adds triggers that reject UPDATE and DELETE on a table, so rows can only be added.
+// Triggers have no portable syntax, so each dialect gets its own.
+func makeAppendOnly(db *sql.DB, dialect Dialect, table string) error {
+	var statements []string
+	if dialect == Postgres {
+		statements = []string{
+			`CREATE OR REPLACE FUNCTION reject_append_only_change() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+			END
+			$$ LANGUAGE plpgsql`,
+			fmt.Sprintf(`DROP TRIGGER IF EXISTS %[1]s_append_only ON %[1]s`, table),
+			fmt.Sprintf(`CREATE TRIGGER %[1]s_append_only BEFORE UPDATE OR DELETE ON %[1]s
+				FOR EACH ROW EXECUTE FUNCTION reject_append_only_change()`, table),
+		}
+	} else {
+		for _, op := range []string{"UPDATE", "DELETE"} {
+			statements = append(statements, fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %[1]s_no_%[2]s BEFORE %[3]s ON %[1]s
+				BEGIN SELECT RAISE(ABORT, '%[1]s is append-only'); END`, table, strings.ToLower(op), op))
+		}
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to make %s append-only: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// seedRoles creates the built-in roles and permissions and restores their default grants
+func seedRoles(db *sql.DB) error {
+	for name, description := range auth.PermissionDescriptions {
+		_, err := db.Exec("INSERT INTO permissions (name, description) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET description = excluded.description",
+			name, description)
+		if err != nil {
+			return fmt.Errorf("failed to seed permission %s: %w", name, err)
+		}
+	}
+	for _, role := range auth.DefaultRoles {
+		_, err := db.Exec("INSERT INTO roles (name, description) VALUES (?, ?) ON CONFLICT (name) DO UPDATE SET description = excluded.description",
+			role.Name, role.Description)
+		if err != nil {
+			return fmt.Errorf("failed to seed role %s: %w", role.Name, err)
+		}
+		for _, perm := range role.Permissions {
+			_, err := db.Exec(`INSERT INTO role_permissions (role_id, permission_id)
+				SELECT r.id, p.id FROM roles r, permissions p WHERE r.name = ? AND p.name = ?
+				ON CONFLICT (role_id, permission_id) DO NOTHING`, role.Name, perm)
+			if err != nil {
+				return fmt.Errorf("failed to grant %s to role %s: %w", perm, role.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// grantBootstrapAdmins gives the admin role to the accounts listed in ADMIN_EMAILS so a fresh
+// install has someone who can assign roles. Addresses without an account yet are skipped.
+func grantBootstrapAdmins(db *sql.DB, emails []string) error {
+	for _, email := range emails {
+		res, err := db.Exec(`INSERT INTO user_roles (user_id, role_id, created_at)
+			SELECT u.id, r.id, ? FROM users u, roles r WHERE LOWER(u.email) = ? AND r.name = ?
+			ON CONFLICT (user_id, role_id) DO NOTHING`, time.Now().UTC(), strings.ToLower(email), auth.RoleAdmin)
+		if err != nil {
+			return fmt.Errorf("failed to grant admin to %s: %w", email, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			slog.Info("Granted admin role from ADMIN_EMAILS", "email", email)
+		}
+	}
+	return nil
+}
+
+// seedFoods fills an empty shared food catalog with common staples so diary imports have something to match
+func seedFoods(db *sql.DB) error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM foods WHERE user_id IS NULL").Scan(&count); err != nil {
+		return fmt.Errorf("failed to inspect foods table: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	foods := []struct {
+		name, unit                    string
+		calories, protein, carbs, fat float64
+	}{
+		{"Chicken breast, cooked", "100 g", 165, 31, 0, 3.6},
+		{"Egg, whole", "1 large", 72, 6.3, 0.4, 4.8},
+		{"Oats, rolled", "40 g", 150, 5, 27, 2.5},
+		{"White rice, cooked", "100 g", 130, 2.7, 28, 0.3},
+		{"Brown rice, cooked", "100 g", 123, 2.7, 26, 1},
+		{"Banana", "1 medium", 105, 1.3, 27, 0.4},
+		{"Apple", "1 medium", 95, 0.5, 25, 0.3},
+		{"Milk, semi-skimmed", "250 ml", 122, 8.5, 12, 4.5},
+		{"Greek yogurt, plain", "170 g", 100, 17, 6, 0.7},
+		{"Whey protein", "30 g", 120, 24, 3, 1.5},
+		{"Peanut butter", "32 g", 190, 7, 7, 16},
+		{"Almonds", "28 g", 164, 6, 6, 14},
+		{"Salmon, cooked", "100 g", 206, 22, 0, 12},
+		{"Broccoli", "100 g", 34, 2.8, 7, 0.4},
+		{"Sweet potato, baked", "100 g", 90, 2, 21, 0.2},
+		{"Whole wheat bread", "1 slice", 80, 4, 14, 1},
+		{"Olive oil", "1 tbsp", 119, 0, 0, 13.5},
+		{"Lentils, cooked", "100 g", 116, 9, 20, 0.4},
+	}
+	for _, f := range foods {
+		_, err := db.Exec("INSERT INTO foods (name, serving_unit, calories, protein_g, carbs_g, fat_g) VALUES (?, ?, ?, ?, ?, ?)",
+			f.name, f.unit, f.calories, f.protein, f.carbs, f.fat)
+		if err != nil {
+			return fmt.Errorf("failed to seed foods: %w", err)
+		}
 	}
 	return nil
 }
+
+// addColumnIfMissing adds a column to an existing table unless it is already there and reports whether it did
+func addColumnIfMissing(db *sql.DB, dialect Dialect, table, column, definition string) (bool, error) {
+	exists, err := columnExists(db, dialect, table, column)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s table: %w", table, err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return false, fmt.Errorf("failed to add %s.%s column: %w", table, column, err)
+	}
+	return true, nil
+}
+
+// columnExists reports whether table has the column, from PRAGMA table_info on SQLite and the
+// information schema on PostgreSQL
+func columnExists(db *sql.DB, dialect Dialect, table, column string) (bool, error) {
+	if dialect == Postgres {
+		var n int
+		err := db.QueryRow("SELECT COUNT(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?",
+			table, column).Scan(&n)
+		return n > 0, err
+	}
+
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
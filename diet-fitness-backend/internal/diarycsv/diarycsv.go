@@ -0,0 +1,278 @@
+// --- diet-fitness-backend/internal/diarycsv/diarycsv.go ---
+package diarycsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// Supported CSV formats
+const (
+	FormatMyFitnessPal = "myfitnesspal"
+	FormatGeneric      = "generic"
+)
+
+// GenericHeader is the documented generic diary schema, used for both import and export:
+//
+//	date       YYYY-MM-DD (required)
+//	meal       breakfast, lunch, dinner, snacks or any other meal name (required)
+//	food       food name (required); matched case-insensitively against the food catalog
+//	brand      optional brand, narrows the food match
+//	quantity   number of servings (default 1)
+//	unit       serving description, e.g. "100 g" (default "serving")
+//	calories   kcal for the quantity eaten; leave blank to compute it from the matched food
+//	protein_g, carbs_g, fat_g, fiber_g, sugar_g, sodium_mg   optional totals for the quantity eaten
+var GenericHeader = []string{"date", "meal", "food", "brand", "quantity", "unit", "calories",
+	"protein_g", "carbs_g", "fat_g", "fiber_g", "sugar_g", "sodium_mg"}
+
+// ErrUnknownFormat is returned when the header matches neither the MyFitnessPal export nor the generic schema
+var ErrUnknownFormat = errors.New(`unrecognised CSV header; expected a MyFitnessPal export or the generic schema (` +
+	`date,meal,food,brand,quantity,unit,calories,protein_g,carbs_g,fat_g,fiber_g,sugar_g,sodium_mg)`)
+
+// Row is one parsed diary line. HasNutrition is false when the calories column was blank,
+// meaning the nutrition should come from the matched food.
+type Row struct {
+	Line         int
+	Entry        models.DiaryEntry
+	HasNutrition bool
+}
+
+// columnAliases maps our field names to the header spellings we accept, in lower case.
+// MyFitnessPal's "Nutrition Summary" export has one row per meal and day; third-party
+// exporters add a per-food "Food" column.
+var columnAliases = map[string][]string{
+	"date":      {"date"},
+	"meal":      {"meal"},
+	"food":      {"food", "food name", "name", "description"},
+	"brand":     {"brand"},
+	"quantity":  {"quantity", "servings", "serving qty"},
+	"unit":      {"unit", "serving", "serving size"},
+	"calories":  {"calories", "energy (kcal)", "kcal"},
+	"protein_g": {"protein_g", "protein (g)", "protein"},
+	"carbs_g":   {"carbs_g", "carbohydrates (g)", "carbohydrates", "carbs"},
+	"fat_g":     {"fat_g", "fat (g)", "fat"},
+	"fiber_g":   {"fiber_g", "fiber", "fibre"},
+	"sugar_g":   {"sugar_g", "sugar"},
+	"sodium_mg": {"sodium_mg", "sodium (mg)", "sodium"},
+}
+
+// dateLayouts are the date formats accepted in the date column; MyFitnessPal uses the account's locale
+var dateLayouts = []string{"2006-01-02", "1/2/2006", "01/02/2006", "2006/01/02", "02.01.2006"}
+
+// Parse reads a diary CSV and returns its format and rows. Rows that can't be parsed are reported
+// in rowErrs and skipped; err is only set when the file as a whole is unreadable.
+func Parse(r io.Reader) (format string, rows []Row, rowErrs []models.CSVRowError, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1 // MyFitnessPal adds trailing columns inconsistently
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return "", nil, nil, errors.New("CSV file is empty")
+	}
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	index := map[string]int{}
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+		for field, aliases := range columnAliases {
+			for _, a := range aliases {
+				if h == a {
+					if _, seen := index[field]; !seen {
+						index[field] = i
+					}
+				}
+			}
+		}
+	}
+
+	_, hasDate := index["date"]
+	_, hasMeal := index["meal"]
+	_, hasFood := index["food"]
+	_, hasCalories := index["calories"]
+	switch {
+	case hasDate && hasMeal && hasCalories && isMyFitnessPal(header):
+		format = FormatMyFitnessPal
+	case hasDate && hasMeal && hasFood:
+		format = FormatGeneric
+	default:
+		return "", nil, nil, ErrUnknownFormat
+	}
+
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				rowErrs = append(rowErrs, models.CSVRowError{Line: parseErr.Line, Message: parseErr.Err.Error()})
+				continue
+			}
+			return format, rows, rowErrs, fmt.Errorf("error reading CSV: %w", err)
+		}
+		if blank(record) {
+			continue
+		}
+
+		line, _ := cr.FieldPos(0)
+		row, err := parseRecord(record, index, format)
+		if err != nil {
+			rowErrs = append(rowErrs, models.CSVRowError{Line: line, Message: err.Error()})
+			continue
+		}
+		row.Line = line
+		rows = append(rows, row)
+	}
+	return format, rows, rowErrs, nil
+}
+
+// isMyFitnessPal recognises the export by its unit-suffixed column names
+func isMyFitnessPal(header []string) bool {
+	for _, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "fat (g)", "carbohydrates (g)", "protein (g)":
+			return true
+		}
+	}
+	return false
+}
+
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func parseRecord(record []string, index map[string]int, format string) (Row, error) {
+	get := func(field string) string {
+		i, ok := index[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(field string) (float64, error) {
+		v := strings.ReplaceAll(get(field), ",", "")
+		if v == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil || !finite(n) || n < 0 {
+			return 0, fmt.Errorf("%s must be a non-negative number", field)
+		}
+		return n, nil
+	}
+
+	date, err := ParseDate(get("date"))
+	if err != nil {
+		return Row{}, err
+	}
+	meal := NormalizeMeal(get("meal"))
+	if meal == "" {
+		return Row{}, errors.New("meal is required")
+	}
+
+	e := models.DiaryEntry{
+		Date:     date,
+		Meal:     meal,
+		Name:     get("food"),
+		Brand:    get("brand"),
+		Unit:     get("unit"),
+		Quantity: 1,
+		Source:   "csv",
+	}
+	if format == FormatMyFitnessPal {
+		e.Source = FormatMyFitnessPal
+		if e.Name == "" {
+			// The standard export only has meal totals
+			e.Name = fmt.Sprintf("MyFitnessPal %s", meal)
+		}
+	}
+	if e.Name == "" {
+		return Row{}, errors.New("food is required")
+	}
+	if e.Unit == "" {
+		e.Unit = "serving"
+	}
+	if v := get("quantity"); v != "" {
+		q, err := strconv.ParseFloat(v, 64)
+		if err != nil || !finite(q) || q <= 0 {
+			return Row{}, errors.New("quantity must be a positive number")
+		}
+		e.Quantity = q
+	}
+
+	fields := []struct {
+		name string
+		dst  *float64
+	}{
+		{"calories", &e.Calories}, {"protein_g", &e.ProteinG}, {"carbs_g", &e.CarbsG}, {"fat_g", &e.FatG},
+		{"fiber_g", &e.FiberG}, {"sugar_g", &e.SugarG}, {"sodium_mg", &e.SodiumMg},
+	}
+	for _, f := range fields {
+		if *f.dst, err = number(f.name); err != nil {
+			return Row{}, err
+		}
+	}
+	return Row{Entry: e, HasNutrition: get("calories") != ""}, nil
+}
+
+// finite rejects the NaN and Inf that ParseFloat accepts
+func finite(n float64) bool {
+	return !math.IsNaN(n) && !math.IsInf(n, 0)
+}
+
+// ParseDate accepts the date formats used by common exporters and returns YYYY-MM-DD
+func ParseDate(v string) (string, error) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q; use YYYY-MM-DD", v)
+}
+
+// NormalizeMeal lower-cases meal names and folds common variants onto the four standard meals
+func NormalizeMeal(v string) string {
+	m := strings.ToLower(strings.TrimSpace(v))
+	switch m {
+	case "snack", "snacks":
+		return "snacks"
+	case "supper":
+		return "dinner"
+	}
+	return m
+}
+
+// Write exports diary entries in the generic schema
+func Write(w io.Writer, entries []models.DiaryEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(GenericHeader); err != nil {
+		return err
+	}
+	num := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, e := range entries {
+		record := []string{e.Date, e.Meal, e.Name, e.Brand, num(e.Quantity), e.Unit, num(e.Calories),
+			num(e.ProteinG), num(e.CarbsG), num(e.FatG), num(e.FiberG), num(e.SugarG), num(e.SodiumMg)}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,268 @@
+// --- diet-fitness-backend/internal/handlers/account.go ---
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/account"
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/jobs"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	accountExportKind = "account_export"
+	exportRetention   = 7 * 24 * time.Hour // Export zips are deleted a week after they were created
+)
+
+// ExportAccount queues a background job that packages all of the user's data into a zip
+func ExportAccount(db *sql.DB, cfg *config.Config, runner *jobs.Runner, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		removeExpiredExports(cfg.ExportDir)
+
+		jobID, err := runner.Submit(userID, accountExportKind, func(ctx context.Context, progress jobs.ProgressFunc) (interface{}, error) {
+			return account.Export(ctx, db, userID, cfg.UploadDir, cfg.ExportDir, progress)
+		})
+		if err != nil {
+			if errors.Is(err, jobs.ErrQueueFull) {
+				respondWithJSON(w, http.StatusServiceUnavailable, models.ErrorResponse{Message: err.Error()})
+				return
+			}
+			slog.ErrorContext(r.Context(), "Error queueing data export", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error starting export"})
+			return
+		}
+
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionExportRequested, TargetType: audit.TargetJob, TargetID: strconv.Itoa(jobID)})
+		respondWithJSON(w, http.StatusAccepted, models.JobAcceptedResponse{
+			JobID:     jobID,
+			Status:    jobs.StatusQueued,
+			StatusURL: fmt.Sprintf("/api/jobs/%d", jobID),
+			ResultURL: fmt.Sprintf("/api/account/export/%d/download", jobID),
+		})
+	}
+}
+
+// DownloadAccountExport serves the zip produced by a finished export job
+func DownloadAccountExport(db *sql.DB, cfg *config.Config, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid job ID"})
+			return
+		}
+
+		job, err := jobs.Get(db, userID, id)
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && job.Kind != accountExportKind) {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Export not found"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading export job", "job_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading export"})
+			return
+		}
+		if job.Status != jobs.StatusSucceeded {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: fmt.Sprintf("Export is not ready (status: %s)", job.Status)})
+			return
+		}
+
+		var summary account.ExportSummary
+		if err := json.Unmarshal(job.Result, &summary); err != nil || summary.File == "" {
+			slog.ErrorContext(r.Context(), "Export job has no usable result", "job_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading export"})
+			return
+		}
+		path := filepath.Join(cfg.ExportDir, filepath.Base(summary.File))
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > exportRetention {
+			os.Remove(path)
+			respondWithJSON(w, http.StatusGone, models.ErrorResponse{Message: "Export has expired; request a new one"})
+			return
+		}
+		if _, err := os.Stat(path); err != nil {
+			respondWithJSON(w, http.StatusGone, models.ErrorResponse{Message: "Export is no longer available; request a new one"})
+			return
+		}
+
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionExportDownloaded, TargetType: audit.TargetJob, TargetID: strconv.Itoa(id)})
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="fitplan-export.zip"`)
+		http.ServeFile(w, r, path)
+	}
+}
+
+// recentSignInWindow is how fresh a login must be to delete an account that has no password
+const recentSignInWindow = 10 * time.Minute
+
+// DeleteAccount permanently erases the user's data, uploaded files and the account itself.
+// The password must be re-entered; existing tokens stop working because the account is gone.
+// Accounts created through a sign-in provider have no password, so they confirm with a two-factor
+// code, or by having signed in within the last few minutes.
+func DeleteAccount(db *sql.DB, users store.Users, cfg *config.Config, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var payload models.DeleteAccountPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+
+		user, err := users.ByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user for deletion", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+			return
+		}
+		// Re-entered credentials count towards the login backoff, so a stolen token can't be used to guess them
+		recentSignIn := principal.Method == auth.AuthMethodToken && time.Since(principal.SignedInAt) <= recentSignInWindow
+		switch {
+		case user.PasswordHash != "" && payload.Password == "":
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Password is required to delete the account"})
+			return
+		case user.PasswordHash == "" && !recentSignIn && payload.Code == "":
+			respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: fmt.Sprintf("Sign in again (within %d minutes) or enter your two-factor code to delete the account", int(recentSignInWindow.Minutes()))})
+			return
+		case user.PasswordHash != "" || !recentSignIn:
+			if !confirmDeletion(w, r, db, cfg, auditLog, user, payload) {
+				return
+			}
+		}
+
+		// A running import would keep writing rows for the deleted user
+		var active int
+		if err := db.QueryRow("SELECT COUNT(*) FROM jobs WHERE user_id = ? AND status IN (?, ?)", userID, jobs.StatusQueued, jobs.StatusRunning).Scan(&active); err != nil {
+			slog.ErrorContext(r.Context(), "Error checking jobs", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+			return
+		}
+		if active > 0 {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Wait for running imports and exports to finish before deleting the account"})
+			return
+		}
+
+		// Collect the files first; the uploads table that lists them is deleted with the account
+		files, err := account.UploadedFiles(db, userID, cfg.UploadDir)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing files", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+			return
+		}
+		exports, _ := filepath.Glob(filepath.Join(cfg.ExportDir, fmt.Sprintf("export_user_%d_*", userID)))
+		imports, _ := filepath.Glob(filepath.Join(cfg.ImportDir, fmt.Sprintf("user_%d_*", userID)))
+		files = append(append(files, exports...), imports...)
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting account deletion", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+			return
+		}
+		defer tx.Rollback()
+
+		if err := account.Delete(tx, userID); err != nil {
+			slog.ErrorContext(r.Context(), "Error deleting account", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing deletion", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+			return
+		}
+
+		if err := account.RemoveFiles(files); err != nil {
+			slog.ErrorContext(r.Context(), "Error removing files", "user_id", userID, "err", err)
+		}
+		slog.InfoContext(r.Context(), "User deleted their account", "user_id", userID, "files_removed", len(files))
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionAccountDeleted, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID)})
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Account and all associated data deleted"})
+	}
+}
+
+// confirmDeletion checks the password, or the two-factor code of an account without one, under the
+// login backoff. When they are wrong it answers the request and returns false.
+func confirmDeletion(w http.ResponseWriter, r *http.Request, db *sql.DB, cfg *config.Config, auditLog *audit.Log, user models.User, payload models.DeleteAccountPayload) bool {
+	email, ip := strings.ToLower(user.Email), auth.ClientIP(r, cfg.TrustProxyHeaders)
+	attemptID, wait, err := auth.BeginLogin(db, loginLimits(cfg), email, ip)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+		return false
+	}
+	if wait > 0 {
+		respondThrottled(w, r, auditLog, email, ip, wait)
+		return false
+	}
+
+	if user.PasswordHash != "" {
+		if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.Password)) != nil {
+			settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginBadPassword)
+			respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Incorrect password"})
+			return false
+		}
+	} else {
+		valid, err := verifySecondFactor(db, user.ID, payload.Code, "")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error verifying second factor", "user_id", user.ID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error deleting account"})
+			return false
+		}
+		if !valid {
+			settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginBadMFACode)
+			respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Invalid two-factor code"})
+			return false
+		}
+	}
+	settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginReauthenticated)
+	return true
+}
+
+// removeExpiredExports deletes export zips past the retention period
+func removeExpiredExports(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || e.IsDir() || time.Since(info.ModTime()) <= exportRetention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, e.Name())); err != nil {
+			slog.Error("Error removing expired export", "file", e.Name(), "err", err)
+		}
+	}
+}
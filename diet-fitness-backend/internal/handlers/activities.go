@@ -0,0 +1,323 @@
+// --- diet-fitness-backend/internal/handlers/activities.go ---
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diet-fitness-backend/internal/activityfile"
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/physiology"
+
+	"github.com/gorilla/mux"
+)
+
+const maxActivityFileSize = 25 << 20 // 25 MB; long FIT recordings with 1s sampling stay well below this
+
+const activityColumns = `id, user_id, source, sport, start_time, device, distance_m, duration_s, elevation_gain_m,
+	avg_heart_rate, max_heart_rate, splits, created_at`
+
+// ImportActivity accepts a GPX, TCX or FIT file and stores it as a cardio activity
+func ImportActivity(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxActivityFileSize+1<<20)
+		if err := r.ParseMultipartForm(maxActivityFileSize); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "File upload error: " + err.Error()})
+			return
+		}
+		file, handler, err := r.FormFile("file")
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Error retrieving file from form: " + err.Error()})
+			return
+		}
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, maxActivityFileSize+1))
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Error reading uploaded file"})
+			return
+		}
+		if len(data) > maxActivityFileSize {
+			respondWithJSON(w, http.StatusRequestEntityTooLarge, models.ErrorResponse{Message: "Activity file is too large"})
+			return
+		}
+
+		activity, err := activityfile.Parse(handler.Filename, data)
+		if err != nil {
+			respondWithJSON(w, http.StatusUnprocessableEntity, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+		activity.UserID = userID
+
+		id, created, err := insertActivity(db, activity)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving imported activity", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving activity"})
+			return
+		}
+		if !created {
+			respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"message":     "This activity has already been imported",
+				"activity_id": id,
+			})
+			return
+		}
+
+		stored, err := loadAnalyzedActivity(db, userID, id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading imported activity", "activity_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading activity"})
+			return
+		}
+		slog.InfoContext(r.Context(), "User imported activity", "user_id", userID, "source", activity.Source, "activity_id", id, "distance_m", activity.DistanceM, "duration_s", activity.DurationS)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionActivityImported, TargetType: audit.TargetActivity, TargetID: strconv.Itoa(id), Detail: activity.Source})
+		respondWithJSON(w, http.StatusCreated, stored)
+	}
+}
+
+// CreateActivity stores a manually entered cardio activity
+func CreateActivity(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var payload models.ManualActivityPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		if payload.StartTime.IsZero() || payload.StartTime.After(time.Now().Add(time.Hour)) {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "start_time is required and must not be in the future"})
+			return
+		}
+		if payload.DurationS <= 0 || payload.DistanceM < 0 || payload.ElevationGainM < 0 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "duration_s must be positive and distance/elevation must not be negative"})
+			return
+		}
+		if payload.AvgHeartRate < 0 || payload.AvgHeartRate > 250 || payload.MaxHeartRate < 0 || payload.MaxHeartRate > 250 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Heart rates must be between 0 and 250"})
+			return
+		}
+
+		activity := models.Activity{
+			UserID:         userID,
+			Source:         "manual",
+			Sport:          activityfile.NormalizeSport(payload.Sport),
+			StartTime:      payload.StartTime.UTC().Truncate(time.Second),
+			Device:         "manual",
+			DistanceM:      payload.DistanceM,
+			DurationS:      payload.DurationS,
+			ElevationGainM: payload.ElevationGainM,
+			AvgHeartRate:   payload.AvgHeartRate,
+			MaxHeartRate:   max(payload.MaxHeartRate, payload.AvgHeartRate),
+			Splits:         []models.ActivitySplit{},
+		}
+		id, created, err := insertActivity(db, activity)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving manual activity", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving activity"})
+			return
+		}
+		if !created {
+			respondWithJSON(w, http.StatusConflict, map[string]interface{}{
+				"message":     "An activity starting at this time already exists",
+				"activity_id": id,
+			})
+			return
+		}
+
+		stored, err := loadAnalyzedActivity(db, userID, id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading activity", "activity_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading activity"})
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, stored)
+	}
+}
+
+// ListActivities returns the user's cardio activities, newest first (without heart-rate samples)
+func ListActivities(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		serveActivities(db, w, r, userID)
+	}
+}
+
+// serveActivities writes a user's latest activities; shared with the coach's view of a client
+func serveActivities(db *sql.DB, w http.ResponseWriter, r *http.Request, userID int) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "limit must be between 1 and 100"})
+			return
+		}
+		limit = n
+	}
+
+	rows, err := db.Query("SELECT "+activityColumns+" FROM activities WHERE user_id = ? ORDER BY start_time DESC, id DESC LIMIT ?", userID, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error listing activities", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading activities"})
+		return
+	}
+	defer rows.Close()
+
+	activities := []models.Activity{}
+	for rows.Next() {
+		a, err := scanActivity(rows)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning activity", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading activities"})
+			return
+		}
+		activities = append(activities, a)
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "Error listing activities", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading activities"})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string][]models.Activity{"activities": activities})
+}
+
+// GetActivity returns a single activity including its heart-rate samples, zones, TRIMP and calorie estimate
+func GetActivity(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid activity ID"})
+			return
+		}
+
+		activity, err := loadAnalyzedActivity(db, userID, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Activity not found"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading activity", "activity_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading activity"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, activity)
+	}
+}
+
+// insertActivity stores an activity unless one with the same start time and device already exists.
+// It returns the ID of the new or existing row and whether a row was created.
+func insertActivity(q execQueryer, a models.Activity) (int, bool, error) {
+	splits, err := json.Marshal(a.Splits)
+	if err != nil {
+		return 0, false, fmt.Errorf("error encoding splits: %w", err)
+	}
+	samples := a.HeartRate
+	if samples == nil {
+		samples = []models.HeartRateSample{}
+	}
+	hr, err := json.Marshal(samples)
+	if err != nil {
+		return 0, false, fmt.Errorf("error encoding heart-rate samples: %w", err)
+	}
+
+	var id int
+	err = q.QueryRow(`INSERT INTO activities (user_id, source, sport, start_time, device, distance_m, duration_s, elevation_gain_m,
+		avg_heart_rate, max_heart_rate, splits, heart_rate_samples)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, start_time, device) DO NOTHING RETURNING id`,
+		a.UserID, a.Source, a.Sport, a.StartTime.UTC(), a.Device, a.DistanceM, a.DurationS, a.ElevationGainM,
+		a.AvgHeartRate, a.MaxHeartRate, string(splits), string(hr)).Scan(&id)
+	if err == nil {
+		return id, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, false, err
+	}
+
+	// Nothing was inserted: the activity is a duplicate
+	err = q.QueryRow("SELECT id FROM activities WHERE user_id = ? AND start_time = ? AND device = ?", a.UserID, a.StartTime.UTC(), a.Device).Scan(&id)
+	if err != nil {
+		return 0, false, fmt.Errorf("error looking up duplicate activity: %w", err)
+	}
+	return id, false, nil
+}
+
+func scanActivity(rows *sql.Rows) (models.Activity, error) {
+	var a models.Activity
+	var splits string
+	if err := rows.Scan(&a.ID, &a.UserID, &a.Source, &a.Sport, &a.StartTime, &a.Device, &a.DistanceM, &a.DurationS, &a.ElevationGainM,
+		&a.AvgHeartRate, &a.MaxHeartRate, &splits, &a.CreatedAt); err != nil {
+		return a, err
+	}
+	a.Splits = []models.ActivitySplit{}
+	if err := json.Unmarshal([]byte(splits), &a.Splits); err != nil {
+		return a, fmt.Errorf("error decoding splits of activity %d: %w", a.ID, err)
+	}
+	return a, nil
+}
+
+// loadActivity fetches one of the user's activities with its heart-rate samples
+func loadActivity(q execQueryer, userID, id int) (models.Activity, error) {
+	var (
+		a      models.Activity
+		splits string
+		hr     string
+	)
+	err := q.QueryRow("SELECT "+activityColumns+", heart_rate_samples FROM activities WHERE id = ? AND user_id = ?", id, userID).
+		Scan(&a.ID, &a.UserID, &a.Source, &a.Sport, &a.StartTime, &a.Device, &a.DistanceM, &a.DurationS, &a.ElevationGainM,
+			&a.AvgHeartRate, &a.MaxHeartRate, &splits, &a.CreatedAt, &hr)
+	if err != nil {
+		return a, err
+	}
+	a.Splits = []models.ActivitySplit{}
+	if err := json.Unmarshal([]byte(splits), &a.Splits); err != nil {
+		return a, fmt.Errorf("error decoding splits of activity %d: %w", a.ID, err)
+	}
+	if err := json.Unmarshal([]byte(hr), &a.HeartRate); err != nil {
+		return a, fmt.Errorf("error decoding heart-rate samples of activity %d: %w", a.ID, err)
+	}
+	return a, nil
+}
+
+// loadAnalyzedActivity loads an activity and attaches its physiological analysis based on the current profile
+func loadAnalyzedActivity(q execQueryer, userID, id int) (models.Activity, error) {
+	a, err := loadActivity(q, userID, id)
+	if err != nil {
+		return a, err
+	}
+	profile, err := loadProfile(q, userID)
+	if err != nil {
+		return a, fmt.Errorf("error loading profile: %w", err)
+	}
+	analysis := physiology.Analyze(profile, a)
+	a.Analysis = &analysis
+	return a, nil
+}
@@ -0,0 +1,294 @@
+// --- diet-fitness-backend/internal/handlers/admin.go ---
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+// ListRoles returns every role with the permissions it grants
+func ListRoles(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rows, err := db.Query(`SELECT r.name, r.description, p.name FROM roles r
+			LEFT JOIN role_permissions rp ON rp.role_id = r.id
+			LEFT JOIN permissions p ON p.id = rp.permission_id
+			ORDER BY r.id, p.name`)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing roles", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error listing roles"})
+			return
+		}
+		defer rows.Close()
+
+		roles := []models.Role{}
+		for rows.Next() {
+			var (
+				name, description string
+				perm              sql.NullString
+			)
+			if err := rows.Scan(&name, &description, &perm); err != nil {
+				slog.ErrorContext(r.Context(), "Error scanning role", "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error listing roles"})
+				return
+			}
+			if len(roles) == 0 || roles[len(roles)-1].Name != name {
+				roles = append(roles, models.Role{Name: name, Description: description, Permissions: []string{}})
+			}
+			if perm.Valid {
+				roles[len(roles)-1].Permissions = append(roles[len(roles)-1].Permissions, perm.String)
+			}
+		}
+		if err := rows.Err(); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing roles", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error listing roles"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, roles)
+	}
+}
+
+// ListUsers returns accounts with their roles, optionally filtered by part of the email address
+func ListUsers(db *sql.DB, users store.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 50
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 200 {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "limit must be between 1 and 200"})
+				return
+			}
+			limit = n
+		}
+		found, err := users.Search(r.Context(), strings.TrimSpace(r.URL.Query().Get("email")), limit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing users", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error listing users"})
+			return
+		}
+
+		list := make([]models.AdminUser, 0, len(found))
+		for _, u := range found {
+			user, err := adminUser(db, u)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error loading user", "user_id", u.ID, "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error listing users"})
+				return
+			}
+			list = append(list, user)
+		}
+		respondWithJSON(w, http.StatusOK, list)
+	}
+}
+
+// GetUser returns one account with its roles
+func GetUser(db *sql.DB, users store.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid user ID"})
+			return
+		}
+		user, err := loadAdminUser(r.Context(), db, users, id)
+		if errors.Is(err, store.ErrNotFound) {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "User not found"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user", "user_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading user"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, user)
+	}
+}
+
+// AssignRole grants a role to a user. The user's existing tokens are revoked so their next login
+// carries the new role.
+func AssignRole(db *sql.DB, users store.Users, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		adminID := principal.UserID
+		userID, roleID, status, msg := resolveRoleChange(db, users, r)
+		if status != 0 {
+			respondWithJSON(w, status, models.ErrorResponse{Message: msg})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting role assignment", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error assigning role"})
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`INSERT INTO user_roles (user_id, role_id, granted_by, created_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id, role_id) DO NOTHING`, userID, roleID, adminID, time.Now().UTC())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error assigning role", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error assigning role"})
+			return
+		}
+		granted, _ := res.RowsAffected()
+		if granted > 0 {
+			if err := auth.RevokeTokens(tx, userID); err != nil {
+				slog.ErrorContext(r.Context(), "Error revoking sessions", "user_id", userID, "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error assigning role"})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing role assignment", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error assigning role"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Admin granted role", "admin_id", adminID, "role", mux.Vars(r)["role"], "user_id", userID)
+		if granted > 0 {
+			auditLog.Record(r, models.AuditEvent{Action: audit.ActionRoleGranted, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID), Detail: mux.Vars(r)["role"]})
+		}
+		respondRoleChange(w, r, db, users, userID)
+	}
+}
+
+// RemoveRole revokes a role from a user and signs them out everywhere so it stops working immediately.
+// The last administrator can't be removed.
+func RemoveRole(db *sql.DB, users store.Users, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		adminID := principal.UserID
+		userID, roleID, status, msg := resolveRoleChange(db, users, r)
+		if status != 0 {
+			respondWithJSON(w, status, models.ErrorResponse{Message: msg})
+			return
+		}
+		role := mux.Vars(r)["role"]
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting role removal", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error removing role"})
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec("DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", userID, roleID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error removing role", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error removing role"})
+			return
+		}
+		removed, _ := res.RowsAffected()
+		if removed > 0 {
+			if role == auth.RoleAdmin {
+				var admins int
+				if err := tx.QueryRow("SELECT COUNT(*) FROM user_roles WHERE role_id = ?", roleID).Scan(&admins); err != nil {
+					slog.ErrorContext(r.Context(), "Error counting admins", "err", err)
+					respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error removing role"})
+					return
+				}
+				if admins == 0 {
+					respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Can't remove the last administrator"})
+					return
+				}
+			}
+			if err := auth.RevokeTokens(tx, userID); err != nil {
+				slog.ErrorContext(r.Context(), "Error revoking sessions", "user_id", userID, "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error removing role"})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing role removal", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error removing role"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Admin removed role", "admin_id", adminID, "role", role, "user_id", userID)
+		if removed > 0 {
+			auditLog.Record(r, models.AuditEvent{Action: audit.ActionRoleRevoked, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID), Detail: role})
+		}
+		respondRoleChange(w, r, db, users, userID)
+	}
+}
+
+// resolveRoleChange validates the {id} and {role} path variables. A non-zero status means the request
+// should be rejected with msg.
+func resolveRoleChange(db *sql.DB, users store.Users, r *http.Request) (userID, roleID, status int, msg string) {
+	vars := mux.Vars(r)
+	userID, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		return 0, 0, http.StatusBadRequest, "Invalid user ID"
+	}
+	if vars["role"] == auth.RoleUser {
+		return 0, 0, http.StatusBadRequest, "Every account has the user role"
+	}
+
+	_, err = users.ByID(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return 0, 0, http.StatusNotFound, "User not found"
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+		return 0, 0, http.StatusInternalServerError, "Error loading user"
+	}
+	err = db.QueryRow("SELECT id FROM roles WHERE name = ?", vars["role"]).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return 0, 0, http.StatusNotFound, "Unknown role"
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading role", "role", vars["role"], "err", err)
+		return 0, 0, http.StatusInternalServerError, "Error loading role"
+	}
+	return userID, roleID, 0, ""
+}
+
+// respondRoleChange replies with the user's roles after a change
+func respondRoleChange(w http.ResponseWriter, r *http.Request, db *sql.DB, users store.Users, userID int) {
+	user, err := loadAdminUser(r.Context(), db, users, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Role updated, but the user could not be reloaded"})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, user)
+}
+
+// loadAdminUser reads an account and its roles; it returns store.ErrNotFound for unknown IDs
+func loadAdminUser(ctx context.Context, db *sql.DB, users store.Users, userID int) (models.AdminUser, error) {
+	user, err := users.ByID(ctx, userID)
+	if err != nil {
+		return models.AdminUser{}, err
+	}
+	return adminUser(db, user)
+}
+
+// adminUser adds the roles to an account
+func adminUser(db *sql.DB, u models.User) (models.AdminUser, error) {
+	user := models.AdminUser{ID: u.ID, Email: u.Email, EmailVerified: u.EmailVerifiedAt != nil, CreatedAt: u.CreatedAt}
+	access, err := auth.LoadAccess(db, u.ID)
+	if err != nil {
+		return user, err
+	}
+	user.Roles = access.Roles
+	return user, nil
+}
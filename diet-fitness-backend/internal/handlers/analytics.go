@@ -0,0 +1,67 @@
+// --- diet-fitness-backend/internal/handlers/analytics.go ---
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diet-fitness-backend/internal/analytics"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+)
+
+// GetTrainingLoad returns weekly volume, workload ratio and monotony/strain series for charts
+func GetTrainingLoad(workouts store.Workouts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		serveTrainingLoad(workouts, w, r, userID)
+	}
+}
+
+// serveTrainingLoad writes the training load report of a user; shared with the coach's view of a client
+func serveTrainingLoad(workouts store.Workouts, w http.ResponseWriter, r *http.Request, userID int) {
+	weeks := 12
+	if v := r.URL.Query().Get("weeks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 52 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "weeks must be between 1 and 52"})
+			return
+		}
+		weeks = n
+	}
+
+	to := time.Now().UTC()
+	from := analytics.WeekStart(to).AddDate(0, 0, -7*(weeks-1))
+	report, err := trainingLoadReport(r.Context(), workouts, userID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error computing training load", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error computing training load"})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// trainingLoadReport loads the workout log (plus the baseline history the metrics need) and computes the report
+func trainingLoadReport(ctx context.Context, workouts store.Workouts, userID int, from, to time.Time) (models.TrainingLoadReport, error) {
+	logged, err := workouts.Since(ctx, userID, analytics.WeekStart(from.AddDate(0, 0, -analytics.HistoryDays)))
+	if err != nil {
+		return models.TrainingLoadReport{}, err
+	}
+	sessions := make([]analytics.Session, len(logged))
+	for i, l := range logged {
+		s := analytics.Session{PerformedAt: l.PerformedAt, SessionRPE: l.SessionRPE, DurationMin: l.DurationMin}
+		for _, set := range l.Sets {
+			s.Sets = append(s.Sets, analytics.Set{MuscleGroup: set.MuscleGroup, Reps: set.Reps, LoadKg: set.LoadKg, RPE: set.RPE})
+		}
+		sessions[i] = s
+	}
+	return analytics.TrainingLoad(sessions, from, to), nil
+}
@@ -0,0 +1,201 @@
+// --- diet-fitness-backend/internal/handlers/apikeys.go ---
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	defaultAPIKeyDays = 90
+	maxAPIKeyDays     = 365
+	maxAPIKeysPerUser = 25
+)
+
+// ListAPIKeyScopes describes the scopes API keys can be granted
+func ListAPIKeyScopes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respondWithJSON(w, http.StatusOK, auth.ScopeDescriptions)
+	}
+}
+
+// ListAPIKeys returns the user's active API keys without the keys themselves
+func ListAPIKeys(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		rows, err := db.Query(`SELECT id, name, prefix, scopes, expires_at, last_used_at, created_at FROM api_keys
+			WHERE user_id = ? AND revoked_at IS NULL ORDER BY id`, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing API keys", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading API keys"})
+			return
+		}
+		defer rows.Close()
+
+		keys := []models.APIKey{}
+		for rows.Next() {
+			var (
+				k                   models.APIKey
+				scopes              string
+				expiresAt, lastUsed sql.NullTime
+			)
+			if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, &scopes, &expiresAt, &lastUsed, &k.CreatedAt); err != nil {
+				slog.ErrorContext(r.Context(), "Error scanning API key", "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading API keys"})
+				return
+			}
+			k.Scopes = strings.Split(scopes, ",")
+			if expiresAt.Valid {
+				k.ExpiresAt = &expiresAt.Time
+			}
+			if lastUsed.Valid {
+				k.LastUsedAt = &lastUsed.Time
+			}
+			keys = append(keys, k)
+		}
+		if err := rows.Err(); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing API keys", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading API keys"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, keys)
+	}
+}
+
+// CreateAPIKey issues a scoped API key. The key is in the response and can't be retrieved again.
+func CreateAPIKey(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.CreateAPIKeyPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		name := strings.TrimSpace(payload.Name)
+		if name == "" || len(name) > 100 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "name is required and at most 100 characters"})
+			return
+		}
+		scopes, msg := parseAPIKeyScopes(payload.Scopes)
+		if msg != "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+			return
+		}
+		days := defaultAPIKeyDays
+		if payload.ExpiresInDays != nil {
+			days = *payload.ExpiresInDays
+		}
+		if days < 0 || days > maxAPIKeyDays {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: fmt.Sprintf("expires_in_days must be between 0 (never) and %d", maxAPIKeyDays)})
+			return
+		}
+
+		var active int
+		if err := db.QueryRow("SELECT COUNT(*) FROM api_keys WHERE user_id = ? AND revoked_at IS NULL", userID).Scan(&active); err != nil {
+			slog.ErrorContext(r.Context(), "Error counting API keys", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error creating API key"})
+			return
+		}
+		if active >= maxAPIKeysPerUser {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: fmt.Sprintf("You can have at most %d API keys; revoke one first", maxAPIKeysPerUser)})
+			return
+		}
+
+		key, prefix, hash, err := auth.NewAPIKey()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating API key", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error creating API key"})
+			return
+		}
+		now := time.Now().UTC()
+		created := models.CreatedAPIKey{APIKey: models.APIKey{Name: name, Prefix: prefix, Scopes: scopes, CreatedAt: now}, Key: key}
+		var expiresAt sql.NullTime
+		if days > 0 {
+			expiresAt = sql.NullTime{Time: now.AddDate(0, 0, days), Valid: true}
+			created.ExpiresAt = &expiresAt.Time
+		}
+		err = db.QueryRow(`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`, userID, name, prefix, hash, strings.Join(scopes, ","), expiresAt, now).Scan(&created.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error storing API key", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error creating API key"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "User created API key", "user_id", userID, "api_key_id", created.ID, "scopes", strings.Join(scopes, ","))
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionAPIKeyCreated, TargetType: audit.TargetAPIKey, TargetID: strconv.Itoa(created.ID), Detail: strings.Join(scopes, ",")})
+		respondWithJSON(w, http.StatusCreated, created)
+	}
+}
+
+// RevokeAPIKey disables one of the user's API keys immediately
+func RevokeAPIKey(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		keyID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid API key ID"})
+			return
+		}
+
+		res, err := db.Exec("UPDATE api_keys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL",
+			time.Now().UTC(), keyID, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error revoking API key", "api_key_id", keyID, "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error revoking API key"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "API key not found"})
+			return
+		}
+		slog.InfoContext(r.Context(), "User revoked API key", "user_id", userID, "api_key_id", keyID)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionAPIKeyRevoked, TargetType: audit.TargetAPIKey, TargetID: strconv.Itoa(keyID)})
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "API key revoked"})
+	}
+}
+
+// parseAPIKeyScopes validates and de-duplicates requested scopes. A non-empty msg explains why they were rejected.
+func parseAPIKeyScopes(requested []string) (scopes []string, msg string) {
+	seen := make(map[string]bool)
+	for _, s := range requested {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if _, known := auth.ScopeDescriptions[s]; !known {
+			return nil, fmt.Sprintf("Unknown scope %q; see GET /api/api-keys/scopes", s)
+		}
+		if !seen[s] {
+			seen[s] = true
+			scopes = append(scopes, s)
+		}
+	}
+	if len(scopes) == 0 {
+		return nil, "At least one scope is required"
+	}
+	sort.Strings(scopes)
+	return scopes, ""
+}
@@ -0,0 +1,82 @@
+// --- diet-fitness-backend/internal/handlers/audit.go ---
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+)
+
+// ListAuditLog returns audit events newest first. Filters: actor_id, action, target_type, target_id,
+// and since/until as RFC 3339 times. Pages hold up to limit events (default 50, at most 200); pass
+// next_before_id from the response as before_id for the next page.
+func ListAuditLog(events store.Audit) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := store.AuditFilter{
+			Action:     q.Get("action"),
+			TargetType: q.Get("target_type"),
+			TargetID:   q.Get("target_id"),
+			Limit:      50,
+		}
+		for _, p := range []struct {
+			name string
+			dst  *int
+			max  int
+		}{
+			{"actor_id", &filter.ActorID, 0},
+			{"before_id", &filter.BeforeID, 0},
+			{"limit", &filter.Limit, 200},
+		} {
+			v := q.Get(p.name)
+			if v == "" {
+				continue
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || (p.max > 0 && n > p.max) {
+				msg := p.name + " must be a positive integer"
+				if p.max > 0 {
+					msg = p.name + " must be between 1 and " + strconv.Itoa(p.max)
+				}
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+				return
+			}
+			*p.dst = n
+		}
+		for _, p := range []struct {
+			name string
+			dst  *time.Time
+		}{
+			{"since", &filter.Since},
+			{"until", &filter.Until},
+		} {
+			v := q.Get(p.name)
+			if v == "" {
+				continue
+			}
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: p.name + " must be an RFC 3339 time, e.g. 2024-03-01T00:00:00Z"})
+				return
+			}
+			*p.dst = t.UTC()
+		}
+
+		page := models.AuditLogPage{}
+		var err error
+		page.Events, err = events.List(r.Context(), filter)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing audit events", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading audit log"})
+			return
+		}
+		if len(page.Events) == filter.Limit {
+			page.NextBeforeID = page.Events[len(page.Events)-1].ID
+		}
+		respondWithJSON(w, http.StatusOK, page)
+	}
+}
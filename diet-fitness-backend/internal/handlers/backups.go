@@ -0,0 +1,53 @@
+// --- diet-fitness-backend/internal/handlers/backups.go ---
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/backup"
+	"diet-fitness-backend/internal/models"
+)
+
+// CreateBackup takes a database backup right away, e.g. before a risky migration
+func CreateBackup(backups *backup.Manager, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		info, err := backups.Run(r.Context())
+		if errors.Is(err, backup.ErrUnsupported) {
+			respondWithJSON(w, http.StatusNotImplemented, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error taking database backup", "admin_id", principal.UserID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error taking backup"})
+			return
+		}
+		slog.InfoContext(r.Context(), "Admin took database backup", "admin_id", principal.UserID, "backup", info.Name, "size_bytes", info.SizeBytes)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionBackupCreated, TargetType: audit.TargetBackup, TargetID: info.Name})
+		respondWithJSON(w, http.StatusCreated, info)
+	}
+}
+
+// ListBackups lists the backups kept on the server, newest first
+func ListBackups(backups *backup.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := backups.List()
+		if errors.Is(err, backup.ErrUnsupported) {
+			respondWithJSON(w, http.StatusNotImplemented, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing database backups", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error listing backups"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string][]backup.Info{"backups": list})
+	}
+}
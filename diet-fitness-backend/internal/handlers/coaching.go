@@ -0,0 +1,768 @@
+// --- diet-fitness-backend/internal/handlers/coaching.go ---
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/mail"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+// Scopes a client can grant their coach
+const (
+	scopeWorkouts   = "workouts"   // Workout log, training load and session comments
+	scopeDiary      = "diary"      // Meal diary
+	scopeActivities = "activities" // Cardio activities
+	scopePlan       = "plan"       // View and replace the active workout plan
+)
+
+var coachingScopes = []string{scopeWorkouts, scopeDiary, scopeActivities, scopePlan}
+
+const (
+	maxInviteMessageLength = 500
+	maxCommentLength       = 2000
+)
+
+const relationshipColumns = `c.id, c.coach_id, co.email, c.client_id, c.invite_email, c.status, c.scopes, c.message,
+	c.created_at, c.accepted_at, c.ended_at`
+
+// InviteClient invites someone by email. The address doesn't need an account yet; the invitation
+// shows up once they register and verify it.
+func InviteClient(db *sql.DB, cfg *config.Config, mailer mail.Mailer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		coachID := principal.UserID
+		var payload models.CoachInvitePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		email, err := auth.NormalizeEmail(payload.Email)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Please enter a valid email address"})
+			return
+		}
+		scopes := coachingScopes
+		if len(payload.Scopes) > 0 {
+			if scopes, err = parseScopes(payload.Scopes); err != nil {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+				return
+			}
+		}
+		message := strings.TrimSpace(payload.Message)
+		if len(message) > maxInviteMessageLength {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: fmt.Sprintf("Message must be at most %d characters", maxInviteMessageLength)})
+			return
+		}
+
+		coachEmail := principal.Email
+		if strings.EqualFold(email, coachEmail) {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "You can't coach yourself"})
+			return
+		}
+		var existing int
+		err = db.QueryRow("SELECT COUNT(*) FROM coach_clients WHERE coach_id = ? AND status IN ('pending', 'active') AND LOWER(invite_email) = ?",
+			coachID, email).Scan(&existing)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking existing invitations", "coach_id", coachID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error sending invitation"})
+			return
+		}
+		if existing > 0 {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "This person is already your client or has a pending invitation"})
+			return
+		}
+
+		var id int
+		err = db.QueryRow(`INSERT INTO coach_clients (coach_id, invite_email, status, scopes, message, created_at)
+			VALUES (?, ?, 'pending', ?, ?, ?) RETURNING id`, coachID, email, strings.Join(scopes, ","), message, time.Now().UTC()).Scan(&id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error creating invitation", "coach_id", coachID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error sending invitation"})
+			return
+		}
+		rel, err := loadRelationship(db, id)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading invitation", "invitation_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error sending invitation"})
+			return
+		}
+
+		go sendCoachingInvite(cfg, mailer, rel)
+		slog.InfoContext(r.Context(), "Coach invited a client", "coach_id", coachID, "invitation_id", id)
+		respondWithJSON(w, http.StatusCreated, rel)
+	}
+}
+
+func sendCoachingInvite(cfg *config.Config, mailer mail.Mailer, rel models.CoachingRelationship) {
+	body := fmt.Sprintf("%s invited you to train with them on FitPlan.\n\n", rel.CoachEmail)
+	if rel.Message != "" {
+		body += fmt.Sprintf("Their message:\n\n%s\n\n", rel.Message)
+	}
+	body += fmt.Sprintf("They asked to see your: %s. You choose what to share when you accept.\n\n"+
+		"Log in (or sign up with this address) to review the invitation:\n\n%s/coaching\n",
+		strings.Join(rel.Scopes, ", "), strings.TrimRight(cfg.AppBaseURL, "/"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err := mailer.Send(ctx, mail.Message{To: rel.ClientEmail, Subject: "You've been invited to FitPlan coaching", Body: body})
+	if err != nil {
+		slog.Error("Error sending coaching invitation", "invitation_id", rel.ID, "err", err)
+	}
+}
+
+// ListCoachInvitations returns the coach's invitations that haven't been answered yet
+func ListCoachInvitations(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		coachID := principal.UserID
+		invitations, err := listRelationships(db, "c.coach_id = ? AND c.status = 'pending'", coachID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing invitations", "coach_id", coachID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading invitations"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string][]models.CoachingRelationship{"invitations": invitations})
+	}
+}
+
+// GetRoster summarizes the coach's active clients: plan, recent sessions, adherence to the planned
+// frequency and diary logging, each only when the client shares it
+func GetRoster(db *sql.DB, st store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		coachID := principal.UserID
+		clients, err := listRelationships(db, "c.coach_id = ? AND c.status = 'active'", coachID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing clients", "coach_id", coachID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading clients"})
+			return
+		}
+
+		now := time.Now().UTC()
+		roster := make([]models.RosterEntry, 0, len(clients))
+		for _, rel := range clients {
+			entry, err := rosterEntry(r.Context(), db, st, rel, now)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error summarizing client relationship", "relationship_id", rel.ID, "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading clients"})
+				return
+			}
+			roster = append(roster, entry)
+		}
+		respondWithJSON(w, http.StatusOK, map[string][]models.RosterEntry{"clients": roster})
+	}
+}
+
+func rosterEntry(ctx context.Context, db *sql.DB, st store.Store, rel models.CoachingRelationship, now time.Time) (models.RosterEntry, error) {
+	entry := models.RosterEntry{Relationship: rel}
+	clientID := *rel.ClientID
+
+	plan, err := st.Plans().Active(ctx, clientID)
+	if err != nil {
+		return entry, err
+	}
+	sessionsPerWeek := 0
+	if plan != nil {
+		sessionsPerWeek = plan.SessionsPerWeek
+		if hasScope(rel, scopePlan) || hasScope(rel, scopeWorkouts) {
+			entry.PlanTitle = plan.Title
+			entry.SessionsPerWeek = sessionsPerWeek
+		}
+	}
+
+	if hasScope(rel, scopeWorkouts) {
+		latest, err := st.Workouts().Recent(ctx, clientID, 1)
+		if err != nil {
+			return entry, err
+		}
+		if len(latest) > 0 {
+			entry.LastSessionAt = &latest[0].PerformedAt
+		}
+
+		recent, err := st.Workouts().Since(ctx, clientID, now.AddDate(0, 0, -28))
+		if err != nil {
+			return entry, err
+		}
+		last7, last28 := 0, len(recent)
+		for _, s := range recent {
+			if !s.PerformedAt.Before(now.AddDate(0, 0, -7)) {
+				last7++
+			}
+		}
+		entry.SessionsLast7Days = &last7
+		entry.SessionsLast28Days = &last28
+		if sessionsPerWeek > 0 {
+			adherence := math.Round(float64(last28)/float64(sessionsPerWeek*4)*100) / 100
+			entry.Adherence28Days = &adherence
+		}
+	}
+
+	if hasScope(rel, scopeDiary) {
+		var days int
+		err := db.QueryRow("SELECT COUNT(DISTINCT entry_date) FROM diary_entries WHERE user_id = ? AND entry_date > ?",
+			clientID, now.AddDate(0, 0, -7).Format("2006-01-02")).Scan(&days)
+		if err != nil {
+			return entry, fmt.Errorf("error counting diary days: %w", err)
+		}
+		entry.DiaryDaysLast7Days = &days
+	}
+	return entry, nil
+}
+
+// EndClientRelationship lets the coach cancel an invitation or stop coaching a client
+func EndClientRelationship(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		coachID := principal.UserID
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid client ID"})
+			return
+		}
+		res, err := db.Exec("UPDATE coach_clients SET status = 'ended', ended_at = ? WHERE id = ? AND coach_id = ? AND status IN ('pending', 'active')",
+			time.Now().UTC(), id, coachID)
+		respondRelationshipUpdate(w, db, id, res, err, "Client not found")
+	}
+}
+
+// ClientWorkouts shows a client's workout log to their coach
+func ClientWorkouts(db *sql.DB, workouts store.Workouts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rel, ok := coachedClient(db, w, r, scopeWorkouts); ok {
+			serveWorkouts(workouts, w, r, *rel.ClientID)
+		}
+	}
+}
+
+// ClientTrainingLoad shows a client's training load report to their coach
+func ClientTrainingLoad(db *sql.DB, workouts store.Workouts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rel, ok := coachedClient(db, w, r, scopeWorkouts); ok {
+			serveTrainingLoad(workouts, w, r, *rel.ClientID)
+		}
+	}
+}
+
+// ClientDiary shows a client's meal diary to their coach
+func ClientDiary(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rel, ok := coachedClient(db, w, r, scopeDiary); ok {
+			serveDiary(db, w, r, *rel.ClientID)
+		}
+	}
+}
+
+// ClientActivities shows a client's cardio activities to their coach
+func ClientActivities(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rel, ok := coachedClient(db, w, r, scopeActivities); ok {
+			serveActivities(db, w, r, *rel.ClientID)
+		}
+	}
+}
+
+// GetClientPlan shows a client's active workout plan to their coach
+func GetClientPlan(db *sql.DB, plans store.Plans) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rel, ok := coachedClient(db, w, r, scopePlan)
+		if !ok {
+			return
+		}
+		plan, err := plans.Active(r.Context(), *rel.ClientID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading active plan", "user_id", *rel.ClientID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading plan"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{"workout_plan": plan})
+	}
+}
+
+// AssignClientPlan replaces a client's active plan with one written by the coach. The progression
+// engine then adjusts it after each session the client logs, as with any other plan.
+func AssignClientPlan(db *sql.DB, plans store.Plans, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rel, ok := coachedClient(db, w, r, scopePlan)
+		if !ok {
+			return
+		}
+		var payload models.AssignPlanPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		if msg := validatePlanExercises(payload.Exercises); msg != "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+			return
+		}
+		if payload.SessionsPerWeek < 0 || payload.SessionsPerWeek > 14 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "sessions_per_week must be between 0 and 14"})
+			return
+		}
+		title := strings.TrimSpace(payload.Title)
+		if title == "" {
+			title = "Plan from your coach"
+		}
+		clientID := *rel.ClientID
+
+		coachID := rel.CoachID
+		plan, err := plans.CreateActive(r.Context(), models.WorkoutPlan{
+			UserID:          clientID,
+			Title:           title,
+			AssignedBy:      &coachID,
+			SessionsPerWeek: payload.SessionsPerWeek,
+			Exercises:       preparePlanExercises(payload.Exercises),
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error assigning plan", "user_id", clientID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error assigning plan"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "Coach assigned plan", "coach_id", rel.CoachID, "plan_id", plan.ID, "user_id", clientID)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionPlanAssigned, TargetType: audit.TargetPlan, TargetID: strconv.Itoa(plan.ID), Detail: "client " + strconv.Itoa(clientID)})
+		respondWithJSON(w, http.StatusOK, plan)
+	}
+}
+
+// ListCoaching returns the user's coaches and the invitations addressed to their email
+func ListCoaching(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		relationships, err := listRelationships(db,
+			"(c.client_id = ? AND c.status = 'active') OR (c.status = 'pending' AND LOWER(c.invite_email) = (SELECT LOWER(email) FROM users WHERE id = ?))",
+			userID, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing coaches", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading coaching"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string][]models.CoachingRelationship{"coaching": relationships})
+	}
+}
+
+// AcceptCoaching accepts an invitation. The body may narrow the requested scopes; without one
+// everything requested is granted. Only verified addresses can accept.
+func AcceptCoaching(db *sql.DB, users store.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.CoachingScopesPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && err != io.EOF {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		rel, ok := invitationFor(db, users, w, r, userID)
+		if !ok {
+			return
+		}
+		if !requireVerifiedEmail(users, w, r, userID) {
+			return
+		}
+		if rel.CoachID == userID {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "You can't coach yourself"})
+			return
+		}
+
+		scopes := rel.Scopes
+		if payload.Scopes != nil {
+			granted, err := parseScopes(payload.Scopes)
+			if err != nil {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+				return
+			}
+			for _, s := range granted {
+				if !hasScope(rel, s) {
+					respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: fmt.Sprintf("Your coach didn't ask for %q", s)})
+					return
+				}
+			}
+			scopes = granted
+		}
+
+		res, err := db.Exec("UPDATE coach_clients SET client_id = ?, status = 'active', scopes = ?, accepted_at = ? WHERE id = ? AND status = 'pending'",
+			userID, strings.Join(scopes, ","), time.Now().UTC(), rel.ID)
+		respondRelationshipUpdate(w, db, rel.ID, res, err, "Invitation not found")
+	}
+}
+
+// DeclineCoaching declines an invitation
+func DeclineCoaching(db *sql.DB, users store.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		rel, ok := invitationFor(db, users, w, r, userID)
+		if !ok {
+			return
+		}
+		res, err := db.Exec("UPDATE coach_clients SET status = 'declined', ended_at = ? WHERE id = ? AND status = 'pending'", time.Now().UTC(), rel.ID)
+		respondRelationshipUpdate(w, db, rel.ID, res, err, "Invitation not found")
+	}
+}
+
+// UpdateCoachingScopes changes what the user shares with a coach; it takes effect immediately
+func UpdateCoachingScopes(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid coaching ID"})
+			return
+		}
+		var payload models.CoachingScopesPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Scopes == nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "scopes is required (use [] to share nothing)"})
+			return
+		}
+		scopes, err := parseScopes(payload.Scopes)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+		res, err := db.Exec("UPDATE coach_clients SET scopes = ? WHERE id = ? AND client_id = ? AND status = 'active'",
+			strings.Join(scopes, ","), id, userID)
+		respondRelationshipUpdate(w, db, id, res, err, "Coach not found")
+	}
+}
+
+// EndCoaching stops sharing data with a coach
+func EndCoaching(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid coaching ID"})
+			return
+		}
+		res, err := db.Exec("UPDATE coach_clients SET status = 'ended', ended_at = ? WHERE id = ? AND client_id = ? AND status = 'active'",
+			time.Now().UTC(), id, userID)
+		respondRelationshipUpdate(w, db, id, res, err, "Coach not found")
+	}
+}
+
+// ListSessionComments returns the comments on a session, for its owner or their coach
+func ListSessionComments(db *sql.DB, workouts store.Workouts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionID, ok := sessionForComments(db, workouts, w, r)
+		if !ok {
+			return
+		}
+		rows, err := db.Query(`SELECT sc.id, sc.session_id, sc.author_id, u.email, sc.body, sc.created_at
+			FROM session_comments sc JOIN users u ON u.id = sc.author_id
+			WHERE sc.session_id = ? ORDER BY sc.created_at, sc.id`, sessionID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing comments", "session_id", sessionID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading comments"})
+			return
+		}
+		defer rows.Close()
+
+		comments := []models.SessionComment{}
+		for rows.Next() {
+			var c models.SessionComment
+			if err := rows.Scan(&c.ID, &c.SessionID, &c.AuthorID, &c.AuthorEmail, &c.Body, &c.CreatedAt); err != nil {
+				slog.ErrorContext(r.Context(), "Error scanning comment", "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading comments"})
+				return
+			}
+			comments = append(comments, c)
+		}
+		if err := rows.Err(); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing comments", "session_id", sessionID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading comments"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string][]models.SessionComment{"comments": comments})
+	}
+}
+
+// AddSessionComment adds a comment to a session, by its owner or their coach
+func AddSessionComment(db *sql.DB, workouts store.Workouts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.CommentPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || strings.TrimSpace(payload.Body) == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Comment body is required"})
+			return
+		}
+		body := strings.TrimSpace(payload.Body)
+		if len(body) > maxCommentLength {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: fmt.Sprintf("Comments must be at most %d characters", maxCommentLength)})
+			return
+		}
+		sessionID, ok := sessionForComments(db, workouts, w, r)
+		if !ok {
+			return
+		}
+
+		c := models.SessionComment{SessionID: sessionID, AuthorID: userID, Body: body, CreatedAt: time.Now().UTC()}
+		c.AuthorEmail = principal.Email
+		err := db.QueryRow("INSERT INTO session_comments (session_id, author_id, body, created_at) VALUES (?, ?, ?, ?) RETURNING id",
+			sessionID, userID, body, c.CreatedAt).Scan(&c.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error adding comment", "session_id", sessionID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error adding comment"})
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, c)
+	}
+}
+
+// sessionForComments resolves the {id} session and checks that the user owns it or coaches its owner
+// with the workouts scope. It writes the error response and returns false otherwise.
+func sessionForComments(db *sql.DB, workouts store.Workouts, w http.ResponseWriter, r *http.Request) (int, bool) {
+	principal, ok := auth.RequirePrincipal(w, r)
+	if !ok {
+		return 0, false
+	}
+	userID := principal.UserID
+	sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid session ID"})
+		return 0, false
+	}
+
+	session, err := workouts.ByID(r.Context(), sessionID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		slog.ErrorContext(r.Context(), "Error loading session", "session_id", sessionID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading session"})
+		return 0, false
+	}
+	if err == nil && session.UserID == userID {
+		return sessionID, true
+	}
+	if err == nil && principal.Access.HasPermission(auth.PermClientsManage) {
+		var coached int
+		err := db.QueryRow(`SELECT COUNT(*) FROM coach_clients WHERE coach_id = ? AND client_id = ? AND status = 'active'
+			AND (',' || scopes || ',') LIKE ?`, userID, session.UserID, "%,"+scopeWorkouts+",%").Scan(&coached)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking coaching access", "session_id", sessionID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading session"})
+			return 0, false
+		}
+		if coached > 0 {
+			return sessionID, true
+		}
+	}
+	// Other users' sessions look the same as missing ones
+	respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Session not found"})
+	return 0, false
+}
+
+// coachedClient resolves the {id} relationship for the coach making the request and checks that it is
+// active and the client granted scope. It writes the error response and returns false otherwise.
+func coachedClient(db *sql.DB, w http.ResponseWriter, r *http.Request, scope string) (models.CoachingRelationship, bool) {
+	principal, ok := auth.RequirePrincipal(w, r)
+	if !ok {
+		return models.CoachingRelationship{}, false
+	}
+	coachID := principal.UserID
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid client ID"})
+		return models.CoachingRelationship{}, false
+	}
+	rel, err := loadRelationship(db, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && rel.CoachID != coachID) {
+		respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Client not found"})
+		return rel, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading coaching relationship", "relationship_id", id, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading client"})
+		return rel, false
+	}
+	if rel.Status != "active" {
+		respondWithJSON(w, http.StatusForbidden, models.ErrorResponse{Message: "This person is not currently your client"})
+		return rel, false
+	}
+	if !hasScope(rel, scope) {
+		respondWithJSON(w, http.StatusForbidden, models.ErrorResponse{Message: fmt.Sprintf("Your client hasn't shared their %s with you", scope)})
+		return rel, false
+	}
+	return rel, true
+}
+
+// invitationFor resolves the {id} invitation and checks it is pending and addressed to the user's email.
+// It writes the error response and returns false otherwise.
+func invitationFor(db *sql.DB, users store.Users, w http.ResponseWriter, r *http.Request, userID int) (models.CoachingRelationship, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid coaching ID"})
+		return models.CoachingRelationship{}, false
+	}
+	user, err := users.ByID(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading invitation"})
+		return models.CoachingRelationship{}, false
+	}
+	rel, err := loadRelationship(db, id)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (rel.Status != "pending" || !strings.EqualFold(rel.ClientEmail, user.Email))) {
+		respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Invitation not found"})
+		return rel, false
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading invitation", "invitation_id", id, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading invitation"})
+		return rel, false
+	}
+	return rel, true
+}
+
+// respondRelationshipUpdate replies with the relationship after a guarded UPDATE; no affected rows means
+// the relationship wasn't in a state the caller could change
+func respondRelationshipUpdate(w http.ResponseWriter, db *sql.DB, id int, res sql.Result, err error, notFound string) {
+	if err != nil {
+		slog.Error("Error updating coaching relationship", "relationship_id", id, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error updating coaching"})
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: notFound})
+		return
+	}
+	rel, err := loadRelationship(db, id)
+	if err != nil {
+		slog.Error("Error loading coaching relationship", "relationship_id", id, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error updating coaching"})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, rel)
+}
+
+// parseScopes validates requested scopes and returns them de-duplicated in canonical order
+func parseScopes(requested []string) ([]string, error) {
+	want := map[string]bool{}
+	for _, s := range requested {
+		s = strings.ToLower(strings.TrimSpace(s))
+		known := false
+		for _, c := range coachingScopes {
+			known = known || c == s
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown scope %q; use %s", s, strings.Join(coachingScopes, ", "))
+		}
+		want[s] = true
+	}
+	scopes := []string{}
+	for _, c := range coachingScopes {
+		if want[c] {
+			scopes = append(scopes, c)
+		}
+	}
+	return scopes, nil
+}
+
+func hasScope(rel models.CoachingRelationship, scope string) bool {
+	for _, s := range rel.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func loadRelationship(q execQueryer, id int) (models.CoachingRelationship, error) {
+	row := q.QueryRow("SELECT "+relationshipColumns+" FROM coach_clients c JOIN users co ON co.id = c.coach_id WHERE c.id = ?", id)
+	return scanRelationship(row.Scan)
+}
+
+func listRelationships(q execQueryer, where string, args ...interface{}) ([]models.CoachingRelationship, error) {
+	rows, err := q.Query("SELECT "+relationshipColumns+" FROM coach_clients c JOIN users co ON co.id = c.coach_id WHERE "+where+" ORDER BY c.invite_email, c.id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relationships := []models.CoachingRelationship{}
+	for rows.Next() {
+		rel, err := scanRelationship(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		relationships = append(relationships, rel)
+	}
+	return relationships, rows.Err()
+}
+
+func scanRelationship(scan func(dest ...interface{}) error) (models.CoachingRelationship, error) {
+	var (
+		rel                 models.CoachingRelationship
+		clientID            sql.NullInt64
+		scopes              string
+		acceptedAt, endedAt sql.NullTime
+	)
+	err := scan(&rel.ID, &rel.CoachID, &rel.CoachEmail, &clientID, &rel.ClientEmail, &rel.Status, &scopes, &rel.Message,
+		&rel.CreatedAt, &acceptedAt, &endedAt)
+	if err != nil {
+		return rel, err
+	}
+	if clientID.Valid {
+		id := int(clientID.Int64)
+		rel.ClientID = &id
+	}
+	rel.Scopes = []string{}
+	if scopes != "" {
+		rel.Scopes = strings.Split(scopes, ",")
+	}
+	if acceptedAt.Valid {
+		rel.AcceptedAt = &acceptedAt.Time
+	}
+	if endedAt.Valid {
+		rel.EndedAt = &endedAt.Time
+	}
+	return rel, nil
+}
@@ -0,0 +1,432 @@
+// --- diet-fitness-backend/internal/handlers/diary.go ---
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/diarycsv"
+	"diet-fitness-backend/internal/models"
+)
+
+const maxDiaryCSVSize = 10 << 20 // 10 MB; a decade of per-food MyFitnessPal rows is a few MB
+
+const diaryColumns = `id, user_id, entry_date, meal, food_id, name, brand, quantity, unit, calories, protein_g, carbs_g,
+	fat_g, fiber_g, sugar_g, sodium_mg, source, created_at`
+
+const foodColumns = "id, user_id, name, brand, serving_unit, calories, protein_g, carbs_g, fat_g, created_at"
+
+// ListDiary returns the meal diary for a date range (default: the last 7 days)
+func ListDiary(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		serveDiary(db, w, r, userID)
+	}
+}
+
+// serveDiary writes a user's diary for the requested range; shared with the coach's view of a client
+func serveDiary(db *sql.DB, w http.ResponseWriter, r *http.Request, userID int) {
+	from, to, err := diaryRange(r, 7)
+	if err != nil {
+		respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+		return
+	}
+	entries, err := loadDiaryEntries(db, userID, from, to)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading diary", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading diary"})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"from": from, "to": to, "entries": entries})
+}
+
+// AddDiaryEntry logs a food, either from the catalog (food_id) or with explicit nutrition values
+func AddDiaryEntry(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var payload models.DiaryEntryPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		date, err := diarycsv.ParseDate(payload.Date)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+		meal := diarycsv.NormalizeMeal(payload.Meal)
+		if meal == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "meal is required"})
+			return
+		}
+		if payload.Quantity == 0 {
+			payload.Quantity = 1
+		}
+		if payload.Quantity < 0 || payload.Calories < 0 || payload.ProteinG < 0 || payload.CarbsG < 0 || payload.FatG < 0 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "quantity and nutrition values must not be negative"})
+			return
+		}
+
+		entry := models.DiaryEntry{
+			UserID:   userID,
+			Date:     date,
+			Meal:     meal,
+			Name:     strings.TrimSpace(payload.Name),
+			Brand:    strings.TrimSpace(payload.Brand),
+			Quantity: payload.Quantity,
+			Unit:     payload.Unit,
+			Calories: payload.Calories,
+			ProteinG: payload.ProteinG,
+			CarbsG:   payload.CarbsG,
+			FatG:     payload.FatG,
+			Source:   "manual",
+		}
+		if payload.FoodID != nil {
+			food, err := loadFood(db, userID, *payload.FoodID)
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Food not found"})
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error loading food", "food_id", *payload.FoodID, "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving diary entry"})
+				return
+			}
+			applyFood(&entry, food, true)
+		} else if entry.Name == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Either food_id or name is required"})
+			return
+		}
+		if entry.Unit == "" {
+			entry.Unit = "serving"
+		}
+
+		id, err := insertDiaryEntry(db, entry)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving diary entry", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving diary entry"})
+			return
+		}
+		entry.ID = id
+		entry.CreatedAt = time.Now().UTC()
+		respondWithJSON(w, http.StatusCreated, entry)
+	}
+}
+
+// SearchFoods finds shared and custom foods whose name contains q
+func SearchFoods(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		q := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+		rows, err := db.Query("SELECT "+foodColumns+" FROM foods WHERE (user_id IS NULL OR user_id = ?) AND LOWER(name) LIKE ? ORDER BY name LIMIT 50",
+			userID, "%"+q+"%")
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error searching foods", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error searching foods"})
+			return
+		}
+		defer rows.Close()
+
+		foods := []models.Food{}
+		for rows.Next() {
+			f, err := scanFood(rows.Scan)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error scanning food", "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error searching foods"})
+				return
+			}
+			foods = append(foods, f)
+		}
+		respondWithJSON(w, http.StatusOK, map[string][]models.Food{"foods": foods})
+	}
+}
+
+// ImportDiary imports a MyFitnessPal export or a generic diary CSV. Re-importing a file replaces the
+// entries an earlier import of the same format wrote for those days instead of duplicating them.
+func ImportDiary(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxDiaryCSVSize+1<<20)
+		if err := r.ParseMultipartForm(maxDiaryCSVSize); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "File upload error: " + err.Error()})
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Error retrieving file from form: " + err.Error()})
+			return
+		}
+		defer file.Close()
+
+		format, rows, rowErrs, err := diarycsv.Parse(file)
+		if err != nil {
+			respondWithJSON(w, http.StatusUnprocessableEntity, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+
+		summary := models.DiaryImportSummary{Format: format, Errors: []models.CSVRowError{}}
+		summary.Errors = append(summary.Errors, rowErrs...)
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting diary import", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error importing diary"})
+			return
+		}
+		defer tx.Rollback()
+
+		if err := importDiaryRows(tx, userID, rows, &summary); err != nil {
+			slog.ErrorContext(r.Context(), "Error importing diary", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error importing diary"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing diary import", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error importing diary"})
+			return
+		}
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionDiaryImported, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID),
+			Detail: fmt.Sprintf("%s: %d entries, %d replaced", summary.Format, summary.Imported, summary.Replaced)})
+		respondWithJSON(w, http.StatusOK, summary)
+	}
+}
+
+// ExportDiary downloads the diary as a CSV in the generic import schema (default: all entries)
+func ExportDiary(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		from, to, err := diaryRange(r, 0)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+		entries, err := loadDiaryEntries(db, userID, from, to)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading diary", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error exporting diary"})
+			return
+		}
+
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionDiaryExported, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID),
+			Detail: fmt.Sprintf("%d entries", len(entries))})
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="diary.csv"`)
+		if err := diarycsv.Write(w, entries); err != nil {
+			slog.ErrorContext(r.Context(), "Error writing diary export", "user_id", userID, "err", err)
+		}
+	}
+}
+
+// importDiaryRows replaces earlier imports of the same days and sources and stores each row, linking
+// it to a food
+func importDiaryRows(tx *sql.Tx, userID int, rows []diarycsv.Row, summary *models.DiaryImportSummary) error {
+	type daySource struct{ date, source string }
+	cleared := map[daySource]bool{}
+	for _, row := range rows {
+		e := row.Entry
+		e.UserID = userID
+		if key := (daySource{e.Date, e.Source}); !cleared[key] {
+			res, err := tx.Exec("DELETE FROM diary_entries WHERE user_id = ? AND entry_date = ? AND source = ?", userID, e.Date, e.Source)
+			if err != nil {
+				return fmt.Errorf("error replacing entries for %s: %w", e.Date, err)
+			}
+			n, _ := res.RowsAffected()
+			summary.Replaced += int(n)
+			cleared[key] = true
+		}
+
+		food, err := matchFood(tx, userID, e.Name, e.Brand)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		switch {
+		case err == nil:
+			applyFood(&e, food, !row.HasNutrition)
+			summary.MatchedFoods++
+		case !row.HasNutrition:
+			summary.Errors = append(summary.Errors, models.CSVRowError{Line: row.Line,
+				Message: fmt.Sprintf("no food named %q; calories are required for unknown foods", e.Name)})
+			continue
+		default:
+			id, err := createCustomFood(tx, userID, e)
+			if err != nil {
+				return err
+			}
+			e.FoodID = &id
+			summary.CustomFoods++
+		}
+
+		if _, err := insertDiaryEntry(tx, e); err != nil {
+			return err
+		}
+		summary.Imported++
+	}
+	return nil
+}
+
+// matchFood looks up a food by name (and brand, when given), preferring the user's custom foods
+func matchFood(q execQueryer, userID int, name, brand string) (models.Food, error) {
+	return scanFood(q.QueryRow(`SELECT `+foodColumns+` FROM foods
+		WHERE (user_id IS NULL OR user_id = ?) AND LOWER(name) = LOWER(?) AND (? = '' OR LOWER(brand) = LOWER(?))
+		ORDER BY CASE WHEN user_id IS NULL THEN 1 ELSE 0 END, id LIMIT 1`, userID, name, brand, brand).Scan)
+}
+
+func loadFood(q execQueryer, userID, id int) (models.Food, error) {
+	return scanFood(q.QueryRow("SELECT "+foodColumns+" FROM foods WHERE id = ? AND (user_id IS NULL OR user_id = ?)", id, userID).Scan)
+}
+
+// createCustomFood saves an unmatched diary row as a custom food, with nutrition per single serving
+func createCustomFood(q execQueryer, userID int, e models.DiaryEntry) (int, error) {
+	per := func(v float64) float64 { return round1(v / e.Quantity) }
+	var id int
+	err := q.QueryRow(`INSERT INTO foods (user_id, name, brand, serving_unit, calories, protein_g, carbs_g, fat_g)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		userID, e.Name, e.Brand, e.Unit, per(e.Calories), per(e.ProteinG), per(e.CarbsG), per(e.FatG)).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating custom food %q: %w", e.Name, err)
+	}
+	return id, nil
+}
+
+// applyFood links an entry to a food; with nutrition set the food's per-serving values are scaled by the quantity
+func applyFood(e *models.DiaryEntry, food models.Food, nutrition bool) {
+	id := food.ID
+	e.FoodID = &id
+	if e.Name == "" {
+		e.Name = food.Name
+	}
+	if e.Brand == "" {
+		e.Brand = food.Brand
+	}
+	if e.Unit == "" || e.Unit == "serving" {
+		e.Unit = food.ServingUnit
+	}
+	if nutrition {
+		e.Calories = round1(food.Calories * e.Quantity)
+		e.ProteinG = round1(food.ProteinG * e.Quantity)
+		e.CarbsG = round1(food.CarbsG * e.Quantity)
+		e.FatG = round1(food.FatG * e.Quantity)
+	}
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func insertDiaryEntry(q execQueryer, e models.DiaryEntry) (int, error) {
+	var id int
+	err := q.QueryRow(`INSERT INTO diary_entries (user_id, entry_date, meal, food_id, name, brand, quantity, unit, calories,
+		protein_g, carbs_g, fat_g, fiber_g, sugar_g, sodium_mg, source)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		e.UserID, e.Date, e.Meal, e.FoodID, e.Name, e.Brand, e.Quantity, e.Unit, e.Calories,
+		e.ProteinG, e.CarbsG, e.FatG, e.FiberG, e.SugarG, e.SodiumMg, e.Source).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error saving diary entry: %w", err)
+	}
+	return id, nil
+}
+
+// loadDiaryEntries returns entries between two YYYY-MM-DD dates (inclusive) in diary order
+func loadDiaryEntries(q execQueryer, userID int, from, to string) ([]models.DiaryEntry, error) {
+	rows, err := q.Query(`SELECT `+diaryColumns+` FROM diary_entries WHERE user_id = ? AND entry_date BETWEEN ? AND ?
+		ORDER BY entry_date, CASE meal WHEN 'breakfast' THEN 1 WHEN 'lunch' THEN 2 WHEN 'dinner' THEN 3 WHEN 'snacks' THEN 4 ELSE 5 END, id`,
+		userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.DiaryEntry{}
+	for rows.Next() {
+		var (
+			e      models.DiaryEntry
+			foodID sql.NullInt64
+		)
+		err := rows.Scan(&e.ID, &e.UserID, &e.Date, &e.Meal, &foodID, &e.Name, &e.Brand, &e.Quantity, &e.Unit, &e.Calories,
+			&e.ProteinG, &e.CarbsG, &e.FatG, &e.FiberG, &e.SugarG, &e.SodiumMg, &e.Source, &e.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if foodID.Valid {
+			id := int(foodID.Int64)
+			e.FoodID = &id
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func scanFood(scan func(dest ...interface{}) error) (models.Food, error) {
+	var (
+		f     models.Food
+		owner sql.NullInt64
+	)
+	if err := scan(&f.ID, &owner, &f.Name, &f.Brand, &f.ServingUnit, &f.Calories, &f.ProteinG, &f.CarbsG, &f.FatG, &f.CreatedAt); err != nil {
+		return f, err
+	}
+	if owner.Valid {
+		id := int(owner.Int64)
+		f.UserID = &id
+	}
+	return f, nil
+}
+
+// diaryRange reads the from/to query parameters. Without them the range covers the last defaultDays days,
+// or everything when defaultDays is 0.
+func diaryRange(r *http.Request, defaultDays int) (string, string, error) {
+	from, to := "0001-01-01", "9999-12-31"
+	if defaultDays > 0 {
+		today := time.Now().UTC()
+		from, to = today.AddDate(0, 0, -(defaultDays-1)).Format("2006-01-02"), today.Format("2006-01-02")
+	}
+	for _, p := range []struct {
+		name string
+		dst  *string
+	}{{"from", &from}, {"to", &to}} {
+		if v := r.URL.Query().Get(p.name); v != "" {
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return "", "", fmt.Errorf("%s must be a date in YYYY-MM-DD format", p.name)
+			}
+			*p.dst = t.Format("2006-01-02")
+		}
+	}
+	if from > to {
+		return "", "", errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
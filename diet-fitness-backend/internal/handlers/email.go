@@ -0,0 +1,149 @@
+// --- diet-fitness-backend/internal/handlers/email.go ---
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/mail"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+)
+
+const (
+	emailVerificationTTL = 48 * time.Hour
+	verificationCooldown = 2 * time.Minute // Minimum time between verification emails to the same user
+)
+
+// VerifyEmail marks the address as verified using the token from the verification email
+func VerifyEmail(db *sql.DB, users store.Users) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload models.VerifyEmailPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Token == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Token is required"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting email verification", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error verifying email"})
+			return
+		}
+		defer tx.Rollback()
+
+		userID, err := auth.ConsumeUserToken(tx, auth.PurposeEmailVerification, payload.Token)
+		if errors.Is(err, auth.ErrInvalidToken) {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Verification link is invalid or has expired"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error redeeming verification token", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error verifying email"})
+			return
+		}
+		if err := users.MarkVerified(r.Context(), tx, userID, time.Now().UTC()); err != nil {
+			slog.ErrorContext(r.Context(), "Error marking email as verified", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error verifying email"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing email verification", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error verifying email"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Email address verified"})
+	}
+}
+
+// ResendVerification sends a new verification link, at most once per cooldown period
+func ResendVerification(db *sql.DB, users store.Users, cfg *config.Config, mailer mail.Mailer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		user, err := users.ByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error sending verification email"})
+			return
+		}
+		if user.EmailVerifiedAt != nil {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Email address is already verified"})
+			return
+		}
+
+		var lastSent time.Time
+		err = db.QueryRow("SELECT created_at FROM user_tokens WHERE user_id = ? AND purpose = ? ORDER BY created_at DESC LIMIT 1",
+			userID, auth.PurposeEmailVerification).Scan(&lastSent)
+		if err != nil && err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "Error checking verification cooldown", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error sending verification email"})
+			return
+		}
+		if wait := verificationCooldown - time.Since(lastSent); err == nil && wait > 0 {
+			seconds := int(wait.Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			respondWithJSON(w, http.StatusTooManyRequests, models.ErrorResponse{Message: fmt.Sprintf("Please wait %d seconds before requesting another email", seconds)})
+			return
+		}
+
+		if err := sendVerificationEmail(db, cfg, mailer, userID, user.Email); err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error sending verification email"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Verification email sent"})
+	}
+}
+
+// sendVerificationEmail issues a new verification token and mails the link
+func sendVerificationEmail(db *sql.DB, cfg *config.Config, mailer mail.Mailer, userID int, email string) error {
+	token, err := auth.IssueUserToken(db, userID, auth.PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		slog.Error("Error creating verification token", "user_id", userID, "err", err)
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", strings.TrimRight(cfg.AppBaseURL, "/"), url.QueryEscape(token))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Confirm your FitPlan email address",
+		Body: fmt.Sprintf("Welcome to FitPlan!\n\nPlease confirm your email address by opening this link within %d hours:\n\n%s\n\n"+
+			"If you didn't create an account, you can ignore this email.\n", int(emailVerificationTTL.Hours()), link),
+	})
+	if err != nil {
+		slog.Error("Error sending verification email", "user_id", userID, "err", err)
+	}
+	return err
+}
+
+// requireVerifiedEmail writes a 403 and returns false unless the user has verified their address
+func requireVerifiedEmail(users store.Users, w http.ResponseWriter, r *http.Request, userID int) bool {
+	user, err := users.ByID(r.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking email verification", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error checking email verification"})
+		return false
+	}
+	if user.EmailVerifiedAt == nil {
+		respondWithJSON(w, http.StatusForbidden, models.ErrorResponse{Message: "Please verify your email address first; check your inbox or request a new link"})
+		return false
+	}
+	return true
+}
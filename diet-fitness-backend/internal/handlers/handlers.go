@@ -2,26 +2,39 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings" // Added for SQLite unique constraint error check
 	"time"
 
 	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/audit"
 	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/logging"
+	"diet-fitness-backend/internal/mail"
 	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/password"
+	"diet-fitness-backend/internal/store"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 // Helper function for JSON response
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	// Error responses carry the request ID so a client can quote it when reporting a problem
+	if e, ok := payload.(models.ErrorResponse); ok && e.RequestID == "" {
+		e.RequestID = w.Header().Get(logging.RequestIDHeader)
+		payload = e
+	}
 	response, err := json.Marshal(payload)
 	if err != nil {
 		http.Error(w, `{"message": "Error marshalling JSON response"}`, http.StatusInternalServerError)
@@ -32,8 +45,8 @@ func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	w.Write(response)
 }
 
-// RegisterUser handles new user registration
-func RegisterUser(db *sql.DB) http.HandlerFunc {
+// RegisterUser handles new user registration and sends the email verification link
+func RegisterUser(db *sql.DB, users store.Users, cfg *config.Config, mailer mail.Mailer, policy *password.Policy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var payload models.UserRegisterPayload
 		err := json.NewDecoder(r.Body).Decode(&payload)
@@ -46,6 +59,27 @@ func RegisterUser(db *sql.DB) http.HandlerFunc {
 			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Email and password are required"})
 			return
 		}
+		email, err := auth.NormalizeEmail(payload.Email)
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Please enter a valid email address"})
+			return
+		}
+		if msg := policy.Check(payload.Password, email); msg != "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+			return
+		}
+
+		// Accounts registered before normalization may differ only in case
+		_, err = users.ByEmail(r.Context(), email)
+		if err == nil {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "User with this email already exists"})
+			return
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.ErrorContext(r.Context(), "Error checking for existing user", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error registering user"})
+			return
+		}
 
 		// Hash the password
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.Password), bcrypt.DefaultCost)
@@ -54,28 +88,26 @@ func RegisterUser(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
-		// Insert user into database
-		var userID int
-		// SQLite uses ? for placeholders, and RETURNING id is supported.
-		err = db.QueryRow("INSERT INTO users (email, password_hash) VALUES (?, ?) RETURNING id",
-			payload.Email, string(hashedPassword)).Scan(&userID)
+		userID, err := users.Create(r.Context(), email, string(hashedPassword))
+		if errors.Is(err, store.ErrEmailTaken) {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "User with this email already exists"})
+			return
+		}
 		if err != nil {
-			// Check for unique constraint violation specifically for SQLite
-			if strings.Contains(err.Error(), "UNIQUE constraint failed") {
-				respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "User with this email already exists"})
-				return
-			}
-			log.Printf("Error inserting user: %v", err)
+			slog.ErrorContext(r.Context(), "Error inserting user", "err", err)
 			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error registering user"})
 			return
 		}
 
-		respondWithJSON(w, http.StatusCreated, map[string]string{"message": "User registered successfully", "user_id": fmt.Sprintf("%d", userID)})
+		go sendVerificationEmail(db, cfg, mailer, userID, email)
+		respondWithJSON(w, http.StatusCreated, map[string]string{"message": "User registered successfully; check your inbox to verify your email address", "user_id": fmt.Sprintf("%d", userID)})
 	}
 }
 
 // LoginUser handles user login and JWT generation
-func LoginUser(db *sql.DB, cfg *config.Config) http.HandlerFunc {
+func LoginUser(db *sql.DB, users store.Users, cfg *config.Config, keys *auth.KeyManager, auditLog *audit.Log) http.HandlerFunc {
+	limits := loginLimits(cfg)
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		var payload models.UserLoginPayload
 		err := json.NewDecoder(r.Body).Decode(&payload)
@@ -89,69 +121,168 @@ func LoginUser(db *sql.DB, cfg *config.Config) http.HandlerFunc {
 			return
 		}
 
-		// Retrieve user from database
-		var user models.User
-		var passwordHash string
-		err = db.QueryRow("SELECT id, email, password_hash FROM users WHERE email = ?", payload.Email).Scan(&user.ID, &user.Email, &passwordHash)
+		// Back off after failed attempts, per email and per client address. The attempt counts as a
+		// failure from here until it is settled, so parallel guesses can't slip past the check.
+		email := strings.ToLower(strings.TrimSpace(payload.Email))
+		ip := auth.ClientIP(r, cfg.TrustProxyHeaders)
+		attemptID, wait, err := auth.BeginLogin(db, limits, email, ip)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking login throttle", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+			return
+		}
+		if wait > 0 {
+			respondThrottled(w, r, auditLog, email, ip, wait)
+			return
+		}
+
+		user, err := users.ByEmail(r.Context(), email)
 		if err != nil {
-			if err == sql.ErrNoRows {
+			if errors.Is(err, store.ErrNotFound) {
+				// Take as long as a wrong password so response times don't reveal which emails exist
+				auth.CompareDummyPassword(payload.Password)
+				settleLogin(r.Context(), db, attemptID, email, ip, 0, auth.LoginUnknownAccount)
+				auditLog.Record(r, models.AuditEvent{Action: audit.ActionLoginFailed, Detail: auth.LoginUnknownAccount})
 				respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Invalid credentials"})
 				return
 			}
-			log.Printf("Error retrieving user: %v", err)
+			slog.ErrorContext(r.Context(), "Error retrieving user", "err", err)
 			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
 			return
 		}
 
-		// Compare password hash
-		err = bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(payload.Password))
+		// Compare password hash; accounts created through a sign-in provider have none
+		if user.PasswordHash == "" {
+			auth.CompareDummyPassword(payload.Password)
+			err = bcrypt.ErrMismatchedHashAndPassword
+		} else {
+			err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.Password))
+		}
 		if err != nil {
+			settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginBadPassword)
+			auditLog.Record(r, models.AuditEvent{Action: audit.ActionLoginFailed, TargetType: audit.TargetUser, TargetID: strconv.Itoa(user.ID), Detail: auth.LoginBadPassword})
 			respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Invalid credentials"})
 			return
 		}
 
+		// With two-factor on, the password only earns a short-lived challenge for /login/mfa. The login
+		// isn't a success yet, so earlier failures keep counting against the second factor.
+		enabled, err := mfaEnabled(db, user.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading MFA status", "user_id", user.ID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+			return
+		}
+		if enabled {
+			challenge, err := auth.IssueMFAChallenge(db, user.ID, "password", mfaChallengeTTL)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error creating MFA challenge", "user_id", user.ID, "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+				return
+			}
+			settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginMFARequired)
+			respondWithJSON(w, http.StatusOK, models.LoginResponse{MFARequired: true, MFAToken: challenge, Email: user.Email, EmailVerified: user.EmailVerifiedAt != nil})
+			return
+		}
+		settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginSucceeded)
+
 		// Generate JWT token
-		token, err := auth.GenerateJWT(user.ID, user.Email, cfg.JWTSecret)
+		token, access, err := issueJWT(db, keys, user.ID, user.Email, r.UserAgent(), ip)
 		if err != nil {
-			log.Printf("Error generating JWT: %v", err)
+			slog.ErrorContext(r.Context(), "Error generating JWT", "err", err)
 			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error generating token"})
 			return
 		}
+		auditLog.Record(r, models.AuditEvent{ActorID: audit.Actor(user.ID), Action: audit.ActionLogin, TargetType: audit.TargetUser, TargetID: strconv.Itoa(user.ID), Detail: "password"})
 
-		respondWithJSON(w, http.StatusOK, models.LoginResponse{Token: token, Email: user.Email})
+		respondWithJSON(w, http.StatusOK, models.LoginResponse{Token: token, Email: user.Email, EmailVerified: user.EmailVerifiedAt != nil, Roles: access.Roles})
 	}
 }
 
-// GetDashboardData provides mock dashboard data
-func GetDashboardData(w http.ResponseWriter, r *http.Request) {
-	// Access user ID from context (set by JWTMiddleware)
-	userID, ok := auth.GetUserIDFromContext(r)
-	if !ok {
-		// This should ideally not happen if middleware is working correctly
-		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "User ID not found in context"})
-		return
+// loginLimits are the backoff and lockout settings shared by both steps of a login
+func loginLimits(cfg *config.Config) auth.LoginLimits {
+	return auth.LoginLimits{MaxFailures: cfg.LoginMaxFailures, IPMaxFailures: cfg.LoginIPMaxFailures, Lockout: cfg.LoginLockout}
+}
+
+// respondThrottled rejects a login attempt made before the backoff has passed
+func respondThrottled(w http.ResponseWriter, r *http.Request, auditLog *audit.Log, email, ip string, wait time.Duration) {
+	slog.WarnContext(r.Context(), "Login failed", "outcome", auth.LoginThrottled, "email", email, "ip", ip)
+	auditLog.Record(r, models.AuditEvent{Action: audit.ActionLoginFailed, Detail: auth.LoginThrottled})
+	seconds := int(wait.Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	respondWithJSON(w, http.StatusTooManyRequests, models.ErrorResponse{Message: fmt.Sprintf("Too many failed logins; try again in %d seconds", seconds)})
+}
+
+// settleLogin records the outcome of an attempt from auth.BeginLogin; a failure to record is logged
+// rather than failing the login, and leaves the attempt counted as a failure
+func settleLogin(ctx context.Context, q auth.Querier, attemptID int, email, ip string, userID int, outcome string) {
+	if outcome != auth.LoginSucceeded && outcome != auth.LoginMFARequired && outcome != auth.LoginReauthenticated {
+		slog.WarnContext(ctx, "Login failed", "outcome", outcome, "email", email, "ip", ip)
+	}
+	if err := auth.FinishLogin(q, attemptID, userID, outcome); err != nil {
+		slog.ErrorContext(ctx, "Error recording login attempt", "err", err)
+	}
+}
+
+// issueJWT starts a session for the device and signs a token for it embedding the user's current
+// roles and permissions
+func issueJWT(db *sql.DB, keys *auth.KeyManager, userID int, email, userAgent, ip string) (string, auth.Access, error) {
+	access, err := auth.LoadAccess(db, userID)
+	if err != nil {
+		return "", access, err
 	}
-	userEmail, _ := auth.GetUserEmailFromContext(r) // Get email for personalization
+	sessionID, err := auth.CreateSession(db, userID, userAgent, ip)
+	if err != nil {
+		return "", access, err
+	}
+	token, err := keys.GenerateJWT(userID, email, sessionID, access)
+	return token, access, err
+}
+
+// GetDashboardData provides mock dashboard data along with training load alerts from the workout log
+func GetDashboardData(workouts store.Workouts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Access the user from context (set by the authentication middleware)
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		userEmail := principal.Email // Get email for personalization
 
-	log.Printf("User %d (%s) requested dashboard data.", userID, userEmail) // Log usage of userID and userEmail
+		slog.DebugContext(r.Context(), "User requested dashboard data", "user_id", userID, "email", userEmail)
+
+		data := models.DashboardData{
+			Message:        fmt.Sprintf("Welcome back, %s! Here's your personalized fitness overview.", userEmail),
+			UserName:       userEmail,
+			Progress:       "You've achieved 10% of your weight loss goal and improved endurance by 15%.",
+			LastUpdate:     time.Now().Format("Jan 02, 2006 15:04:05 MST"),
+			TrainingAlerts: []models.TrainingAlert{},
+		}
 
-	data := models.DashboardData{
-		Message:    fmt.Sprintf("Welcome back, %s! Here's your personalized fitness overview.", userEmail),
-		UserName:   userEmail,
-		Progress:   "You've achieved 10% of your weight loss goal and improved endurance by 15%.",
-		LastUpdate: time.Now().Format("Jan 02, 2006 15:04:05 MST"),
+		// Flag load spikes from the last 7 days; a failure here shouldn't take the whole dashboard down
+		now := time.Now().UTC()
+		report, err := trainingLoadReport(r.Context(), workouts, userID, now.AddDate(0, 0, -6), now)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error computing training load for dashboard", "user_id", userID, "err", err)
+		} else {
+			data.TrainingAlerts = report.Alerts
+			if n := len(report.Daily); n > 0 {
+				data.ACWR = report.Daily[n-1].ACWR
+			}
+		}
+		respondWithJSON(w, http.StatusOK, data)
 	}
-	respondWithJSON(w, http.StatusOK, data)
 }
 
 // UploadImage handles image uploads
-func UploadImage(uploadDir string) http.HandlerFunc {
+func UploadImage(uploads store.Uploads, uploadDir string, auditLog *audit.Log) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, ok := auth.GetUserIDFromContext(r)
+		principal, ok := auth.RequirePrincipal(w, r)
 		if !ok {
-			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "User ID not found in context"})
 			return
 		}
+		userID := principal.UserID
 
 		// Parse multipart form (max 10MB file size)
 		err := r.ParseMultipartForm(10 << 20) // 10 MB
@@ -174,89 +305,130 @@ func UploadImage(uploadDir string) http.HandlerFunc {
 		// Create the file on the server
 		dst, err := os.Create(filePath)
 		if err != nil {
-			log.Printf("Error creating file on server: %v", err)
+			slog.ErrorContext(r.Context(), "Error creating file on server", "err", err)
 			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving file"})
 			return
 		}
 		defer dst.Close()
 
 		// Copy the uploaded file data to the new file
-		if _, err := io.Copy(dst, file); err != nil {
-			log.Printf("Error copying file data: %v", err)
+		size, err := io.Copy(dst, file)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error copying file data", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving file"})
+			return
+		}
+
+		// Record the upload so it is included in data exports and removed with the account
+		upload := models.Upload{UserID: userID, Filename: filename, OriginalName: handler.Filename, SizeBytes: size}
+		uploadID, err := uploads.Add(r.Context(), upload)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error recording upload", "file", filename, "err", err)
+			dst.Close()
+			os.Remove(filePath)
 			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving file"})
 			return
 		}
 
-		// In a real app, you'd store metadata (user_id, file_path, upload_time) in the DB
-		log.Printf("User %d uploaded image: %s", userID, filename)
+		slog.InfoContext(r.Context(), "User uploaded an image", "user_id", userID, "file", filename)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionUpload, TargetType: audit.TargetUpload, TargetID: strconv.Itoa(uploadID),
+			Detail: fmt.Sprintf("%s, %d bytes", filename, size)})
 		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Image uploaded successfully", "filename": filename})
 	}
 }
 
-// GenerateFitnessPlan provides a mock AI-generated plan
-func GenerateFitnessPlan(w http.ResponseWriter, r *http.Request) {
-	// In a real application, you'd integrate with an AI model here.
-	// For example:
-	// 1. Read user prompt from request body
-	// 2. Call an external AI API (e.g., Gemini, OpenAI)
-	// 3. Process AI response to format it as FitnessPlan
-	// 4. Optionally save the plan to the database for the user
-
-	userID, ok := auth.GetUserIDFromContext(r)
-	if !ok {
-		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "User ID not found in context"})
-		return
-	}
+// GenerateFitnessPlan provides a mock AI-generated plan and stores a structured workout plan as the user's active plan
+func GenerateFitnessPlan(st store.Store, cfg *config.Config, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// In a real application, you'd integrate with an AI model here.
+		// For example:
+		// 1. Read user prompt from request body
+		// 2. Call an external AI API (e.g., Gemini, OpenAI)
+		// 3. Process AI response to format it as FitnessPlan
 
-	var req models.PlanGenerationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request for plan generation"})
-		return
-	}
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		if cfg.RequireVerifiedEmail && !requireVerifiedEmail(st.Users(), w, r, userID) {
+			return
+		}
+
+		var req models.PlanGenerationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request for plan generation"})
+			return
+		}
 
-	log.Printf("User %d requested plan with prompt: %s", userID, req.UserPrompt)
+		slog.InfoContext(r.Context(), "User requested a plan", "user_id", userID, "prompt", req.UserPrompt)
 
-	// Mock AI response
-	mockDietPlan := models.FitnessPlan{
-		Type:        "Diet",
-		Title:       "Personalized AI Diet Plan",
-		Description: fmt.Sprintf("Based on your goals '%s', your AI-powered diet plan focuses on balanced nutrition. Include 1800-2000 calories, high protein, complex carbs, and healthy fats. Emphasize lean meats, vegetables, fruits, and whole grains. Drink at least 3 liters of water daily.", req.UserPrompt),
-	}
-	mockWorkoutPlan := models.FitnessPlan{
-		Type:        "Workout",
-		Title:       "Personalized AI Workout Routine",
-		Description: fmt.Sprintf("Considering your request '%s', your AI-driven workout plan includes 3 days of strength training (full body) and 2 days of cardio (HIIT or steady-state). Ensure proper warm-up and cool-down. Include warm-up and cool-down stretches.", req.UserPrompt),
-	}
+		// Mock AI response
+		mockDietPlan := models.FitnessPlan{
+			Type:        "Diet",
+			Title:       "Personalized AI Diet Plan",
+			Description: fmt.Sprintf("Based on your goals '%s', your AI-powered diet plan focuses on balanced nutrition. Include 1800-2000 calories, high protein, complex carbs, and healthy fats. Emphasize lean meats, vegetables, fruits, and whole grains. Drink at least 3 liters of water daily.", req.UserPrompt),
+		}
+		mockWorkoutPlan := models.FitnessPlan{
+			Type:        "Workout",
+			Title:       "Personalized AI Workout Routine",
+			Description: fmt.Sprintf("Considering your request '%s', your AI-driven workout plan includes 3 days of strength training (full body) and 2 days of cardio (HIIT or steady-state). Ensure proper warm-up and cool-down. Include warm-up and cool-down stretches.", req.UserPrompt),
+		}
 
-	respondWithJSON(w, http.StatusOK, map[string][]models.FitnessPlan{
-		"plans": {mockDietPlan, mockWorkoutPlan},
-	})
-}
+		// Save the structured workout so the progression engine has targets to adjust after each session
+		workoutPlan, err := st.Plans().CreateActive(r.Context(), models.WorkoutPlan{
+			UserID:    userID,
+			Title:     mockWorkoutPlan.Title,
+			Exercises: preparePlanExercises(starterExercises()),
+		})
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving generated plan", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving generated plan"})
+			return
+		}
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionPlanGenerated, TargetType: audit.TargetPlan, TargetID: strconv.Itoa(workoutPlan.ID)})
 
-// GetFitnessPlan retrieves mock fitness plan data
-func GetFitnessPlan(w http.ResponseWriter, r *http.Request) {
-	// In a real app, you'd fetch the user's saved plan from the database.
-	// For now, return a generic mock plan or the one generated by GenerateFitnessPlan
-	userID, ok := auth.GetUserIDFromContext(r)
-	if !ok {
-		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "User ID not found in context"})
-		return
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"plans":        []models.FitnessPlan{mockDietPlan, mockWorkoutPlan},
+			"workout_plan": workoutPlan,
+		})
 	}
+}
 
-	// This is a placeholder; ideally, fetch from DB
-	genericDietPlan := models.FitnessPlan{
-		Type:        "Diet",
-		Title:       "Your Current Diet Plan",
-		Description: "Continue with your balanced diet of lean proteins, fresh vegetables, and whole grains. Remember portion control and adequate hydration.",
-	}
-	genericWorkoutPlan := models.FitnessPlan{
-		Type:        "Workout",
-		Title:       "Your Current Workout Plan",
-		Description: "Maintain your 4-day-a-week workout schedule, alternating between upper body and lower body strength training, with active recovery on rest days.",
-	}
+// GetFitnessPlan retrieves mock fitness plan data together with the user's active structured workout plan
+func GetFitnessPlan(plans store.Plans) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// In a real app, you'd fetch the user's saved plan from the database.
+		// For now, return a generic mock plan or the one generated by GenerateFitnessPlan
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		// This is a placeholder; ideally, fetch from DB
+		genericDietPlan := models.FitnessPlan{
+			Type:        "Diet",
+			Title:       "Your Current Diet Plan",
+			Description: "Continue with your balanced diet of lean proteins, fresh vegetables, and whole grains. Remember portion control and adequate hydration.",
+		}
+		genericWorkoutPlan := models.FitnessPlan{
+			Type:        "Workout",
+			Title:       "Your Current Workout Plan",
+			Description: "Maintain your 4-day-a-week workout schedule, alternating between upper body and lower body strength training, with active recovery on rest days.",
+		}
+
+		workoutPlan, err := plans.Active(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading active plan", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading plan"})
+			return
+		}
 
-	log.Printf("User %d requested current plan.", userID)
-	respondWithJSON(w, http.StatusOK, map[string][]models.FitnessPlan{
-		"plans": {genericDietPlan, genericWorkoutPlan},
-	})
+		slog.DebugContext(r.Context(), "User requested their current plan", "user_id", userID)
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{
+			"plans":        []models.FitnessPlan{genericDietPlan, genericWorkoutPlan},
+			"workout_plan": workoutPlan, // null until a plan has been generated or configured
+		})
+	}
 }
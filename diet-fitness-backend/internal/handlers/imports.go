@@ -0,0 +1,268 @@
+// --- diet-fitness-backend/internal/handlers/imports.go ---
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/healthimport"
+	"diet-fitness-backend/internal/jobs"
+	"diet-fitness-backend/internal/models"
+)
+
+const (
+	maxHealthExportSize = 2 << 30          // 2 GB; Apple Health exports of long-time watch users get big
+	uploadReadTimeout   = 30 * time.Minute // Replaces the server-wide read timeout for large uploads
+	importBatchSize     = 500              // Rows written per transaction while importing
+)
+
+// ImportHealthData accepts an Apple Health or Google Takeout zip and imports it in a background job
+func ImportHealthData(db *sql.DB, cfg *config.Config, runner *jobs.Runner, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		source := r.URL.Query().Get("source")
+		if source != "" && source != healthimport.SourceAppleHealth && source != healthimport.SourceGoogleFit {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: `source must be "apple_health", "google_fit" or omitted`})
+			return
+		}
+
+		if err := os.MkdirAll(cfg.ImportDir, 0700); err != nil {
+			slog.ErrorContext(r.Context(), "Error creating import directory", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving upload"})
+			return
+		}
+		zipPath := filepath.Join(cfg.ImportDir, fmt.Sprintf("user_%d_%d.zip", userID, time.Now().UnixNano()))
+		if status, msg := saveUploadedFile(w, r, "file", zipPath); status != 0 {
+			os.Remove(zipPath)
+			respondWithJSON(w, status, models.ErrorResponse{Message: msg})
+			return
+		}
+
+		// Reject anything we can't import now rather than failing later in the job
+		zr, err := zip.OpenReader(zipPath)
+		if err != nil {
+			os.Remove(zipPath)
+			respondWithJSON(w, http.StatusUnprocessableEntity, models.ErrorResponse{Message: "Uploaded file is not a valid zip archive"})
+			return
+		}
+		if source == "" {
+			source = healthimport.Detect(&zr.Reader)
+		}
+		zr.Close()
+		if source == "" {
+			os.Remove(zipPath)
+			respondWithJSON(w, http.StatusUnprocessableEntity, models.ErrorResponse{Message: healthimport.ErrUnknownExport.Error()})
+			return
+		}
+
+		jobID, err := runner.Submit(userID, "import:"+source, func(ctx context.Context, progress jobs.ProgressFunc) (interface{}, error) {
+			defer os.Remove(zipPath)
+			sink := &healthSink{db: db, userID: userID}
+			// Commit before each progress update; SQLite allows one writer, so an open batch would block the jobs table
+			report := func(percent float64, message string) {
+				sink.flush()
+				progress(percent, message)
+			}
+			summary, err := healthimport.Import(ctx, zipPath, source, sink, report)
+			if closeErr := sink.close(err == nil); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return nil, err
+			}
+			slog.InfoContext(r.Context(), "User imported an export", "user_id", userID, "source", source, "summary", summary)
+			return summary, nil
+		})
+		if err != nil {
+			os.Remove(zipPath)
+			if errors.Is(err, jobs.ErrQueueFull) {
+				respondWithJSON(w, http.StatusServiceUnavailable, models.ErrorResponse{Message: err.Error()})
+				return
+			}
+			slog.ErrorContext(r.Context(), "Error queueing import", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error starting import"})
+			return
+		}
+
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionImportRequested, TargetType: audit.TargetJob, TargetID: strconv.Itoa(jobID), Detail: source})
+		respondWithJSON(w, http.StatusAccepted, models.JobAcceptedResponse{
+			JobID:     jobID,
+			Status:    jobs.StatusQueued,
+			StatusURL: fmt.Sprintf("/api/jobs/%d", jobID),
+		})
+	}
+}
+
+// saveUploadedFile streams the named multipart field to dst without buffering it in memory.
+// It returns a non-zero HTTP status and message on failure.
+func saveUploadedFile(w http.ResponseWriter, r *http.Request, field, dst string) (int, string) {
+	// The server's ReadTimeout is sized for small JSON bodies
+	if err := http.NewResponseController(w).SetReadDeadline(time.Now().Add(uploadReadTimeout)); err != nil {
+		slog.ErrorContext(r.Context(), "Could not extend read deadline for upload", "err", err)
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxHealthExportSize)
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return http.StatusBadRequest, "Expected a multipart/form-data upload"
+	}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return http.StatusBadRequest, fmt.Sprintf("Missing %q file in form", field)
+		}
+		if err != nil {
+			return http.StatusBadRequest, "File upload error: " + err.Error()
+		}
+		if part.FormName() != field {
+			part.Close()
+			continue
+		}
+
+		out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error creating upload file", "err", err)
+			return http.StatusInternalServerError, "Error saving upload"
+		}
+		_, err = io.Copy(out, part)
+		closeErr := out.Close()
+		part.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, "Upload is too large"
+		}
+		if err != nil || closeErr != nil {
+			return http.StatusBadRequest, "Error receiving upload"
+		}
+		return 0, ""
+	}
+}
+
+// healthSink writes imported records in batched transactions; unique keys make re-imports idempotent
+type healthSink struct {
+	db      *sql.DB
+	userID  int
+	tx      *sql.Tx
+	pending int
+	err     error // First failed commit; later writes return it
+}
+
+// batch returns the open transaction, starting a new one when needed
+func (s *healthSink) batch() (*sql.Tx, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.tx == nil {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		s.tx = tx
+	}
+	return s.tx, nil
+}
+
+// wrote counts one write and commits the batch every importBatchSize writes
+func (s *healthSink) wrote() error {
+	s.pending++
+	if s.pending < importBatchSize {
+		return nil
+	}
+	s.flush()
+	return s.err
+}
+
+// flush commits the open batch, if any
+func (s *healthSink) flush() {
+	if s.tx == nil || s.err != nil {
+		return
+	}
+	tx := s.tx
+	s.tx, s.pending = nil, 0
+	s.err = tx.Commit()
+}
+
+// exec runs a statement inside the current batch
+func (s *healthSink) exec(query string, args ...interface{}) (sql.Result, error) {
+	tx, err := s.batch()
+	if err != nil {
+		return nil, err
+	}
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return res, s.wrote()
+}
+
+// close commits the last batch, or rolls it back when the import failed
+func (s *healthSink) close(commit bool) error {
+	if s.tx != nil && !commit {
+		tx := s.tx
+		s.tx, s.pending = nil, 0
+		return tx.Rollback()
+	}
+	s.flush()
+	return s.err
+}
+
+func inserted(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *healthSink) Weight(at time.Time, kg float64, source string) (bool, error) {
+	return inserted(s.exec(`INSERT INTO body_weights (user_id, measured_at, weight_kg, source) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, measured_at, source) DO NOTHING`, s.userID, at.UTC(), kg, source))
+}
+
+func (s *healthSink) DailySteps(day string, steps int, source string) (bool, error) {
+	// Only report a change when the total is new or different, so a re-import counts as duplicates
+	return inserted(s.exec(`INSERT INTO daily_steps (user_id, day, source, steps, updated_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, day, source) DO UPDATE SET steps = excluded.steps, updated_at = excluded.updated_at
+		WHERE daily_steps.steps <> excluded.steps`, s.userID, day, source, steps, time.Now().UTC()))
+}
+
+func (s *healthSink) HeartRate(at time.Time, bpm int, source string) (bool, error) {
+	return inserted(s.exec(`INSERT INTO heart_rate_samples (user_id, measured_at, source, bpm) VALUES (?, ?, ?, ?)
+		ON CONFLICT (user_id, measured_at, source) DO NOTHING`, s.userID, at.UTC(), source, bpm))
+}
+
+func (s *healthSink) Sleep(start, end time.Time, stage, source string) (bool, error) {
+	return inserted(s.exec(`INSERT INTO sleep_sessions (user_id, start_time, end_time, stage, source) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, start_time, stage, source) DO NOTHING`, s.userID, start.UTC(), end.UTC(), stage, source))
+}
+
+func (s *healthSink) Workout(a models.Activity) (bool, error) {
+	tx, err := s.batch()
+	if err != nil {
+		return false, err
+	}
+	a.UserID = s.userID
+	_, created, err := insertActivity(tx, a)
+	if err != nil {
+		return false, err
+	}
+	return created, s.wrote()
+}
@@ -0,0 +1,73 @@
+// --- diet-fitness-backend/internal/handlers/jobs.go ---
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/jobs"
+	"diet-fitness-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// GetJob reports the status, progress and result of one of the user's background jobs
+func GetJob(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid job ID"})
+			return
+		}
+
+		job, err := jobs.Get(db, userID, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Job not found"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading job", "job_id", id, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading job"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, job)
+	}
+}
+
+// ListJobs returns the user's most recent background jobs
+func ListJobs(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		limit := 20
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 || n > 100 {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "limit must be between 1 and 100"})
+				return
+			}
+			limit = n
+		}
+
+		list, err := jobs.List(db, userID, limit)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing jobs", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading jobs"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, list)
+	}
+}
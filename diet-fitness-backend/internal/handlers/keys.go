@@ -0,0 +1,60 @@
+// --- diet-fitness-backend/internal/handlers/keys.go ---
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+)
+
+// JWKS publishes the public keys that verify our tokens, so other services can check them without a shared secret
+func JWKS(keys *auth.KeyManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Successors are published an hour before they sign, well within this cache lifetime
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		respondWithJSON(w, http.StatusOK, keys.JWKS())
+	}
+}
+
+// RotateSigningKey starts signing with a fresh key immediately. Tokens signed with earlier keys stay
+// valid until they expire, unless ?retire=true: after a suspected key leak, that drops the earlier
+// keys at once and signs everyone out, so tokens forged with a leaked key stop working too.
+func RotateSigningKey(keys *auth.KeyManager, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		retire := false
+		if v := r.URL.Query().Get("retire"); v != "" {
+			var err error
+			if retire, err = strconv.ParseBool(v); err != nil {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "retire must be true or false"})
+				return
+			}
+		}
+
+		kid, err := keys.Rotate(retire)
+		if errors.Is(err, auth.ErrSharedSecret) {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: err.Error()})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error rotating JWT signing key", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error rotating signing key"})
+			return
+		}
+		slog.InfoContext(r.Context(), "Admin rotated the JWT signing key", "admin_id", principal.UserID, "kid", kid, "retired_previous", retire)
+		event := models.AuditEvent{Action: audit.ActionKeyRotated, TargetType: audit.TargetKey, TargetID: kid}
+		if retire {
+			event.Detail = "retired_previous"
+		}
+		auditLog.Record(r, event)
+		respondWithJSON(w, http.StatusOK, map[string]string{"kid": kid})
+	}
+}
@@ -0,0 +1,530 @@
+// --- diet-fitness-backend/internal/handlers/mfa.go ---
+package handlers
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+	"diet-fitness-backend/internal/totp"
+
+	"github.com/skip2/go-qrcode"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer        = "FitPlan"
+	mfaChallengeTTL   = 5 * time.Minute
+	maxMFAAttempts    = 5 // Wrong codes allowed per login challenge
+	recoveryCodeCount = 10
+)
+
+// GetMFAStatus reports whether two-factor authentication is on and how many recovery codes are left
+func GetMFAStatus(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var (
+			status    models.MFAStatus
+			enabledAt sql.NullTime
+		)
+		err := db.QueryRow("SELECT enabled_at FROM user_mfa WHERE user_id = ?", userID).Scan(&enabledAt)
+		if err != nil && err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "Error loading MFA status", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading two-factor status"})
+			return
+		}
+		if err == nil {
+			status.Enabled = enabledAt.Valid
+			status.PendingEnrollment = !enabledAt.Valid
+			if enabledAt.Valid {
+				status.EnabledAt = &enabledAt.Time
+			}
+		}
+		if err := db.QueryRow("SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL", userID).Scan(&status.RecoveryCodesRemaining); err != nil {
+			slog.ErrorContext(r.Context(), "Error counting recovery codes", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading two-factor status"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, status)
+	}
+}
+
+// EnrollTOTP starts enrollment with a new secret. Two-factor stays off until ConfirmTOTP sees a valid code.
+func EnrollTOTP(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		enabled, err := mfaEnabled(db, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading MFA status", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error starting two-factor enrollment"})
+			return
+		}
+		if enabled {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Two-factor authentication is already enabled; disable it first to enroll a new device"})
+			return
+		}
+
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating TOTP secret", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error starting two-factor enrollment"})
+			return
+		}
+		_, err = db.Exec(`INSERT INTO user_mfa (user_id, totp_secret, enabled_at, last_used_step, created_at) VALUES (?, ?, NULL, 0, ?)
+			ON CONFLICT (user_id) DO UPDATE SET totp_secret = excluded.totp_secret, last_used_step = 0, created_at = excluded.created_at`,
+			userID, secret, time.Now().UTC())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving TOTP secret", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error starting two-factor enrollment"})
+			return
+		}
+
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionMFAEnrollStarted, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID)})
+		email := principal.Email
+		respondWithJSON(w, http.StatusOK, models.TOTPEnrollResponse{
+			Secret:     secret,
+			OTPAuthURI: totp.URI(totpIssuer, email, secret),
+			QRCodeURL:  "/api/mfa/totp/qr.png",
+		})
+	}
+}
+
+// TOTPQRCode renders the pending enrollment's otpauth URI as a PNG QR code
+func TOTPQRCode(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var (
+			secret    string
+			enabledAt sql.NullTime
+		)
+		err := db.QueryRow("SELECT totp_secret, enabled_at FROM user_mfa WHERE user_id = ?", userID).Scan(&secret, &enabledAt)
+		if err == sql.ErrNoRows || (err == nil && enabledAt.Valid) {
+			// Once enabled the secret is never shown again
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "No two-factor enrollment in progress"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading TOTP secret", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error creating QR code"})
+			return
+		}
+
+		email := principal.Email
+		png, err := qrcode.Encode(totp.URI(totpIssuer, email, secret), qrcode.Medium, 256)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error encoding QR code", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error creating QR code"})
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(png)
+	}
+}
+
+// ConfirmTOTP turns two-factor authentication on once the user proves their app produces valid codes,
+// and returns the initial recovery codes
+func ConfirmTOTP(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.MFACodePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Code == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Code is required"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting TOTP confirmation", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error enabling two-factor authentication"})
+			return
+		}
+		defer tx.Rollback()
+
+		var (
+			secret    string
+			enabledAt sql.NullTime
+		)
+		err = tx.QueryRow("SELECT totp_secret, enabled_at FROM user_mfa WHERE user_id = ?", userID).Scan(&secret, &enabledAt)
+		if err == sql.ErrNoRows {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Start enrollment first"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading TOTP secret", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error enabling two-factor authentication"})
+			return
+		}
+		if enabledAt.Valid {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Two-factor authentication is already enabled"})
+			return
+		}
+
+		step, valid := totp.Validate(secret, payload.Code, time.Now(), 0)
+		if !valid {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid code; check your device's clock and try again"})
+			return
+		}
+		if _, err := tx.Exec("UPDATE user_mfa SET enabled_at = ?, last_used_step = ? WHERE user_id = ?", time.Now().UTC(), step, userID); err != nil {
+			slog.ErrorContext(r.Context(), "Error enabling MFA", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error enabling two-factor authentication"})
+			return
+		}
+		codes, err := replaceRecoveryCodes(tx, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error creating recovery codes", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error enabling two-factor authentication"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing MFA enrollment", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error enabling two-factor authentication"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "User enabled two-factor authentication", "user_id", userID)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionMFAEnabled, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID)})
+		respondWithJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// DisableTOTP turns two-factor authentication off; it needs a current code or recovery code, and the
+// password unless the account was created through a sign-in provider and has none
+func DisableTOTP(db *sql.DB, users store.Users, cfg *config.Config, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.MFACodePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || (payload.Code == "" && payload.RecoveryCode == "") {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "A code or recovery code is required"})
+			return
+		}
+
+		user, err := users.ByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error disabling two-factor authentication"})
+			return
+		}
+		if user.PasswordHash != "" && payload.Password == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Password and a code or recovery code are required"})
+			return
+		}
+		tx := reauthenticate(w, r, db, cfg, auditLog, user, payload, "Error disabling two-factor authentication")
+		if tx == nil {
+			return
+		}
+		defer tx.Rollback()
+
+		for _, q := range []string{"DELETE FROM user_mfa WHERE user_id = ?", "DELETE FROM mfa_recovery_codes WHERE user_id = ?"} {
+			if _, err := tx.Exec(q, userID); err != nil {
+				slog.ErrorContext(r.Context(), "Error disabling MFA", "user_id", userID, "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error disabling two-factor authentication"})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing MFA disable", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error disabling two-factor authentication"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "User disabled two-factor authentication", "user_id", userID)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionMFADisabled, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID)})
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes; it needs the password, when the account has one,
+// and a current TOTP code. New codes outlive a password change, so a stolen token alone mustn't mint them.
+func RegenerateRecoveryCodes(db *sql.DB, users store.Users, cfg *config.Config, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.MFACodePayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.Code == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Code is required"})
+			return
+		}
+		payload.RecoveryCode = "" // A recovery code can't stand in for the device here
+
+		user, err := users.ByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error regenerating recovery codes"})
+			return
+		}
+		if user.PasswordHash != "" && payload.Password == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Password and code are required"})
+			return
+		}
+		tx := reauthenticate(w, r, db, cfg, auditLog, user, payload, "Error regenerating recovery codes")
+		if tx == nil {
+			return
+		}
+		defer tx.Rollback()
+
+		codes, err := replaceRecoveryCodes(tx, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error creating recovery codes", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error regenerating recovery codes"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing recovery codes", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error regenerating recovery codes"})
+			return
+		}
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionCodesRegenerated, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID)})
+		respondWithJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+// CompleteMFALogin exchanges the challenge from LoginUser plus a TOTP or recovery code for a JWT. Codes
+// go through the same backoff and lockout as passwords, so fresh challenges don't buy fresh guesses.
+func CompleteMFALogin(db *sql.DB, users store.Users, cfg *config.Config, keys *auth.KeyManager, auditLog *audit.Log) http.HandlerFunc {
+	limits := loginLimits(cfg)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload models.MFALoginPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.MFAToken == "" || (payload.Code == "" && payload.RecoveryCode == "") {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "mfa_token and a code or recovery code are required"})
+			return
+		}
+
+		challengeID, userID, firstFactor, err := auth.CheckMFAChallenge(db, payload.MFAToken)
+		if errors.Is(err, auth.ErrInvalidToken) {
+			respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Login challenge is invalid or has expired; log in again"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading MFA challenge", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+			return
+		}
+		user, err := users.ByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+			return
+		}
+		email := user.Email
+
+		ip := auth.ClientIP(r, cfg.TrustProxyHeaders)
+		attemptID, wait, err := auth.BeginLogin(db, limits, strings.ToLower(email), ip)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking login throttle", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+			return
+		}
+		if wait > 0 {
+			respondThrottled(w, r, auditLog, strings.ToLower(email), ip, wait)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting MFA login", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+			return
+		}
+		defer tx.Rollback()
+
+		valid, err := verifySecondFactor(tx, userID, payload.Code, payload.RecoveryCode)
+		if err == nil && valid {
+			// Another request may have completed the challenge in the meantime
+			if err = auth.UseUserToken(tx, challengeID); errors.Is(err, auth.ErrInvalidToken) {
+				valid, err = false, nil
+			}
+		}
+		if err == nil && !valid {
+			err = auth.RecordTokenFailure(tx, challengeID, maxMFAAttempts)
+		}
+		if err == nil {
+			outcome := auth.LoginSucceeded
+			if !valid {
+				outcome = auth.LoginBadMFACode
+			}
+			settleLogin(r.Context(), tx, attemptID, strings.ToLower(email), ip, userID, outcome)
+			err = tx.Commit()
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error completing MFA login", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Server error during login"})
+			return
+		}
+		if !valid {
+			auditLog.Record(r, models.AuditEvent{Action: audit.ActionLoginFailed, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID), Detail: auth.LoginBadMFACode})
+			respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Invalid two-factor code"})
+			return
+		}
+
+		token, access, err := issueJWT(db, keys, userID, email, r.UserAgent(), ip)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating JWT", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error generating token"})
+			return
+		}
+		detail := firstFactor + "+totp"
+		if payload.Code == "" {
+			detail = firstFactor + "+recovery_code"
+		}
+		auditLog.Record(r, models.AuditEvent{ActorID: audit.Actor(userID), Action: audit.ActionLogin, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID), Detail: detail})
+		respondWithJSON(w, http.StatusOK, models.LoginResponse{Token: token, Email: email, EmailVerified: user.EmailVerifiedAt != nil, Roles: access.Roles})
+	}
+}
+
+// reauthenticate checks the password, when the account has one, and the code or recovery code of a
+// signed-in user about to change their two-factor setup. The checks count towards the same backoff and
+// lockout as logins, so a stolen token can't be used to guess codes. When they fail it answers the
+// request and returns nil; otherwise it returns a transaction in which the code has been used up and
+// the attempt settled, to commit together with the change.
+func reauthenticate(w http.ResponseWriter, r *http.Request, db *sql.DB, cfg *config.Config, auditLog *audit.Log, user models.User, payload models.MFACodePayload, errMessage string) *sql.Tx {
+	email, ip := strings.ToLower(user.Email), auth.ClientIP(r, cfg.TrustProxyHeaders)
+	attemptID, wait, err := auth.BeginLogin(db, loginLimits(cfg), email, ip)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking login throttle", "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: errMessage})
+		return nil
+	}
+	if wait > 0 {
+		respondThrottled(w, r, auditLog, email, ip, wait)
+		return nil
+	}
+
+	if user.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.Password)) != nil {
+		settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginBadPassword)
+		respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Incorrect password"})
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error starting two-factor change", "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: errMessage})
+		return nil
+	}
+	valid, err := verifySecondFactor(tx, user.ID, payload.Code, payload.RecoveryCode)
+	if err != nil || !valid {
+		tx.Rollback()
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error verifying second factor", "user_id", user.ID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: errMessage})
+		return nil
+	}
+	if !valid {
+		settleLogin(r.Context(), db, attemptID, email, ip, user.ID, auth.LoginBadMFACode)
+		respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Invalid two-factor code"})
+		return nil
+	}
+	settleLogin(r.Context(), tx, attemptID, email, ip, user.ID, auth.LoginReauthenticated)
+	return tx
+}
+
+// mfaEnabled reports whether the user has confirmed a TOTP device
+func mfaEnabled(q execQueryer, userID int) (bool, error) {
+	var enabledAt sql.NullTime
+	err := q.QueryRow("SELECT enabled_at FROM user_mfa WHERE user_id = ?", userID).Scan(&enabledAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return enabledAt.Valid, err
+}
+
+// verifySecondFactor checks a TOTP code (rejecting replays) or burns a recovery code
+func verifySecondFactor(q execQueryer, userID int, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		var (
+			secret    string
+			lastStep  int64
+			enabledAt sql.NullTime
+		)
+		err := q.QueryRow("SELECT totp_secret, last_used_step, enabled_at FROM user_mfa WHERE user_id = ?", userID).Scan(&secret, &lastStep, &enabledAt)
+		if err == sql.ErrNoRows || (err == nil && !enabledAt.Valid) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		step, valid := totp.Validate(secret, code, time.Now(), lastStep)
+		if !valid {
+			return false, nil
+		}
+		_, err = q.Exec("UPDATE user_mfa SET last_used_step = ? WHERE user_id = ?", step, userID)
+		return err == nil, err
+	}
+
+	res, err := q.Exec("UPDATE mfa_recovery_codes SET used_at = ? WHERE user_id = ? AND code_hash = ? AND used_at IS NULL",
+		time.Now().UTC(), userID, auth.HashToken(normalizeRecoveryCode(recoveryCode)))
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and stores hashes of new ones
+func replaceRecoveryCodes(q execQueryer, userID int) ([]string, error) {
+	if _, err := q.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 10) // 80 bits
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("error generating recovery code: %w", err)
+		}
+		raw := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		if _, err := q.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)",
+			userID, auth.HashToken(normalizeRecoveryCode(code)), time.Now().UTC()); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes so codes can be typed loosely
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,428 @@
+// --- diet-fitness-backend/internal/handlers/oidc.go ---
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/oidc"
+	"diet-fitness-backend/internal/store"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	oidcStateTTL    = 10 * time.Minute
+	oidcStateCookie = "fitplan_oidc_state" // Binds the callback to the browser that started the login
+	oidcCookiePath  = "/api/auth/oidc/"
+)
+
+// errOIDCLogin carries a message that is safe to show the user on the frontend
+type errOIDCLogin struct{ message string }
+
+func (e errOIDCLogin) Error() string { return e.message }
+
+// ListOIDCProviders returns the names of the providers users can sign in with
+func ListOIDCProviders(providers oidc.Providers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(providers))
+		for name := range providers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		respondWithJSON(w, http.StatusOK, names)
+	}
+}
+
+// StartOIDCLogin sends the browser to the provider's sign-in page
+func StartOIDCLogin(db *sql.DB, cfg *config.Config, providers oidc.Providers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := providers[mux.Vars(r)["provider"]]
+		if !ok {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Unknown sign-in provider"})
+			return
+		}
+		authURL, err := startOIDC(db, cfg, w, r, provider, 0)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting sign-in", "provider", provider.Name, "err", err)
+			respondWithJSON(w, http.StatusBadGateway, models.ErrorResponse{Message: "Sign-in provider is unavailable"})
+			return
+		}
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// StartOIDCLink returns the provider URL that links a provider account to the signed-in user.
+// The frontend navigates there itself, since a redirect can't carry the Authorization header.
+func StartOIDCLink(db *sql.DB, cfg *config.Config, providers oidc.Providers) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		provider, ok := providers[mux.Vars(r)["provider"]]
+		if !ok {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Unknown sign-in provider"})
+			return
+		}
+		authURL, err := startOIDC(db, cfg, w, r, provider, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting link", "provider", provider.Name, "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusBadGateway, models.ErrorResponse{Message: "Sign-in provider is unavailable"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"url": authURL})
+	}
+}
+
+// OIDCCallback finishes a provider sign-in: it redeems the code, finds or creates the account and sends
+// the browser back to the frontend with our own token (or an MFA challenge) in the URL fragment
+func OIDCCallback(db *sql.DB, users store.Users, cfg *config.Config, keys *auth.KeyManager, providers oidc.Providers, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := providers[mux.Vars(r)["provider"]]
+		if !ok {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Unknown sign-in provider"})
+			return
+		}
+		result := url.Values{}
+		finish := func() {
+			http.Redirect(w, r, strings.TrimSuffix(cfg.AppBaseURL, "/")+"/auth/callback#"+result.Encode(), http.StatusFound)
+		}
+
+		// The state must match the cookie set when this browser started, or someone else's login could be
+		// completed here
+		query := r.URL.Query()
+		state := query.Get("state")
+		cookie, err := r.Cookie(oidcStateCookie)
+		http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: oidcCookiePath, MaxAge: -1, HttpOnly: true})
+		if state == "" || err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			result.Set("error", "Sign-in expired or was started in another browser; please try again")
+			finish()
+			return
+		}
+
+		var (
+			nonce, verifier, stateProvider string
+			linkUserID                     sql.NullInt64
+			expiresAt                      time.Time
+		)
+		err = db.QueryRow("DELETE FROM oidc_states WHERE state_hash = ? RETURNING provider, nonce, code_verifier, user_id, expires_at",
+			auth.HashToken(state)).Scan(&stateProvider, &nonce, &verifier, &linkUserID, &expiresAt)
+		if err != nil && err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "Error loading sign-in state", "provider", provider.Name, "err", err)
+		}
+		if err != nil || stateProvider != provider.Name || time.Now().UTC().After(expiresAt) {
+			result.Set("error", "Sign-in expired or was started in another browser; please try again")
+			finish()
+			return
+		}
+		if e := query.Get("error"); e != "" {
+			slog.WarnContext(r.Context(), "Sign-in failed at the provider", "provider", provider.Name, "error", e, "error_description", query.Get("error_description"))
+			result.Set("error", "Sign-in was cancelled or refused by the provider")
+			finish()
+			return
+		}
+
+		id, err := provider.Exchange(r.Context(), query.Get("code"), verifier)
+		if err == nil && subtle.ConstantTimeCompare([]byte(id.Nonce), []byte(nonce)) != 1 {
+			err = errors.New("nonce mismatch")
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error completing sign-in", "provider", provider.Name, "err", err)
+			result.Set("error", "Could not complete sign-in with the provider")
+			finish()
+			return
+		}
+
+		if linkUserID.Valid {
+			err = linkIdentity(db, int(linkUserID.Int64), provider.Name, id)
+			if err == nil {
+				slog.InfoContext(r.Context(), "User linked their account", "user_id", linkUserID.Int64, "provider", provider.Name)
+				result.Set("linked", provider.Name)
+			}
+		} else {
+			err = completeOIDCLogin(db, users, cfg, keys, auditLog, r, provider.Name, id, result)
+		}
+		var userErr errOIDCLogin
+		if errors.As(err, &userErr) {
+			result.Set("error", userErr.message)
+		} else if err != nil {
+			slog.ErrorContext(r.Context(), "Error signing in", "provider", provider.Name, "err", err)
+			result.Set("error", "Server error during sign-in")
+		}
+		finish()
+	}
+}
+
+// ListIdentities returns the provider accounts linked to the user
+func ListIdentities(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		rows, err := db.Query("SELECT id, provider, email, created_at, last_login_at FROM user_identities WHERE user_id = ? ORDER BY id", userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing identities", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading linked accounts"})
+			return
+		}
+		defer rows.Close()
+
+		identities := []models.Identity{}
+		for rows.Next() {
+			var (
+				i         models.Identity
+				lastLogin sql.NullTime
+			)
+			if err := rows.Scan(&i.ID, &i.Provider, &i.Email, &i.CreatedAt, &lastLogin); err != nil {
+				slog.ErrorContext(r.Context(), "Error scanning identity", "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading linked accounts"})
+				return
+			}
+			if lastLogin.Valid {
+				i.LastLoginAt = &lastLogin.Time
+			}
+			identities = append(identities, i)
+		}
+		if err := rows.Err(); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing identities", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading linked accounts"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, identities)
+	}
+}
+
+// UnlinkIdentity removes a linked provider account. Accounts without a password keep at least one.
+func UnlinkIdentity(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		identityID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid identity ID"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting identity unlink", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error unlinking account"})
+			return
+		}
+		defer tx.Rollback()
+
+		var (
+			passwordHash string
+			identities   int
+		)
+		err = tx.QueryRow("SELECT u.password_hash, (SELECT COUNT(*) FROM user_identities WHERE user_id = u.id) FROM users u WHERE u.id = ?", userID).
+			Scan(&passwordHash, &identities)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error unlinking account"})
+			return
+		}
+		res, err := tx.Exec("DELETE FROM user_identities WHERE id = ? AND user_id = ?", identityID, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error unlinking identity", "identity_id", identityID, "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error unlinking account"})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Linked account not found"})
+			return
+		}
+		if passwordHash == "" && identities <= 1 {
+			respondWithJSON(w, http.StatusConflict, models.ErrorResponse{Message: "Set a password before unlinking your only sign-in provider"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing identity unlink", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error unlinking account"})
+			return
+		}
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionIdentityUnlinked, TargetType: audit.TargetIdentity, TargetID: strconv.Itoa(identityID)})
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Account unlinked"})
+	}
+}
+
+// startOIDC stores a new sign-in state, binds it to the browser with a cookie and returns the
+// provider URL. userID is set when linking to a signed-in account.
+func startOIDC(db *sql.DB, cfg *config.Config, w http.ResponseWriter, r *http.Request, provider *oidc.Provider, userID int) (string, error) {
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, challenge, err := oidc.NewPKCE()
+	if err != nil {
+		return "", err
+	}
+	authURL, err := provider.AuthURL(r.Context(), state, nonce, challenge)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	if _, err := db.Exec("DELETE FROM oidc_states WHERE expires_at < ?", now); err != nil {
+		return "", err
+	}
+	var linkUser sql.NullInt64
+	if userID != 0 {
+		linkUser = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	_, err = db.Exec("INSERT INTO oidc_states (state_hash, provider, nonce, code_verifier, user_id, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		auth.HashToken(state), provider.Name, nonce, verifier, linkUser, now.Add(oidcStateTTL), now)
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcCookiePath,
+		MaxAge:   int(oidcStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.APIBaseURL, "https://"),
+		SameSite: http.SameSiteLaxMode, // Sent on the provider's top-level redirect back to us
+	})
+	return authURL, nil
+}
+
+// linkIdentity attaches a provider account to a signed-in user
+func linkIdentity(db *sql.DB, userID int, provider string, id *oidc.IDToken) error {
+	var owner int
+	err := db.QueryRow("SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, id.Subject).Scan(&owner)
+	if err == nil && owner != userID {
+		return errOIDCLogin{"This provider account is already linked to another user"}
+	}
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	_, err = db.Exec("INSERT INTO user_identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, provider, id.Subject, id.Email, time.Now().UTC())
+	return err
+}
+
+// linkOIDCByEmail records a first sign-in with a provider account, creating the user or linking the
+// one registered under the verified email. A sign-in racing this one for the same account or email
+// fails on the unique constraints and can simply be retried.
+func linkOIDCByEmail(ctx context.Context, db *sql.DB, users store.Users, provider string, id *oidc.IDToken, now time.Time) (int, error) {
+	existing, err := users.ByEmail(ctx, id.Email)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return 0, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID := existing.ID
+	switch {
+	case userID == 0:
+		if userID, err = users.CreateVerified(ctx, tx, id.Email, now); err != nil {
+			return 0, err
+		}
+		slog.InfoContext(ctx, "Created user from sign-in", "user_id", userID, "provider", provider)
+	case existing.EmailVerifiedAt == nil:
+		// Whoever registered this unverified address never proved they own it; the provider just did.
+		// Drop their password and sessions so an account registered in advance can't be taken over.
+		if err := users.SetPassword(ctx, tx, userID, ""); err != nil {
+			return 0, err
+		}
+		if err := users.MarkVerified(ctx, tx, userID, now); err != nil {
+			return 0, err
+		}
+		if err := auth.RevokeTokens(tx, userID); err != nil {
+			return 0, err
+		}
+		slog.InfoContext(ctx, "Linked sign-in to an unverified user and cleared their password", "provider", provider, "user_id", userID)
+	default:
+		slog.InfoContext(ctx, "Linked sign-in to user by verified email", "provider", provider, "user_id", userID)
+	}
+	_, err = tx.Exec("INSERT INTO user_identities (user_id, provider, subject, email, created_at) VALUES (?, ?, ?, ?, ?)",
+		userID, provider, id.Subject, id.Email, now)
+	if err != nil {
+		return 0, err
+	}
+	return userID, tx.Commit()
+}
+
+// completeOIDCLogin finds the user for a verified provider identity, linking or creating the account
+// by verified email on first sign-in, and puts our token into result
+func completeOIDCLogin(db *sql.DB, users store.Users, cfg *config.Config, keys *auth.KeyManager, auditLog *audit.Log, r *http.Request, provider string, id *oidc.IDToken, result url.Values) error {
+	ctx := r.Context()
+	now := time.Now().UTC()
+	var userID int
+	err := db.QueryRow("SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?", provider, id.Subject).Scan(&userID)
+	if err == sql.ErrNoRows {
+		// First sign-in with this provider account: match on email, which the provider must vouch for
+		if id.Email == "" || !id.EmailVerified {
+			return errOIDCLogin{"The provider did not share a verified email address"}
+		}
+		userID, err = linkOIDCByEmail(ctx, db, users, provider, id, now)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("UPDATE user_identities SET email = ?, last_login_at = ? WHERE provider = ? AND subject = ?",
+		id.Email, now, provider, id.Subject); err != nil {
+		return err
+	}
+
+	user, err := users.ByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	email := user.Email
+
+	// The provider replaces the password, not the second factor
+	enabled, err := mfaEnabled(db, userID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		challenge, err := auth.IssueMFAChallenge(db, userID, "oidc:"+provider, mfaChallengeTTL)
+		if err != nil {
+			return err
+		}
+		result.Set("mfa_required", "true")
+		result.Set("mfa_token", challenge)
+		return nil
+	}
+	token, _, err := issueJWT(db, keys, userID, email, r.UserAgent(), auth.ClientIP(r, cfg.TrustProxyHeaders))
+	if err != nil {
+		return err
+	}
+	auditLog.Record(r, models.AuditEvent{ActorID: audit.Actor(userID), Action: audit.ActionLogin, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID), Detail: "oidc:" + provider})
+	result.Set("token", token)
+	return nil
+}
@@ -0,0 +1,323 @@
+// --- diet-fitness-backend/internal/handlers/password.go ---
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/mail"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/password"
+	"diet-fitness-backend/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL      = time.Hour
+	passwordResetCooldown = 2 * time.Minute // Minimum time between reset emails to the same address
+	passwordResetIPLimit  = 10              // Reset requests accepted from one client address per window
+	passwordResetIPWindow = time.Hour
+)
+
+// passwordResetSlots bounds how many reset emails are being prepared or sent at once
+var passwordResetSlots = make(chan struct{}, 4)
+
+// ForgotPassword emails a password reset link. The response is the same whether or not the
+// address belongs to an account, and the work happens in the background so timing doesn't tell either.
+// Each client address gets a limited number of requests per window, and each account at most one email
+// per cooldown period.
+func ForgotPassword(db *sql.DB, users store.Users, cfg *config.Config, mailer mail.Mailer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload models.ForgotPasswordPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || strings.TrimSpace(payload.Email) == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Email is required"})
+			return
+		}
+
+		wait, err := admitPasswordReset(db, auth.ClientIP(r, cfg.TrustProxyHeaders))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error throttling password reset", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error requesting password reset"})
+			return
+		}
+		if wait > 0 {
+			seconds := int(wait.Seconds()) + 1
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			respondWithJSON(w, http.StatusTooManyRequests, models.ErrorResponse{Message: fmt.Sprintf("Too many reset requests; try again in %d seconds", seconds)})
+			return
+		}
+
+		select {
+		case passwordResetSlots <- struct{}{}:
+			go func(email string) {
+				defer func() { <-passwordResetSlots }()
+				sendPasswordReset(db, users, cfg, mailer, email)
+			}(strings.ToLower(strings.TrimSpace(payload.Email)))
+		default:
+			slog.WarnContext(r.Context(), "Password reset dropped: too many being sent")
+		}
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "If an account exists for that email, a reset link has been sent"})
+	}
+}
+
+// admitPasswordReset records a reset request from the client address, or returns how long it must wait
+// when it has used up its requests for the window
+func admitPasswordReset(db *sql.DB, ip string) (time.Duration, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	since := now.Add(-passwordResetIPWindow)
+	var requests int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM password_reset_requests WHERE ip = ? AND created_at > ?", ip, since).Scan(&requests); err != nil {
+		return 0, fmt.Errorf("error counting reset requests: %w", err)
+	}
+	if requests >= passwordResetIPLimit {
+		// Another request is allowed once the oldest one in the window expires
+		var oldest time.Time
+		if err := tx.QueryRow("SELECT created_at FROM password_reset_requests WHERE ip = ? AND created_at > ? ORDER BY created_at LIMIT 1 OFFSET ?",
+			ip, since, requests-passwordResetIPLimit).Scan(&oldest); err != nil {
+			return 0, fmt.Errorf("error loading reset requests: %w", err)
+		}
+		if wait := oldest.Add(passwordResetIPWindow).Sub(now); wait > 0 {
+			return wait, nil
+		}
+	}
+
+	if _, err := tx.Exec("INSERT INTO password_reset_requests (ip, created_at) VALUES (?, ?)", ip, now); err != nil {
+		return 0, fmt.Errorf("error recording reset request: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM password_reset_requests WHERE created_at < ?", since); err != nil {
+		return 0, fmt.Errorf("error pruning reset requests: %w", err)
+	}
+	return 0, tx.Commit()
+}
+
+// sendPasswordReset mails a reset link to the account with that address, unless one was sent within
+// the cooldown. The check and the new token share a transaction so parallel requests send one email.
+func sendPasswordReset(db *sql.DB, users store.Users, cfg *config.Config, mailer mail.Mailer, email string) {
+	user, err := users.ByEmail(context.Background(), email)
+	if errors.Is(err, store.ErrNotFound) {
+		return
+	}
+	if err != nil {
+		slog.Error("Error looking up user for password reset", "err", err)
+		return
+	}
+	userID := user.ID
+
+	tx, err := db.Begin()
+	if err != nil {
+		slog.Error("Error starting password reset", "err", err)
+		return
+	}
+	defer tx.Rollback()
+
+	var lastSent time.Time
+	err = tx.QueryRow("SELECT created_at FROM user_tokens WHERE user_id = ? AND purpose = ? ORDER BY created_at DESC LIMIT 1",
+		userID, auth.PurposePasswordReset).Scan(&lastSent)
+	if err != nil && err != sql.ErrNoRows {
+		slog.Error("Error checking password reset cooldown", "user_id", userID, "err", err)
+		return
+	}
+	if err == nil && time.Since(lastSent) < passwordResetCooldown {
+		slog.Info("Password reset skipped: one was sent recently", "user_id", userID)
+		return
+	}
+
+	token, err := auth.IssueUserToken(tx, userID, auth.PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		slog.Error("Error creating password reset token", "user_id", userID, "err", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error creating password reset token", "user_id", userID, "err", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(cfg.AppBaseURL, "/"), url.QueryEscape(token))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	err = mailer.Send(ctx, mail.Message{
+		To:      email,
+		Subject: "Reset your FitPlan password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your FitPlan account.\n\n"+
+			"Open this link within %d minutes to choose a new password:\n\n%s\n\n"+
+			"If it wasn't you, ignore this email; your password stays the same.\n", int(passwordResetTTL.Minutes()), link),
+	})
+	if err != nil {
+		slog.Error("Error sending password reset email", "user_id", userID, "err", err)
+	}
+}
+
+// ResetPassword sets a new password using a single-use reset token and signs out every existing session
+func ResetPassword(db *sql.DB, users store.Users, policy *password.Policy, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload models.ResetPasswordPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		if payload.Token == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Token is required"})
+			return
+		}
+
+		// The link is only used up once the new password is accepted, so a rejected one can be retried
+		_, userID, err := auth.CheckUserToken(db, auth.PurposePasswordReset, payload.Token)
+		if errors.Is(err, auth.ErrInvalidToken) {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Reset link is invalid or has expired"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error checking password reset token", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error resetting password"})
+			return
+		}
+		user, err := users.ByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user for password reset", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error resetting password"})
+			return
+		}
+		if msg := policy.Check(payload.NewPassword, user.Email); msg != "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error hashing password"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting password reset", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error resetting password"})
+			return
+		}
+		defer tx.Rollback()
+
+		// Redeemed in the transaction, so a link submitted twice at once still works only once
+		if _, err := auth.ConsumeUserToken(tx, auth.PurposePasswordReset, payload.Token); err != nil {
+			if errors.Is(err, auth.ErrInvalidToken) {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Reset link is invalid or has expired"})
+				return
+			}
+			slog.ErrorContext(r.Context(), "Error redeeming password reset token", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error resetting password"})
+			return
+		}
+		if err := users.SetPassword(r.Context(), tx, userID, string(hashedPassword)); err != nil {
+			slog.ErrorContext(r.Context(), "Error updating password", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error resetting password"})
+			return
+		}
+		if err := auth.RevokeTokens(tx, userID); err != nil {
+			slog.ErrorContext(r.Context(), "Error revoking sessions", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error resetting password"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing password reset", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error resetting password"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "User reset their password", "user_id", userID)
+		auditLog.Record(r, models.AuditEvent{ActorID: audit.Actor(userID), Action: audit.ActionPasswordReset, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID)})
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset; please log in with your new password"})
+	}
+}
+
+// ChangePassword replaces the signed-in user's password after checking the current one. Every other
+// session is signed out, and the response carries a fresh token for this device.
+func ChangePassword(db *sql.DB, users store.Users, cfg *config.Config, keys *auth.KeyManager, policy *password.Policy, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.ChangePasswordPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.NewPassword == "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "new_password is required"})
+			return
+		}
+
+		user, err := users.ByID(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading user for password change", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error changing password"})
+			return
+		}
+		// Accounts created through an identity provider have no password to confirm
+		if user.PasswordHash != "" && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(payload.CurrentPassword)) != nil {
+			respondWithJSON(w, http.StatusUnauthorized, models.ErrorResponse{Message: "Incorrect current password"})
+			return
+		}
+		if payload.NewPassword == payload.CurrentPassword {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "The new password must differ from the current one"})
+			return
+		}
+		if msg := policy.Check(payload.NewPassword, user.Email); msg != "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+			return
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(payload.NewPassword), bcrypt.DefaultCost)
+		if err != nil {
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error hashing password"})
+			return
+		}
+		tx, err := db.Begin()
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error starting password change", "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error changing password"})
+			return
+		}
+		defer tx.Rollback()
+		if err := users.SetPassword(r.Context(), tx, userID, string(hashedPassword)); err != nil {
+			slog.ErrorContext(r.Context(), "Error updating password", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error changing password"})
+			return
+		}
+		if err := auth.RevokeTokens(tx, userID); err != nil {
+			slog.ErrorContext(r.Context(), "Error revoking sessions", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error changing password"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			slog.ErrorContext(r.Context(), "Error committing password change", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error changing password"})
+			return
+		}
+		slog.InfoContext(r.Context(), "User changed their password", "user_id", userID)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionPasswordChanged, TargetType: audit.TargetUser, TargetID: strconv.Itoa(userID)})
+
+		token, access, err := issueJWT(db, keys, userID, user.Email, r.UserAgent(), auth.ClientIP(r, cfg.TrustProxyHeaders))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error generating JWT after password change", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Password changed; please log in again"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, models.LoginResponse{Token: token, Email: user.Email, EmailVerified: user.EmailVerifiedAt != nil, Roles: access.Roles})
+	}
+}
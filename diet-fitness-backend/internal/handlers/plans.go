@@ -0,0 +1,159 @@
+// --- diet-fitness-backend/internal/handlers/plans.go ---
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/progression"
+	"diet-fitness-backend/internal/store"
+)
+
+// execQueryer is satisfied by both *sql.DB and *sql.Tx so helpers can run inside or outside a transaction
+type execQueryer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// starterExercises is the full-body template every generated plan starts from
+func starterExercises() []models.PlanExercise {
+	return []models.PlanExercise{
+		{Name: "Back Squat", MuscleGroup: "quads", Scheme: string(progression.Linear), Sets: 3, TargetReps: 5, LoadKg: 40, IncrementKg: 2.5},
+		{Name: "Bench Press", MuscleGroup: "chest", Scheme: string(progression.DoubleProgression), Sets: 3, RepsMin: 8, RepsMax: 12, TargetReps: 8, LoadKg: 30, IncrementKg: 2.5},
+		{Name: "Barbell Row", MuscleGroup: "back", Scheme: string(progression.DoubleProgression), Sets: 3, RepsMin: 8, RepsMax: 12, TargetReps: 8, LoadKg: 30, IncrementKg: 2.5},
+		{Name: "Overhead Press", MuscleGroup: "shoulders", Scheme: string(progression.Linear), Sets: 3, TargetReps: 5, LoadKg: 20, IncrementKg: 1.25},
+		{Name: "Romanian Deadlift", MuscleGroup: "hamstrings", Scheme: string(progression.RPE), Sets: 3, TargetReps: 8, TargetRPE: 8, LoadKg: 40, IncrementKg: 2.5},
+	}
+}
+
+// exerciseState converts a stored plan exercise into progression engine state
+func exerciseState(e models.PlanExercise) progression.State {
+	return progression.State{
+		Scheme: progression.Scheme(e.Scheme),
+		Prescription: progression.Prescription{
+			Sets:       e.Sets,
+			TargetReps: e.TargetReps,
+			RepsMin:    e.RepsMin,
+			RepsMax:    e.RepsMax,
+			LoadKg:     e.LoadKg,
+			TargetRPE:  e.TargetRPE,
+			WaveWeek:   e.WaveWeek,
+		},
+		IncrementKg:   e.IncrementKg,
+		OneRepMaxKg:   e.OneRepMaxKg,
+		FailureStreak: e.FailureStreak,
+		DeloadAfter:   e.DeloadAfter,
+		DeloadPercent: e.DeloadPercent,
+	}
+}
+
+// applyState writes progression engine state back onto a plan exercise
+func applyState(e models.PlanExercise, s progression.State) models.PlanExercise {
+	e.Scheme = string(s.Scheme)
+	e.Sets = s.Sets
+	e.TargetReps = s.TargetReps
+	e.RepsMin = s.RepsMin
+	e.RepsMax = s.RepsMax
+	e.LoadKg = s.LoadKg
+	e.TargetRPE = s.TargetRPE
+	e.WaveWeek = s.WaveWeek
+	e.IncrementKg = s.IncrementKg
+	e.OneRepMaxKg = s.OneRepMaxKg
+	e.FailureStreak = s.FailureStreak
+	e.DeloadAfter = s.DeloadAfter
+	e.DeloadPercent = s.DeloadPercent
+	return e
+}
+
+// preparePlanExercises normalizes new plan exercises and fills in their starting prescriptions
+func preparePlanExercises(exercises []models.PlanExercise) []models.PlanExercise {
+	prepared := make([]models.PlanExercise, len(exercises))
+	for i, e := range exercises {
+		e.MuscleGroup = strings.ToLower(e.MuscleGroup)
+		prepared[i] = applyState(e, progression.Initial(exerciseState(e)))
+	}
+	return prepared
+}
+
+// validatePlanExercises returns a message describing the first problem with a plan's exercises, or ""
+func validatePlanExercises(exercises []models.PlanExercise) string {
+	if len(exercises) == 0 {
+		return "At least one exercise is required"
+	}
+	for _, e := range exercises {
+		if strings.TrimSpace(e.Name) == "" {
+			return "Every exercise needs a name"
+		}
+		if e.Scheme != "" && !progression.Scheme(e.Scheme).IsValid() {
+			return fmt.Sprintf("Unknown progression scheme %q", e.Scheme)
+		}
+		if e.Sets < 0 || e.TargetReps < 0 || e.LoadKg < 0 {
+			return "Sets, reps and load must not be negative"
+		}
+	}
+	return ""
+}
+
+// UpdatePlanExercises replaces the exercises (and their progression schemes) of the user's active plan
+func UpdatePlanExercises(plans store.Plans, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var payload models.UpdatePlanExercisesPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		if msg := validatePlanExercises(payload.Exercises); msg != "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+			return
+		}
+
+		plan, err := plans.Active(r.Context(), userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading plan", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error updating plan"})
+			return
+		}
+
+		exercises := preparePlanExercises(payload.Exercises)
+		if plan == nil {
+			title := payload.Title
+			if title == "" {
+				title = "My Workout Plan"
+			}
+			_, err = plans.CreateActive(r.Context(), models.WorkoutPlan{UserID: userID, Title: title, Exercises: exercises})
+		} else {
+			err = plans.ReplaceExercises(r.Context(), plan.ID, payload.Title, exercises)
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving plan", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error updating plan"})
+			return
+		}
+
+		updated, err := plans.Active(r.Context(), userID)
+		if err != nil || updated == nil {
+			slog.ErrorContext(r.Context(), "Error reloading plan", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error updating plan"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "User updated their active plan", "user_id", userID, "exercises", len(updated.Exercises))
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionPlanUpdated, TargetType: audit.TargetPlan, TargetID: strconv.Itoa(updated.ID)})
+		respondWithJSON(w, http.StatusOK, updated)
+	}
+}
@@ -0,0 +1,135 @@
+// --- diet-fitness-backend/internal/handlers/profile.go ---
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/physiology"
+)
+
+// GetProfile returns the user's physiological profile (empty fields when not set yet)
+func GetProfile(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		profile, err := loadProfile(db, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading profile", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading profile"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, profile)
+	}
+}
+
+// UpdateProfile replaces the user's physiological profile
+func UpdateProfile(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var p models.UserProfile
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		p.Sex = strings.ToLower(strings.TrimSpace(p.Sex))
+		p.MaxHRFormula = strings.ToLower(strings.TrimSpace(p.MaxHRFormula))
+		if msg := validateProfile(p); msg != "" {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: msg})
+			return
+		}
+
+		p.UserID = userID
+		p.UpdatedAt = time.Now().UTC()
+		_, err := db.Exec(`INSERT INTO user_profiles (user_id, birth_date, sex, weight_kg, height_cm, resting_hr, max_hr, max_hr_formula, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (user_id) DO UPDATE SET birth_date = excluded.birth_date, sex = excluded.sex, weight_kg = excluded.weight_kg,
+				height_cm = excluded.height_cm, resting_hr = excluded.resting_hr, max_hr = excluded.max_hr,
+				max_hr_formula = excluded.max_hr_formula, updated_at = excluded.updated_at`,
+			p.UserID, p.BirthDate, p.Sex, p.WeightKg, p.HeightCm, p.RestingHR, p.MaxHR, p.MaxHRFormula, p.UpdatedAt)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error saving profile", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error saving profile"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, p)
+	}
+}
+
+// GetHeartRateZones returns the user's current heart-rate zones
+func GetHeartRateZones(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		profile, err := loadProfile(db, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error loading profile", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading heart-rate zones"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, physiology.Zones(profile, time.Now()))
+	}
+}
+
+// validateProfile returns a user-facing message for the first invalid field, or "" when the profile is valid.
+// Zero values mean "unknown" and are always accepted.
+func validateProfile(p models.UserProfile) string {
+	if p.BirthDate != "" {
+		if _, ok := physiology.Age(p.BirthDate, time.Now()); !ok {
+			return "birth_date must be a past date in YYYY-MM-DD format"
+		}
+		if age, _ := physiology.Age(p.BirthDate, time.Now()); age > 120 {
+			return "birth_date is too far in the past"
+		}
+	}
+	switch {
+	case p.Sex != "" && p.Sex != "male" && p.Sex != "female":
+		return `sex must be "male", "female" or empty`
+	case p.WeightKg != 0 && (p.WeightKg < 20 || p.WeightKg > 400):
+		return "weight_kg must be between 20 and 400"
+	case p.HeightCm != 0 && (p.HeightCm < 50 || p.HeightCm > 275):
+		return "height_cm must be between 50 and 275"
+	case p.RestingHR != 0 && (p.RestingHR < 25 || p.RestingHR > 120):
+		return "resting_hr must be between 25 and 120"
+	case p.MaxHR != 0 && (p.MaxHR < 100 || p.MaxHR > 230):
+		return "max_hr must be between 100 and 230"
+	case p.RestingHR != 0 && p.MaxHR != 0 && p.RestingHR >= p.MaxHR:
+		return "resting_hr must be lower than max_hr"
+	case p.MaxHRFormula != "" && p.MaxHRFormula != "tanaka" && p.MaxHRFormula != "fox":
+		return `max_hr_formula must be "tanaka" or "fox"`
+	}
+	return ""
+}
+
+// loadProfile returns the stored profile, or an empty one when the user hasn't filled it in
+func loadProfile(q execQueryer, userID int) (models.UserProfile, error) {
+	p := models.UserProfile{UserID: userID}
+	var updatedAt sql.NullTime
+	err := q.QueryRow(`SELECT birth_date, sex, weight_kg, height_cm, resting_hr, max_hr, max_hr_formula, updated_at
+		FROM user_profiles WHERE user_id = ?`, userID).
+		Scan(&p.BirthDate, &p.Sex, &p.WeightKg, &p.HeightCm, &p.RestingHR, &p.MaxHR, &p.MaxHRFormula, &updatedAt)
+	if err == sql.ErrNoRows {
+		return p, nil
+	}
+	p.UpdatedAt = updatedAt.Time
+	return p, err
+}
@@ -0,0 +1,86 @@
+// --- diet-fitness-backend/internal/handlers/sessions.go ---
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"diet-fitness-backend/internal/audit"
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+
+	"github.com/gorilla/mux"
+)
+
+// ListSessions returns the devices the user is signed in on, most recently used first
+func ListSessions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		current := principal.SessionID
+
+		rows, err := db.Query(`SELECT id, user_agent, ip, created_at, last_seen_at, expires_at FROM user_sessions
+			WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC, id DESC`, userID, time.Now().UTC())
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing sessions", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading sessions"})
+			return
+		}
+		defer rows.Close()
+
+		sessions := []models.LoginSession{}
+		for rows.Next() {
+			var s models.LoginSession
+			if err := rows.Scan(&s.ID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+				slog.ErrorContext(r.Context(), "Error scanning session", "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading sessions"})
+				return
+			}
+			s.Current = s.ID == current
+			sessions = append(sessions, s)
+		}
+		if err := rows.Err(); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing sessions", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading sessions"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, sessions)
+	}
+}
+
+// RevokeSession signs a device out; its token stops working on the next request.
+// Revoking the current session is a logout.
+func RevokeSession(db *sql.DB, auditLog *audit.Log) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid session ID"})
+			return
+		}
+
+		err = auth.RevokeSession(db, userID, sessionID)
+		if err == auth.ErrSessionRevoked {
+			respondWithJSON(w, http.StatusNotFound, models.ErrorResponse{Message: "Session not found"})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error revoking session", "session_id", sessionID, "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error signing out session"})
+			return
+		}
+		slog.InfoContext(r.Context(), "User signed out session", "user_id", userID, "session_id", sessionID)
+		auditLog.Record(r, models.AuditEvent{Action: audit.ActionSessionRevoked, TargetType: audit.TargetSession, TargetID: strconv.Itoa(sessionID)})
+		respondWithJSON(w, http.StatusOK, map[string]string{"message": "Session signed out"})
+	}
+}
@@ -0,0 +1,109 @@
+// --- diet-fitness-backend/internal/handlers/weights.go ---
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+)
+
+// weightSourceManual marks weights entered through the API rather than imported from a health export
+const weightSourceManual = "manual"
+
+// ListWeights returns body weight measurements, newest first, optionally limited to ?from= and ?to= (YYYY-MM-DD)
+func ListWeights(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		query := "SELECT id, measured_at, weight_kg, source FROM body_weights WHERE user_id = ?"
+		args := []interface{}{userID}
+		for _, bound := range []struct{ param, cond string }{{"from", " AND measured_at >= ?"}, {"to", " AND measured_at < ?"}} {
+			v := r.URL.Query().Get(bound.param)
+			if v == "" {
+				continue
+			}
+			day, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: bound.param + " must be a date (YYYY-MM-DD)"})
+				return
+			}
+			if bound.param == "to" {
+				day = day.AddDate(0, 0, 1) // Inclusive of the whole day
+			}
+			query += bound.cond
+			args = append(args, day)
+		}
+		rows, err := db.Query(query+" ORDER BY measured_at DESC LIMIT 1000", args...)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error listing weights", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading weights"})
+			return
+		}
+		defer rows.Close()
+
+		weights := []models.BodyWeight{}
+		for rows.Next() {
+			var bw models.BodyWeight
+			if err := rows.Scan(&bw.ID, &bw.MeasuredAt, &bw.WeightKg, &bw.Source); err != nil {
+				slog.ErrorContext(r.Context(), "Error scanning weight", "err", err)
+				respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading weights"})
+				return
+			}
+			weights = append(weights, bw)
+		}
+		if err := rows.Err(); err != nil {
+			slog.ErrorContext(r.Context(), "Error listing weights", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading weights"})
+			return
+		}
+		respondWithJSON(w, http.StatusOK, weights)
+	}
+}
+
+// AddWeight records a body weight measurement; recording the same time again replaces the value
+func AddWeight(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		var payload models.BodyWeightPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		if payload.WeightKg < 20 || payload.WeightKg > 500 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "weight_kg must be between 20 and 500"})
+			return
+		}
+		now := time.Now().UTC()
+		bw := models.BodyWeight{MeasuredAt: now, WeightKg: payload.WeightKg, Source: weightSourceManual}
+		if payload.MeasuredAt != nil {
+			bw.MeasuredAt = payload.MeasuredAt.UTC()
+		}
+		if bw.MeasuredAt.After(now.Add(time.Hour)) {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "measured_at can't be in the future"})
+			return
+		}
+
+		err := db.QueryRow(`INSERT INTO body_weights (user_id, measured_at, weight_kg, source) VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id, measured_at, source) DO UPDATE SET weight_kg = excluded.weight_kg RETURNING id`,
+			userID, bw.MeasuredAt, bw.WeightKg, bw.Source).Scan(&bw.ID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error recording weight", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error recording weight"})
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, bw)
+	}
+}
@@ -0,0 +1,189 @@
+// --- diet-fitness-backend/internal/handlers/workouts.go ---
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/progression"
+	"diet-fitness-backend/internal/store"
+)
+
+// errInvalidWorkout rejects a logged session with a message for the client
+type errInvalidWorkout struct{ message string }
+
+func (e errInvalidWorkout) Error() string { return e.message }
+
+// LogWorkout records a training session and runs the progression engine for every plan exercise it touched
+func LogWorkout(st store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+
+		var payload models.LogWorkoutPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Invalid request payload"})
+			return
+		}
+		if len(payload.Sets) == 0 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "At least one set is required"})
+			return
+		}
+		for _, s := range payload.Sets {
+			if s.Reps < 0 || s.LoadKg < 0 || s.RPE < 0 || s.RPE > 10 {
+				respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Reps and load must not be negative and RPE must be between 0 and 10"})
+				return
+			}
+		}
+
+		if payload.SessionRPE < 0 || payload.SessionRPE > 10 || payload.DurationMin < 0 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "Session RPE must be between 0 and 10 and duration must not be negative"})
+			return
+		}
+
+		performedAt := time.Now().UTC()
+		if payload.PerformedAt != nil {
+			performedAt = payload.PerformedAt.UTC()
+		}
+
+		// The plan is read and progressed in the same write as the session, so two sessions logged at
+		// once don't both start from the old prescription
+		var changes []models.ProgressionChange
+		session, err := st.Workouts().Progress(r.Context(), userID, func(plan *models.WorkoutPlan) (*models.WorkoutSession, []models.PlanExercise, error) {
+			// Resolve every set to a plan exercise, either by explicit ID or by name
+			exercisesByID := map[int]models.PlanExercise{}
+			exercisesByName := map[string]models.PlanExercise{}
+			var planID *int
+			if plan != nil {
+				planID = &plan.ID
+				for _, e := range plan.Exercises {
+					exercisesByID[e.ID] = e
+					exercisesByName[strings.ToLower(e.Name)] = e
+				}
+			}
+
+			session := models.WorkoutSession{
+				UserID:      userID,
+				PlanID:      planID,
+				PerformedAt: performedAt,
+				SessionRPE:  payload.SessionRPE,
+				DurationMin: payload.DurationMin,
+				Notes:       payload.Notes,
+			}
+			performed := map[int][]progression.SetResult{}
+			var order []int
+			for i, s := range payload.Sets {
+				if s.PlanExerciseID != nil {
+					e, found := exercisesByID[*s.PlanExerciseID]
+					if !found {
+						return nil, nil, errInvalidWorkout{fmt.Sprintf("Exercise %d is not part of your active plan", *s.PlanExerciseID)}
+					}
+					if s.ExerciseName == "" {
+						s.ExerciseName = e.Name
+					}
+					if s.MuscleGroup == "" {
+						s.MuscleGroup = e.MuscleGroup
+					}
+				} else if e, found := exercisesByName[strings.ToLower(strings.TrimSpace(s.ExerciseName))]; found {
+					id := e.ID
+					s.PlanExerciseID = &id
+					if s.MuscleGroup == "" {
+						s.MuscleGroup = e.MuscleGroup
+					}
+				}
+				s.MuscleGroup = strings.ToLower(strings.TrimSpace(s.MuscleGroup))
+				if strings.TrimSpace(s.ExerciseName) == "" {
+					return nil, nil, errInvalidWorkout{"Every set needs an exercise name or plan exercise ID"}
+				}
+				if s.SetNumber <= 0 {
+					s.SetNumber = i + 1
+				}
+
+				if s.PlanExerciseID != nil {
+					if _, seen := performed[*s.PlanExerciseID]; !seen {
+						order = append(order, *s.PlanExerciseID)
+					}
+					performed[*s.PlanExerciseID] = append(performed[*s.PlanExerciseID], progression.SetResult{Reps: s.Reps, LoadKg: s.LoadKg, RPE: s.RPE})
+				}
+				session.Sets = append(session.Sets, s)
+			}
+
+			// Compute the next prescription for each exercise and write it back into the active plan
+			changes = []models.ProgressionChange{}
+			var progressed []models.PlanExercise
+			for _, id := range order {
+				previous := exercisesByID[id]
+				outcome := progression.Next(exerciseState(previous), performed[id])
+				next := applyState(previous, outcome.Next)
+				progressed = append(progressed, next)
+				changes = append(changes, models.ProgressionChange{
+					PlanExerciseID: id,
+					ExerciseName:   previous.Name,
+					Success:        outcome.Success,
+					Deload:         outcome.Deload,
+					Reason:         outcome.Reason,
+					Previous:       previous,
+					Next:           next,
+				})
+			}
+			return &session, progressed, nil
+		})
+		var invalid errInvalidWorkout
+		if errors.As(err, &invalid) {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: invalid.message})
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error recording workout", "user_id", userID, "err", err)
+			respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error logging workout"})
+			return
+		}
+
+		slog.InfoContext(r.Context(), "User logged a workout", "user_id", userID, "session_id", session.ID, "sets", len(session.Sets), "exercises_progressed", len(changes))
+		respondWithJSON(w, http.StatusCreated, models.LogWorkoutResponse{Session: *session, Progression: changes})
+	}
+}
+
+// ListWorkouts returns the user's most recent sessions, newest first
+func ListWorkouts(workouts store.Workouts) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		principal, ok := auth.RequirePrincipal(w, r)
+		if !ok {
+			return
+		}
+		userID := principal.UserID
+		serveWorkouts(workouts, w, r, userID)
+	}
+}
+
+// serveWorkouts writes the latest sessions of a user; shared with the coach's view of a client
+func serveWorkouts(workouts store.Workouts, w http.ResponseWriter, r *http.Request, userID int) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 100 {
+			respondWithJSON(w, http.StatusBadRequest, models.ErrorResponse{Message: "limit must be between 1 and 100"})
+			return
+		}
+		limit = n
+	}
+
+	sessions, err := workouts.Recent(r.Context(), userID, limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error loading sessions", "user_id", userID, "err", err)
+		respondWithJSON(w, http.StatusInternalServerError, models.ErrorResponse{Message: "Error loading workouts"})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string][]models.WorkoutSession{"sessions": sessions})
+}
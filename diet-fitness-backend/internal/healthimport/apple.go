@@ -0,0 +1,255 @@
+// --- diet-fitness-backend/internal/healthimport/apple.go ---
+package healthimport
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/activityfile"
+	"diet-fitness-backend/internal/models"
+)
+
+// appleDateLayout is the timestamp format used throughout export.xml
+const appleDateLayout = "2006-01-02 15:04:05 -0700"
+
+// appleSleepStages maps HealthKit sleep category values onto our stage names
+var appleSleepStages = map[string]string{
+	"HKCategoryValueSleepAnalysisInBed":             "in_bed",
+	"HKCategoryValueSleepAnalysisAsleep":            "asleep",
+	"HKCategoryValueSleepAnalysisAsleepUnspecified": "asleep",
+	"HKCategoryValueSleepAnalysisAsleepCore":        "core",
+	"HKCategoryValueSleepAnalysisAsleepDeep":        "deep",
+	"HKCategoryValueSleepAnalysisAsleepREM":         "rem",
+	"HKCategoryValueSleepAnalysisAwake":             "awake",
+}
+
+// importAppleHealth streams export.xml token by token; exports of several GB never have to fit in memory
+func importAppleHealth(ctx context.Context, zr *zip.Reader, sink Sink, progress ProgressFunc) (Summary, error) {
+	summary := Summary{Source: SourceAppleHealth}
+
+	var export *zip.File
+	for _, f := range zr.File {
+		if path.Base(f.Name) == "export.xml" {
+			export = f
+			break
+		}
+	}
+	if export == nil {
+		return summary, errors.New("export.xml not found in zip")
+	}
+
+	rc, err := export.Open()
+	if err != nil {
+		return summary, fmt.Errorf("error opening export.xml: %w", err)
+	}
+	defer rc.Close()
+
+	counter := &countingReader{r: rc}
+	dec := xml.NewDecoder(counter)
+	total := float64(export.UncompressedSize64)
+	steps := stepTotals{}
+	lastReport := time.Now()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return summary, fmt.Errorf("error reading export.xml: %w", err)
+		}
+
+		if time.Since(lastReport) > time.Second && total > 0 {
+			// Leave the last few percent for the step totals written at the end
+			progress(float64(counter.n)/total*95, "Reading Apple Health records")
+			lastReport = time.Now()
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "Record":
+			if err := appleRecord(start, sink, steps, &summary); err != nil {
+				return summary, err
+			}
+		case "Workout":
+			if err := appleWorkout(dec, start, sink, &summary); err != nil {
+				return summary, err
+			}
+		}
+	}
+
+	progress(95, "Saving daily step totals")
+	if err := steps.flush(ctx, sink, SourceAppleHealth, &summary); err != nil {
+		return summary, err
+	}
+	progress(100, "Apple Health import finished")
+	return summary, nil
+}
+
+func attrs(e xml.StartElement) map[string]string {
+	m := make(map[string]string, len(e.Attr))
+	for _, a := range e.Attr {
+		m[a.Name.Local] = a.Value
+	}
+	return m
+}
+
+func appleRecord(e xml.StartElement, sink Sink, steps stepTotals, summary *Summary) error {
+	a := attrs(e)
+	kind := a["type"]
+	switch kind {
+	case "HKQuantityTypeIdentifierBodyMass", "HKQuantityTypeIdentifierStepCount", "HKQuantityTypeIdentifierHeartRate", "HKCategoryTypeIdentifierSleepAnalysis":
+	default:
+		return nil
+	}
+
+	start, err := time.Parse(appleDateLayout, a["startDate"])
+	if err != nil {
+		summary.Skipped++
+		return nil
+	}
+
+	switch kind {
+	case "HKQuantityTypeIdentifierBodyMass":
+		v, err := parseNumber(a["value"])
+		if err != nil || v <= 0 {
+			summary.Skipped++
+			return nil
+		}
+		switch a["unit"] {
+		case "lb":
+			v *= 0.45359237
+		case "g":
+			v /= 1000
+		}
+		inserted, err := sink.Weight(start, v, SourceAppleHealth)
+		return summary.count(&summary.Weights, inserted, err)
+
+	case "HKQuantityTypeIdentifierStepCount":
+		v, err := parseNumber(a["value"])
+		if err != nil || v < 0 {
+			summary.Skipped++
+			return nil
+		}
+		// The day in the recording device's own time zone, as the Health app shows it
+		steps[start.Format("2006-01-02")] += int(v)
+
+	case "HKQuantityTypeIdentifierHeartRate":
+		v, err := parseNumber(a["value"])
+		if err != nil || v <= 0 || v > 250 {
+			summary.Skipped++
+			return nil
+		}
+		inserted, err := sink.HeartRate(start, int(v+0.5), SourceAppleHealth)
+		return summary.count(&summary.HeartRateSamples, inserted, err)
+
+	case "HKCategoryTypeIdentifierSleepAnalysis":
+		end, err := time.Parse(appleDateLayout, a["endDate"])
+		stage, known := appleSleepStages[a["value"]]
+		if err != nil || !known || !end.After(start) {
+			summary.Skipped++
+			return nil
+		}
+		inserted, err := sink.Sleep(start, end, stage, SourceAppleHealth)
+		return summary.count(&summary.SleepSessions, inserted, err)
+	}
+	return nil
+}
+
+// appleWorkout reads a Workout element and its children (WorkoutStatistics carry distance and heart rate since iOS 16)
+func appleWorkout(dec *xml.Decoder, e xml.StartElement, sink Sink, summary *Summary) error {
+	a := attrs(e)
+	start, err1 := time.Parse(appleDateLayout, a["startDate"])
+	end, err2 := time.Parse(appleDateLayout, a["endDate"])
+	if err1 != nil || err2 != nil {
+		summary.Skipped++
+		return dec.Skip()
+	}
+
+	activity := models.Activity{
+		Source:    SourceAppleHealth,
+		Sport:     activityfile.NormalizeSport(strings.TrimPrefix(a["workoutActivityType"], "HKWorkoutActivityType")),
+		StartTime: start.UTC().Truncate(time.Second),
+		Device:    strings.TrimSpace(a["sourceName"]),
+		DurationS: end.Sub(start).Seconds(),
+		Splits:    []models.ActivitySplit{},
+	}
+	if activity.Device == "" {
+		activity.Device = "Apple Health"
+	}
+	if d, err := parseNumber(a["duration"]); err == nil && d > 0 {
+		activity.DurationS = durationSeconds(d, a["durationUnit"])
+	}
+	if d, err := parseNumber(a["totalDistance"]); err == nil {
+		activity.DistanceM = distanceMetres(d, a["totalDistanceUnit"])
+	}
+
+	// Walk the children until the matching end element
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("error reading workout: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if t.Name.Local != "WorkoutStatistics" {
+				continue
+			}
+			s := attrs(t)
+			switch s["type"] {
+			case "HKQuantityTypeIdentifierDistanceWalkingRunning", "HKQuantityTypeIdentifierDistanceCycling", "HKQuantityTypeIdentifierDistanceSwimming":
+				if d, err := parseNumber(s["sum"]); err == nil && activity.DistanceM == 0 {
+					activity.DistanceM = distanceMetres(d, s["unit"])
+				}
+			case "HKQuantityTypeIdentifierHeartRate":
+				if v, err := parseNumber(s["average"]); err == nil {
+					activity.AvgHeartRate = int(v + 0.5)
+				}
+				if v, err := parseNumber(s["maximum"]); err == nil {
+					activity.MaxHeartRate = int(v + 0.5)
+				}
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+
+	inserted, err := sink.Workout(activity)
+	return summary.count(&summary.Workouts, inserted, err)
+}
+
+func durationSeconds(v float64, unit string) float64 {
+	switch unit {
+	case "s", "sec":
+		return v
+	case "hr", "h":
+		return v * 3600
+	}
+	return v * 60 // HealthKit exports minutes by default
+}
+
+func distanceMetres(v float64, unit string) float64 {
+	switch unit {
+	case "m":
+		return v
+	case "mi":
+		return v * 1609.344
+	case "yd":
+		return v * 0.9144
+	}
+	return v * 1000 // km
+}
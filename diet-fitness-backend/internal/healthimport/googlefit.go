@@ -0,0 +1,247 @@
+// --- diet-fitness-backend/internal/healthimport/googlefit.go ---
+package healthimport
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/activityfile"
+	"diet-fitness-backend/internal/models"
+)
+
+// Google Takeout's Fit folder holds "All Data" (one JSON file per data source, each with a
+// "Data Points" array) and "All Sessions" (one JSON file per workout or sleep session).
+// Only the merged, derived data sources are read; raw per-device sources duplicate them.
+
+type googleFitValue struct {
+	FpVal  *float64 `json:"fpVal"`
+	IntVal *int64   `json:"intVal"`
+}
+
+type googleDataPoint struct {
+	DataTypeName   string `json:"dataTypeName"`
+	StartTimeNanos int64  `json:"startTimeNanos,string"`
+	EndTimeNanos   int64  `json:"endTimeNanos,string"`
+	FitValue       []struct {
+		Value googleFitValue `json:"value"`
+	} `json:"fitValue"`
+}
+
+type googleSession struct {
+	FitnessActivity string `json:"fitnessActivity"`
+	StartTime       string `json:"startTime"`
+	EndTime         string `json:"endTime"`
+	Duration        string `json:"duration"` // e.g. "1800.000s"
+	Aggregate       []struct {
+		MetricName string   `json:"metricName"`
+		FloatValue *float64 `json:"floatValue"`
+		IntValue   *int64   `json:"intValue"`
+	} `json:"aggregate"`
+}
+
+func (v googleFitValue) float() (float64, bool) {
+	switch {
+	case v.FpVal != nil:
+		return *v.FpVal, true
+	case v.IntVal != nil:
+		return float64(*v.IntVal), true
+	}
+	return 0, false
+}
+
+func importGoogleFit(ctx context.Context, zr *zip.Reader, sink Sink, progress ProgressFunc) (Summary, error) {
+	summary := Summary{Source: SourceGoogleFit}
+
+	var files []*zip.File
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		base := path.Base(name)
+		if !strings.HasSuffix(base, ".json") {
+			continue
+		}
+		isData := strings.Contains(name, "fit/all data/") && strings.HasPrefix(base, "derived_") && strings.Contains(base, "merge")
+		isSession := strings.Contains(name, "fit/all sessions/")
+		if isData || isSession {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return summary, ErrUnknownExport
+	}
+
+	steps := stepTotals{}
+	for i, f := range files {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+		progress(float64(i)/float64(len(files))*95, fmt.Sprintf("Reading %s", path.Base(f.Name)))
+
+		var err error
+		if strings.Contains(strings.ToLower(f.Name), "fit/all sessions/") {
+			err = googleSessionFile(f, sink, &summary)
+		} else {
+			err = googleDataFile(ctx, f, sink, steps, &summary)
+		}
+		if err != nil {
+			return summary, fmt.Errorf("error importing %s: %w", f.Name, err)
+		}
+	}
+
+	progress(95, "Saving daily step totals")
+	if err := steps.flush(ctx, sink, SourceGoogleFit, &summary); err != nil {
+		return summary, err
+	}
+	progress(100, "Google Fit import finished")
+	return summary, nil
+}
+
+// googleDataFile streams the "Data Points" array so very large files are decoded one point at a time
+func googleDataFile(ctx context.Context, f *zip.File, sink Sink, steps stepTotals, summary *Summary) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	dec := json.NewDecoder(rc)
+	if err := seekArray(dec, "Data Points"); err != nil {
+		if err == io.EOF {
+			return nil // No data points in this file
+		}
+		return err
+	}
+
+	for dec.More() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var p googleDataPoint
+		if err := dec.Decode(&p); err != nil {
+			return err
+		}
+		if len(p.FitValue) == 0 {
+			summary.Skipped++
+			continue
+		}
+		v, ok := p.FitValue[0].Value.float()
+		if !ok {
+			summary.Skipped++
+			continue
+		}
+		at := time.Unix(0, p.StartTimeNanos).UTC()
+
+		switch p.DataTypeName {
+		case "com.google.weight":
+			if v <= 0 {
+				summary.Skipped++
+				continue
+			}
+			inserted, err := sink.Weight(at, v, SourceGoogleFit)
+			if err := summary.count(&summary.Weights, inserted, err); err != nil {
+				return err
+			}
+		case "com.google.step_count.delta":
+			steps[at.Format("2006-01-02")] += int(v)
+		case "com.google.heart_rate.bpm":
+			if v <= 0 || v > 250 {
+				summary.Skipped++
+				continue
+			}
+			inserted, err := sink.HeartRate(at, int(v+0.5), SourceGoogleFit)
+			if err := summary.count(&summary.HeartRateSamples, inserted, err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// seekArray advances the decoder to just inside the array stored under key in the top-level object
+func seekArray(dec *json.Decoder, key string) error {
+	if _, err := dec.Token(); err != nil { // Opening brace
+		return err
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if k, ok := tok.(string); ok && k == key {
+			open, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if d, ok := open.(json.Delim); !ok || d != '[' {
+				return fmt.Errorf("%q is not an array", key)
+			}
+			return nil
+		}
+		// Skip the value of any other key
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return err
+		}
+	}
+	return io.EOF
+}
+
+func googleSessionFile(f *zip.File, sink Sink, summary *Summary) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var s googleSession
+	if err := json.NewDecoder(rc).Decode(&s); err != nil {
+		summary.Skipped++
+		return nil
+	}
+	start, err1 := time.Parse(time.RFC3339Nano, s.StartTime)
+	end, err2 := time.Parse(time.RFC3339Nano, s.EndTime)
+	if err1 != nil || err2 != nil || !end.After(start) {
+		summary.Skipped++
+		return nil
+	}
+
+	if strings.HasPrefix(s.FitnessActivity, "sleep") {
+		stage := "asleep"
+		switch s.FitnessActivity {
+		case "sleep.light":
+			stage = "core"
+		case "sleep.deep":
+			stage = "deep"
+		case "sleep.rem":
+			stage = "rem"
+		case "sleep.awake":
+			stage = "awake"
+		}
+		inserted, err := sink.Sleep(start, end, stage, SourceGoogleFit)
+		return summary.count(&summary.SleepSessions, inserted, err)
+	}
+
+	activity := models.Activity{
+		Source:    SourceGoogleFit,
+		Sport:     activityfile.NormalizeSport(s.FitnessActivity),
+		StartTime: start.UTC().Truncate(time.Second),
+		Device:    "Google Fit",
+		DurationS: end.Sub(start).Seconds(),
+		Splits:    []models.ActivitySplit{},
+	}
+	if d, err := parseNumber(strings.TrimSuffix(s.Duration, "s")); err == nil && d > 0 {
+		activity.DurationS = d
+	}
+	for _, agg := range s.Aggregate {
+		if agg.MetricName == "com.google.distance.delta" && agg.FloatValue != nil {
+			activity.DistanceM = *agg.FloatValue
+		}
+	}
+	inserted, err := sink.Workout(activity)
+	return summary.count(&summary.Workouts, inserted, err)
+}
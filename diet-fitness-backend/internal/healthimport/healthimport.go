@@ -0,0 +1,144 @@
+// --- diet-fitness-backend/internal/healthimport/healthimport.go ---
+package healthimport
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// Supported export sources
+const (
+	SourceAppleHealth = "apple_health"
+	SourceGoogleFit   = "google_fit"
+)
+
+// ErrUnknownExport is returned when a zip is neither an Apple Health nor a Google Takeout Fit export
+var ErrUnknownExport = errors.New("zip is neither an Apple Health export nor a Google Takeout Fit export")
+
+// Sink receives the records found in an export. Implementations must be idempotent:
+// writing a record that was already imported reports inserted=false instead of duplicating it.
+type Sink interface {
+	Weight(at time.Time, kg float64, source string) (inserted bool, err error)
+	DailySteps(day string, steps int, source string) (inserted bool, err error) // day is YYYY-MM-DD; later writes replace earlier ones
+	HeartRate(at time.Time, bpm int, source string) (inserted bool, err error)
+	Sleep(start, end time.Time, stage, source string) (inserted bool, err error)
+	Workout(a models.Activity) (inserted bool, err error)
+}
+
+// ProgressFunc is called periodically with a completion percentage (0-100) and a short status message
+type ProgressFunc func(percent float64, message string)
+
+// Summary counts what an import wrote; records already present from an earlier import count as duplicates
+type Summary struct {
+	Source           string `json:"source"`
+	Weights          int    `json:"weights"`
+	StepDays         int    `json:"step_days"`
+	HeartRateSamples int    `json:"heart_rate_samples"`
+	SleepSessions    int    `json:"sleep_sessions"`
+	Workouts         int    `json:"workouts"`
+	Duplicates       int    `json:"duplicates"`
+	Skipped          int    `json:"skipped"` // Records we couldn't parse
+}
+
+// count tallies one Sink write into the summary
+func (s *Summary) count(field *int, inserted bool, err error) error {
+	if err != nil {
+		return err
+	}
+	if inserted {
+		*field++
+	} else {
+		s.Duplicates++
+	}
+	return nil
+}
+
+// Detect inspects the zip's file names to find out which service produced it
+func Detect(zr *zip.Reader) string {
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		switch {
+		case path.Base(name) == "export.xml":
+			return SourceAppleHealth
+		case strings.Contains(name, "fit/all data/") || strings.Contains(name, "fit/all sessions/"):
+			return SourceGoogleFit
+		}
+	}
+	return ""
+}
+
+// Import reads an uploaded export zip and writes everything it recognises to sink.
+// source may be empty to auto-detect. The import stops early when ctx is cancelled.
+func Import(ctx context.Context, zipPath, source string, sink Sink, progress ProgressFunc) (Summary, error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return Summary{}, fmt.Errorf("error opening zip: %w", err)
+	}
+	defer zr.Close()
+
+	if source == "" {
+		source = Detect(&zr.Reader)
+	}
+	if progress == nil {
+		progress = func(float64, string) {}
+	}
+
+	switch source {
+	case SourceAppleHealth:
+		return importAppleHealth(ctx, &zr.Reader, sink, progress)
+	case SourceGoogleFit:
+		return importGoogleFit(ctx, &zr.Reader, sink, progress)
+	}
+	return Summary{}, ErrUnknownExport
+}
+
+// countingReader tracks how many bytes have been read so progress can be reported on large streams
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// parseNumber is strconv.ParseFloat without the NaN and Inf it accepts, which would otherwise reach
+// the database as NULL or turn into garbage integers
+func parseNumber(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("number %q is not finite", s)
+	}
+	return v, nil
+}
+
+// stepTotals aggregates step counts per day before writing, since exports store many small intervals
+type stepTotals map[string]int
+
+func (st stepTotals) flush(ctx context.Context, sink Sink, source string, summary *Summary) error {
+	for day, steps := range st {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		inserted, err := sink.DailySteps(day, steps, source)
+		if err := summary.count(&summary.StepDays, inserted, err); err != nil {
+			return err
+		}
+	}
+	return nil
+}
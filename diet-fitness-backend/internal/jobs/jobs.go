@@ -0,0 +1,211 @@
+// --- diet-fitness-backend/internal/jobs/jobs.go ---
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// Job statuses
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrQueueFull is returned by Submit when too many jobs are already waiting
+var ErrQueueFull = errors.New("job queue is full; try again later")
+
+// progressInterval throttles how often progress updates are written to the database
+const progressInterval = time.Second
+
+// ProgressFunc lets a running job report a completion percentage (0-100) and a status message
+type ProgressFunc func(percent float64, message string)
+
+// Func is the work a job performs. The returned result is stored as JSON on success.
+type Func func(ctx context.Context, progress ProgressFunc) (interface{}, error)
+
+type task struct {
+	id int
+	fn Func
+}
+
+// Runner executes jobs on a fixed pool of background workers and records their state in the jobs table
+type Runner struct {
+	db     *sql.DB
+	queue  chan task
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRunner starts the worker pool. Jobs left queued or running by a previous process are marked failed,
+// since their in-memory work is gone.
+func NewRunner(db *sql.DB, workers int) (*Runner, error) {
+	if workers <= 0 {
+		workers = 1
+	}
+	_, err := db.Exec("UPDATE jobs SET status = ?, error = ?, finished_at = ? WHERE status IN (?, ?)",
+		StatusFailed, "interrupted by a server restart", time.Now().UTC(), StatusQueued, StatusRunning)
+	if err != nil {
+		return nil, fmt.Errorf("error recovering interrupted jobs: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{db: db, queue: make(chan task, 100), ctx: ctx, cancel: cancel}
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go r.worker()
+	}
+	return r, nil
+}
+
+// Submit records a new job for the user and queues it for execution
+func (r *Runner) Submit(userID int, kind string, fn Func) (int, error) {
+	var id int
+	err := r.db.QueryRow("INSERT INTO jobs (user_id, kind, status, progress, message) VALUES (?, ?, ?, 0, ?) RETURNING id",
+		userID, kind, StatusQueued, "Waiting to start").Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error creating job: %w", err)
+	}
+
+	select {
+	case r.queue <- task{id: id, fn: fn}:
+		return id, nil
+	default:
+		r.finish(id, nil, ErrQueueFull)
+		return 0, ErrQueueFull
+	}
+}
+
+// Shutdown stops accepting work, cancels running jobs and waits for the workers to exit
+func (r *Runner) Shutdown() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Runner) worker() {
+	defer r.wg.Done()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case t := <-r.queue:
+			r.run(t)
+		}
+	}
+}
+
+func (r *Runner) run(t task) {
+	now := time.Now().UTC()
+	if _, err := r.db.Exec("UPDATE jobs SET status = ?, started_at = ?, message = ? WHERE id = ?", StatusRunning, now, "Started", t.id); err != nil {
+		slog.Error("Error marking job as running", "job_id", t.id, "err", err)
+	}
+
+	var (
+		mu   sync.Mutex
+		last time.Time
+	)
+	progress := func(percent float64, message string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if time.Since(last) < progressInterval && percent < 100 {
+			return
+		}
+		last = time.Now()
+		if _, err := r.db.Exec("UPDATE jobs SET progress = ?, message = ? WHERE id = ?", min(max(percent, 0), 100), message, t.id); err != nil {
+			slog.Error("Error updating job progress", "job_id", t.id, "err", err)
+		}
+	}
+
+	result, err := func() (result interface{}, err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("job panicked: %v", p)
+			}
+		}()
+		return t.fn(r.ctx, progress)
+	}()
+	r.finish(t.id, result, err)
+}
+
+func (r *Runner) finish(id int, result interface{}, jobErr error) {
+	now := time.Now().UTC()
+	if jobErr != nil {
+		slog.Warn("Job failed", "job_id", id, "err", jobErr)
+		if _, err := r.db.Exec("UPDATE jobs SET status = ?, error = ?, message = ?, finished_at = ? WHERE id = ?",
+			StatusFailed, jobErr.Error(), "Failed", now, id); err != nil {
+			slog.Error("Error marking job as failed", "job_id", id, "err", err)
+		}
+		return
+	}
+
+	encoded := []byte("null")
+	if result != nil {
+		var err error
+		if encoded, err = json.Marshal(result); err != nil {
+			slog.Error("Error encoding job result", "job_id", id, "err", err)
+			encoded = []byte("null")
+		}
+	}
+	if _, err := r.db.Exec("UPDATE jobs SET status = ?, progress = 100, message = ?, result = ?, finished_at = ? WHERE id = ?",
+		StatusSucceeded, "Finished", string(encoded), now, id); err != nil {
+		slog.Error("Error marking job as succeeded", "job_id", id, "err", err)
+	}
+}
+
+const jobColumns = "id, user_id, kind, status, progress, message, result, error, created_at, started_at, finished_at"
+
+func scanJob(scan func(dest ...interface{}) error) (models.Job, error) {
+	var (
+		j                   models.Job
+		result              sql.NullString
+		started, finishedAt sql.NullTime
+	)
+	if err := scan(&j.ID, &j.UserID, &j.Kind, &j.Status, &j.Progress, &j.Message, &result, &j.Error, &j.CreatedAt, &started, &finishedAt); err != nil {
+		return j, err
+	}
+	if result.Valid && result.String != "" {
+		j.Result = json.RawMessage(result.String)
+	}
+	if started.Valid {
+		j.StartedAt = &started.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return j, nil
+}
+
+// Get returns one of the user's jobs; sql.ErrNoRows means it doesn't exist or belongs to someone else
+func Get(db *sql.DB, userID, id int) (models.Job, error) {
+	return scanJob(db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ? AND user_id = ?", id, userID).Scan)
+}
+
+// List returns the user's most recent jobs, newest first
+func List(db *sql.DB, userID, limit int) ([]models.Job, error) {
+	rows, err := db.Query("SELECT "+jobColumns+" FROM jobs WHERE user_id = ? ORDER BY id DESC LIMIT ?", userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.Job{}
+	for rows.Next() {
+		j, err := scanJob(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, j)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,69 @@
+// --- diet-fitness-backend/internal/logging/redact.go ---
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// secretKeys are attribute keys whose values are never written. Keys ending in _token, _password or
+// _secret are treated the same way.
+var secretKeys = map[string]bool{
+	"token":         true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+	"api_key":       true,
+	"code":          true,
+	"recovery_code": true,
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+// redact is the ReplaceAttr hook of the handlers. Secrets are dropped, prompts are reduced to their
+// length, emails keep only their first letter and domain, and email addresses inside messages and
+// errors are masked the same way.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key] || strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_password") || strings.HasSuffix(key, "_secret"):
+		a.Value = slog.StringValue("[redacted]")
+	case key == "prompt" || strings.HasSuffix(key, "_prompt"):
+		a.Value = slog.StringValue(fmt.Sprintf("[redacted, %d chars]", len(a.Value.Resolve().String())))
+	case key == "email" || strings.HasSuffix(key, "_email"):
+		a.Value = slog.StringValue(MaskEmail(a.Value.Resolve().String()))
+	default:
+		v := a.Value.Resolve()
+		switch {
+		case v.Kind() == slog.KindString:
+			a.Value = slog.StringValue(maskEmails(v.String()))
+		case v.Kind() == slog.KindAny:
+			if err, ok := v.Any().(error); ok {
+				a.Value = slog.StringValue(maskEmails(err.Error()))
+			}
+		}
+	}
+	return a
+}
+
+// MaskEmail keeps the first letter of the mailbox and the domain: alice@example.com becomes
+// a***@example.com. That is usually enough to tell accounts apart in a log without exposing them.
+func MaskEmail(email string) string {
+	local, domain, found := strings.Cut(email, "@")
+	if !found || local == "" {
+		if email == "" {
+			return ""
+		}
+		return "[redacted]"
+	}
+	return string([]rune(local)[:1]) + "***@" + domain
+}
+
+func maskEmails(s string) string {
+	if !strings.Contains(s, "@") {
+		return s
+	}
+	return emailPattern.ReplaceAllStringFunc(s, MaskEmail)
+}
@@ -0,0 +1,123 @@
+// --- diet-fitness-backend/internal/mail/mail.go ---
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/config"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers transactional email such as password reset links
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER: "smtp" or "log" (the default, for local development)
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		if cfg.SMTPHost == "" || cfg.MailFrom == "" {
+			return nil, fmt.Errorf("MAIL_DRIVER=smtp requires SMTP_HOST and MAIL_FROM")
+		}
+		return &SMTPMailer{Host: cfg.SMTPHost, Port: cfg.SMTPPort, Username: cfg.SMTPUsername, Password: cfg.SMTPPassword, From: cfg.MailFrom}, nil
+	case "", "log":
+		return &LogMailer{Dir: cfg.MailDir}, nil
+	}
+	return nil, fmt.Errorf("unknown MAIL_DRIVER %q; use \"smtp\" or \"log\"", cfg.MailDriver)
+}
+
+// SMTPMailer sends mail through an SMTP server. STARTTLS is used when the server offers it.
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string // Leave empty for servers that don't require authentication
+	Password string
+	From     string
+}
+
+// Send delivers the message
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	var a smtp.Auth
+	if m.Username != "" {
+		a = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	addr := net.JoinHostPort(m.Host, m.Port)
+
+	// smtp.SendMail has no timeout of its own
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(addr, a, m.From, []string{msg.To}, format(m.From, msg))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("error sending mail to %s: %w", msg.To, err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// LogMailer writes messages to the application log and, when Dir is set, to .eml files there
+type LogMailer struct {
+	Dir string
+}
+
+// Send logs the message instead of delivering it. The body holds sign-in links, so it is only logged
+// at debug level.
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	slog.InfoContext(ctx, "Mail logged instead of sent", "email", msg.To, "subject", msg.Subject)
+	slog.DebugContext(ctx, "Mail body", "email", msg.To, "body", msg.Body)
+	if m.Dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0700); err != nil {
+		return fmt.Errorf("error creating mail directory: %w", err)
+	}
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), sanitize(msg.To))
+	if err := os.WriteFile(filepath.Join(m.Dir, name), format("noreply@localhost", msg), 0600); err != nil {
+		return fmt.Errorf("error writing mail file: %w", err)
+	}
+	return nil
+}
+
+// headerSafe strips line breaks so a value can't inject extra headers
+var headerSafe = strings.NewReplacer("\r", "", "\n", "")
+
+// format builds an RFC 5322 message
+func format(from string, msg Message) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", headerSafe.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerSafe.Replace(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerSafe.Replace(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
@@ -11,7 +11,8 @@ func CORSMiddleware(next http.Handler) http.Handler {
 		// Allow requests from your frontend origin
 		w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000") // Replace with your frontend domain in production
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 
 		// Handle preflight requests (OPTIONS method)
@@ -2,14 +2,26 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // User represents a user in the database
 type User struct {
+	ID              int        `json:"id"`
+	Email           string     `json:"email"`
+	PasswordHash    string     `json:"-"` // Don't expose password hash in JSON responses; empty for provider-only accounts
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Upload records an image a user uploaded, stored under Filename in the upload directory
+type Upload struct {
 	ID           int       `json:"id"`
-	Email        string    `json:"email"`
-	PasswordHash string    `json:"-"` // Don't expose password hash in JSON responses
+	UserID       int       `json:"user_id"`
+	Filename     string    `json:"filename"`
+	OriginalName string    `json:"original_name"`
+	SizeBytes    int64     `json:"size_bytes"`
 	CreatedAt    time.Time `json:"created_at"`
 }
 
@@ -27,21 +39,85 @@ type UserLoginPayload struct {
 
 // LoginResponse represents the response after successful login
 type LoginResponse struct {
-	Token string `json:"token"`
+	Token         string   `json:"token,omitempty"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles,omitempty"`
+	MFARequired   bool     `json:"mfa_required,omitempty"` // Set instead of a token when a second factor is due
+	MFAToken      string   `json:"mfa_token,omitempty"`    // Short-lived challenge to send to /api/login/mfa
+}
+
+// MFALoginPayload completes a two-step login with a TOTP code or a recovery code
+type MFALoginPayload struct {
+	MFAToken     string `json:"mfa_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// MFACodePayload carries a TOTP code (or a recovery code where accepted), plus the password when required
+type MFACodePayload struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	Password     string `json:"password"`
+}
+
+// TOTPEnrollResponse holds what the user needs to add the account to an authenticator app
+type TOTPEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+	QRCodeURL  string `json:"qr_code_url"` // PNG of the otpauth URI
+}
+
+// RecoveryCodesResponse returns freshly generated recovery codes; they are only ever shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// MFAStatus describes the user's two-factor setup
+type MFAStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	PendingEnrollment      bool       `json:"pending_enrollment"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// ForgotPasswordPayload requests a password reset email
+type ForgotPasswordPayload struct {
 	Email string `json:"email"`
 }
 
+// ResetPasswordPayload sets a new password using the token from a reset email
+type ResetPasswordPayload struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+// ChangePasswordPayload replaces the password of the signed-in user. CurrentPassword may be left out
+// by accounts that only sign in through an identity provider and have no password yet.
+type ChangePasswordPayload struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// VerifyEmailPayload confirms an email address using the token from the verification email
+type VerifyEmailPayload struct {
+	Token string `json:"token"`
+}
+
 // ErrorResponse represents a generic error message for API responses
 type ErrorResponse struct {
-	Message string `json:"message"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"` // Quote it when reporting a problem; it is on every log line of the request
 }
 
 // DashboardData represents the placeholder data for the user dashboard
 type DashboardData struct {
-	Message    string `json:"message"`
-	UserName   string `json:"user_name"`
-	Progress   string `json:"progress"`
-	LastUpdate string `json:"last_update"`
+	Message        string          `json:"message"`
+	UserName       string          `json:"user_name"`
+	Progress       string          `json:"progress"`
+	LastUpdate     string          `json:"last_update"`
+	ACWR           *float64        `json:"acwr,omitempty"`  // Latest acute:chronic workload ratio, once enough history exists
+	TrainingAlerts []TrainingAlert `json:"training_alerts"` // Load spikes detected over the last week
 }
 
 // FitnessPlan represents a diet or workout plan
@@ -55,3 +131,485 @@ type FitnessPlan struct {
 type PlanGenerationRequest struct {
 	UserPrompt string `json:"user_prompt"`
 }
+
+// PlanExercise is one exercise of a structured workout plan together with its current prescription
+type PlanExercise struct {
+	ID            int       `json:"id"`
+	PlanID        int       `json:"plan_id"`
+	Position      int       `json:"position"`
+	Name          string    `json:"name"`
+	MuscleGroup   string    `json:"muscle_group"`
+	Scheme        string    `json:"scheme"` // "linear", "double_progression", "rpe" or "percent_wave"
+	Sets          int       `json:"sets"`
+	TargetReps    int       `json:"target_reps"`
+	RepsMin       int       `json:"reps_min,omitempty"`
+	RepsMax       int       `json:"reps_max,omitempty"`
+	LoadKg        float64   `json:"load_kg"`
+	TargetRPE     float64   `json:"target_rpe,omitempty"`
+	OneRepMaxKg   float64   `json:"one_rep_max_kg,omitempty"`
+	WaveWeek      int       `json:"wave_week,omitempty"`
+	IncrementKg   float64   `json:"increment_kg"`
+	FailureStreak int       `json:"failure_streak"`
+	DeloadAfter   int       `json:"deload_after"`
+	DeloadPercent float64   `json:"deload_percent"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// WorkoutPlan is a user's structured training plan
+type WorkoutPlan struct {
+	ID              int            `json:"id"`
+	UserID          int            `json:"user_id"`
+	Title           string         `json:"title"`
+	Active          bool           `json:"active"`
+	AssignedBy      *int           `json:"assigned_by,omitempty"`       // Coach who assigned the plan
+	SessionsPerWeek int            `json:"sessions_per_week,omitempty"` // Target frequency used for adherence
+	CreatedAt       time.Time      `json:"created_at"`
+	Exercises       []PlanExercise `json:"exercises"`
+}
+
+// UpdatePlanExercisesPayload replaces the exercises of the user's active plan
+type UpdatePlanExercisesPayload struct {
+	Title     string         `json:"title"`
+	Exercises []PlanExercise `json:"exercises"`
+}
+
+// WorkoutSet is a single logged set
+type WorkoutSet struct {
+	ID             int     `json:"id,omitempty"`
+	PlanExerciseID *int    `json:"plan_exercise_id,omitempty"` // Links the set to a plan exercise so progression can run
+	ExerciseName   string  `json:"exercise_name"`
+	MuscleGroup    string  `json:"muscle_group,omitempty"` // Taken from the plan exercise when not given
+	SetNumber      int     `json:"set_number"`
+	Reps           int     `json:"reps"`
+	LoadKg         float64 `json:"load_kg"`
+	RPE            float64 `json:"rpe,omitempty"`
+}
+
+// WorkoutSession is a logged training session
+type WorkoutSession struct {
+	ID          int          `json:"id"`
+	UserID      int          `json:"user_id"`
+	PlanID      *int         `json:"plan_id,omitempty"`
+	PerformedAt time.Time    `json:"performed_at"`
+	SessionRPE  float64      `json:"session_rpe,omitempty"`  // Whole-session perceived exertion (0-10)
+	DurationMin int          `json:"duration_min,omitempty"` // Session length in minutes
+	Notes       string       `json:"notes"`
+	Sets        []WorkoutSet `json:"sets"`
+}
+
+// LogWorkoutPayload is the request body for logging a session
+type LogWorkoutPayload struct {
+	PerformedAt *time.Time   `json:"performed_at"` // Defaults to now
+	SessionRPE  float64      `json:"session_rpe"`
+	DurationMin int          `json:"duration_min"`
+	Notes       string       `json:"notes"`
+	Sets        []WorkoutSet `json:"sets"`
+}
+
+// ProgressionChange describes how a plan exercise's prescription changed after a session
+type ProgressionChange struct {
+	PlanExerciseID int          `json:"plan_exercise_id"`
+	ExerciseName   string       `json:"exercise_name"`
+	Success        bool         `json:"success"`
+	Deload         bool         `json:"deload"`
+	Reason         string       `json:"reason"`
+	Previous       PlanExercise `json:"previous"`
+	Next           PlanExercise `json:"next"`
+}
+
+// LogWorkoutResponse is returned after a session is logged
+type LogWorkoutResponse struct {
+	Session     WorkoutSession      `json:"session"`
+	Progression []ProgressionChange `json:"progression"`
+}
+
+// WeeklyTrainingLoad aggregates one calendar week (Monday to Sunday, UTC) of the workout log
+type WeeklyTrainingLoad struct {
+	WeekStart   string         `json:"week_start"` // YYYY-MM-DD
+	Sessions    int            `json:"sessions"`
+	TonnageKg   float64        `json:"tonnage_kg"`   // Sum of reps × load
+	SessionLoad float64        `json:"session_load"` // Sum of session RPE × duration (arbitrary units)
+	HardSets    map[string]int `json:"hard_sets"`    // Hard sets per muscle group
+	Monotony    float64        `json:"monotony"`     // Mean daily load / standard deviation of daily load
+	Strain      float64        `json:"strain"`       // Weekly load × monotony
+}
+
+// DailyTrainingLoad is one point of the daily workload series
+type DailyTrainingLoad struct {
+	Date    string   `json:"date"` // YYYY-MM-DD
+	Load    float64  `json:"load"`
+	Acute   float64  `json:"acute"`          // Load over the last 7 days
+	Chronic float64  `json:"chronic"`        // Average weekly load over the last 28 days
+	ACWR    *float64 `json:"acwr,omitempty"` // Omitted until 28 days of history exist
+}
+
+// TrainingAlert flags a metric that crossed an overreaching threshold
+type TrainingAlert struct {
+	Date      string  `json:"date"`
+	Metric    string  `json:"metric"` // "acwr", "monotony", "strain" or "hard_sets:<muscle>"
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+	Severity  string  `json:"severity"` // "warning" or "high"
+	Message   string  `json:"message"`
+}
+
+// TrainingLoadReport is the response of the training load analytics endpoint
+type TrainingLoadReport struct {
+	From   string               `json:"from"`
+	To     string               `json:"to"`
+	Weekly []WeeklyTrainingLoad `json:"weekly"`
+	Daily  []DailyTrainingLoad  `json:"daily"`
+	Alerts []TrainingAlert      `json:"alerts"`
+}
+
+// ActivitySplit is one kilometre (or the final partial kilometre) of a cardio activity
+type ActivitySplit struct {
+	Index        int     `json:"index"` // 1-based
+	DistanceM    float64 `json:"distance_m"`
+	DurationS    float64 `json:"duration_s"`
+	PaceSecPerKm float64 `json:"pace_sec_per_km"`
+	ElevationM   float64 `json:"elevation_gain_m"`
+	AvgHeartRate int     `json:"avg_heart_rate,omitempty"`
+}
+
+// HeartRateSample is a heart-rate reading at an offset from the activity start
+type HeartRateSample struct {
+	OffsetS int `json:"offset_s"`
+	BPM     int `json:"bpm"`
+}
+
+// Activity is a cardio session (run, ride, ...) imported from a device file or entered manually
+type Activity struct {
+	ID             int               `json:"id"`
+	UserID         int               `json:"user_id"`
+	Source         string            `json:"source"` // "gpx", "tcx", "fit" or "manual"
+	Sport          string            `json:"sport"`  // "running", "cycling", "walking", "hiking", "swimming" or "other"
+	StartTime      time.Time         `json:"start_time"`
+	Device         string            `json:"device"`
+	DistanceM      float64           `json:"distance_m"`
+	DurationS      float64           `json:"duration_s"`
+	ElevationGainM float64           `json:"elevation_gain_m"`
+	AvgHeartRate   int               `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate   int               `json:"max_heart_rate,omitempty"`
+	Splits         []ActivitySplit   `json:"splits"`
+	HeartRate      []HeartRateSample `json:"heart_rate,omitempty"` // Only included when fetching a single activity
+	Analysis       *ActivityAnalysis `json:"analysis,omitempty"`   // Only included when fetching a single activity
+	CreatedAt      time.Time         `json:"created_at"`
+}
+
+// UserProfile holds the physiological data used for heart-rate zones and calorie estimates
+type UserProfile struct {
+	UserID       int       `json:"user_id"`
+	BirthDate    string    `json:"birth_date,omitempty"`     // YYYY-MM-DD
+	Sex          string    `json:"sex,omitempty"`            // "male", "female" or empty
+	WeightKg     float64   `json:"weight_kg,omitempty"`      // Body weight used for calorie estimates
+	HeightCm     float64   `json:"height_cm,omitempty"`      // Informational for now
+	RestingHR    int       `json:"resting_hr,omitempty"`     // Enables Karvonen (heart-rate reserve) zones
+	MaxHR        int       `json:"max_hr,omitempty"`         // User-tested maximum; overrides the age formula
+	MaxHRFormula string    `json:"max_hr_formula,omitempty"` // "tanaka" (default) or "fox"
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// HeartRateZone is one training zone expressed in beats per minute
+type HeartRateZone struct {
+	Zone   int    `json:"zone"` // 1-5
+	Name   string `json:"name"`
+	MinBPM int    `json:"min_bpm"`
+	MaxBPM int    `json:"max_bpm"`
+}
+
+// HeartRateZones is the user's zone model and how it was derived
+type HeartRateZones struct {
+	MaxHR       int             `json:"max_hr"`
+	MaxHRSource string          `json:"max_hr_source"` // "tested", "tanaka", "fox" or "default"
+	RestingHR   int             `json:"resting_hr,omitempty"`
+	Method      string          `json:"method"` // "karvonen" or "percent_max"
+	Zones       []HeartRateZone `json:"zones"`
+}
+
+// ZoneTime is the time an activity spent in one zone; zone 0 is below zone 1
+type ZoneTime struct {
+	Zone    int     `json:"zone"`
+	Seconds float64 `json:"seconds"`
+	Percent float64 `json:"percent"`
+}
+
+// ActivityAnalysis is the physiological interpretation of a cardio activity
+type ActivityAnalysis struct {
+	HeartRateZones
+	TimeInZones   []ZoneTime `json:"time_in_zones"`
+	TRIMP         *float64   `json:"trimp,omitempty"` // Banister training impulse; requires heart-rate data
+	Calories      float64    `json:"calories"`
+	CalorieMethod string     `json:"calorie_method"` // "heart_rate" or "met"
+	MET           float64    `json:"met,omitempty"`  // Set when the MET table was used
+	Assumptions   []string   `json:"assumptions"`    // Defaults substituted for missing profile values
+}
+
+// ManualActivityPayload is the request body for entering a cardio activity by hand
+type ManualActivityPayload struct {
+	Sport          string    `json:"sport"`
+	StartTime      time.Time `json:"start_time"`
+	DurationS      float64   `json:"duration_s"`
+	DistanceM      float64   `json:"distance_m"`
+	ElevationGainM float64   `json:"elevation_gain_m"`
+	AvgHeartRate   int       `json:"avg_heart_rate"`
+	MaxHeartRate   int       `json:"max_heart_rate"`
+}
+
+// Job is a long-running background task such as a data import
+type Job struct {
+	ID         int             `json:"id"`
+	UserID     int             `json:"user_id"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`   // "queued", "running", "succeeded" or "failed"
+	Progress   float64         `json:"progress"` // 0-100
+	Message    string          `json:"message"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+}
+
+// JobAcceptedResponse is returned when work has been queued as a background job
+type JobAcceptedResponse struct {
+	JobID     int    `json:"job_id"`
+	Status    string `json:"status"`
+	StatusURL string `json:"status_url"`
+	ResultURL string `json:"result_url,omitempty"` // Where the job's output can be fetched once it has succeeded
+}
+
+// DeleteAccountPayload confirms account deletion by re-entering the password. Accounts without one
+// (created through a sign-in provider) confirm with a two-factor code or a recent sign-in instead.
+type DeleteAccountPayload struct {
+	Password string `json:"password"`
+	Code     string `json:"code,omitempty"`
+}
+
+// Food is a catalog item; shared foods have no owner, custom foods belong to one user.
+// Nutrition values are per serving.
+type Food struct {
+	ID          int       `json:"id"`
+	UserID      *int      `json:"user_id,omitempty"` // Set for the user's custom foods
+	Name        string    `json:"name"`
+	Brand       string    `json:"brand,omitempty"`
+	ServingUnit string    `json:"serving_unit"` // e.g. "100 g", "1 medium", "serving"
+	Calories    float64   `json:"calories"`
+	ProteinG    float64   `json:"protein_g"`
+	CarbsG      float64   `json:"carbs_g"`
+	FatG        float64   `json:"fat_g"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DiaryEntry is one food logged in the meal diary; nutrition values are totals for the quantity eaten
+type DiaryEntry struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	Date      string    `json:"date"` // YYYY-MM-DD
+	Meal      string    `json:"meal"` // "breakfast", "lunch", "dinner", "snacks" or a custom meal name
+	FoodID    *int      `json:"food_id,omitempty"`
+	Name      string    `json:"name"`
+	Brand     string    `json:"brand,omitempty"`
+	Quantity  float64   `json:"quantity"` // Number of servings
+	Unit      string    `json:"unit"`
+	Calories  float64   `json:"calories"`
+	ProteinG  float64   `json:"protein_g"`
+	CarbsG    float64   `json:"carbs_g"`
+	FatG      float64   `json:"fat_g"`
+	FiberG    float64   `json:"fiber_g"`
+	SugarG    float64   `json:"sugar_g"`
+	SodiumMg  float64   `json:"sodium_mg"`
+	Source    string    `json:"source"` // "manual", "myfitnesspal" or "csv"
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DiaryEntryPayload is the request body for logging a food. With food_id the nutrition is
+// taken from the catalog; otherwise name and calories are required.
+type DiaryEntryPayload struct {
+	Date     string  `json:"date"`
+	Meal     string  `json:"meal"`
+	FoodID   *int    `json:"food_id"`
+	Name     string  `json:"name"`
+	Brand    string  `json:"brand"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	Calories float64 `json:"calories"`
+	ProteinG float64 `json:"protein_g"`
+	CarbsG   float64 `json:"carbs_g"`
+	FatG     float64 `json:"fat_g"`
+}
+
+// CSVRowError describes a CSV row that could not be imported
+type CSVRowError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// DiaryImportSummary reports what a diary CSV import did
+type DiaryImportSummary struct {
+	Format       string        `json:"format"` // "myfitnesspal" or "generic"
+	Imported     int           `json:"imported"`
+	Replaced     int           `json:"replaced"`      // Entries from an earlier import of the same days that were replaced
+	MatchedFoods int           `json:"matched_foods"` // Rows linked to an existing catalog or custom food
+	CustomFoods  int           `json:"custom_foods"`  // Custom foods created for rows that matched nothing
+	Errors       []CSVRowError `json:"errors"`
+}
+
+// Role is a role with the permissions it grants
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// AdminUser is an account as shown to administrators
+type AdminUser struct {
+	ID            int       `json:"id"`
+	Email         string    `json:"email"`
+	EmailVerified bool      `json:"email_verified"`
+	Roles         []string  `json:"roles"` // Always includes "user"
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CoachingRelationship links a coach with a client. Scopes lists what the client lets the coach
+// see and do: "workouts", "diary", "activities" and "plan".
+type CoachingRelationship struct {
+	ID          int        `json:"id"`
+	CoachID     int        `json:"coach_id"`
+	CoachEmail  string     `json:"coach_email"`
+	ClientID    *int       `json:"client_id,omitempty"` // Set once the invitation is accepted
+	ClientEmail string     `json:"client_email"`
+	Status      string     `json:"status"` // "pending", "active", "declined" or "ended"
+	Scopes      []string   `json:"scopes"`
+	Message     string     `json:"message,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AcceptedAt  *time.Time `json:"accepted_at,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+}
+
+// CoachInvitePayload invites someone by email to become a client
+type CoachInvitePayload struct {
+	Email   string   `json:"email"`
+	Scopes  []string `json:"scopes"` // Requested access; defaults to all scopes
+	Message string   `json:"message"`
+}
+
+// CoachingScopesPayload sets the scopes a client grants. On acceptance it may narrow the requested scopes.
+type CoachingScopesPayload struct {
+	Scopes []string `json:"scopes"`
+}
+
+// AssignPlanPayload is a plan a coach writes for a client; it replaces the client's active plan
+type AssignPlanPayload struct {
+	Title           string         `json:"title"`
+	SessionsPerWeek int            `json:"sessions_per_week"`
+	Exercises       []PlanExercise `json:"exercises"`
+}
+
+// RosterEntry summarizes one client for the coach. Metrics the client hasn't shared are omitted.
+type RosterEntry struct {
+	Relationship       CoachingRelationship `json:"relationship"`
+	PlanTitle          string               `json:"plan_title,omitempty"`
+	SessionsPerWeek    int                  `json:"sessions_per_week,omitempty"`
+	LastSessionAt      *time.Time           `json:"last_session_at,omitempty"`
+	SessionsLast7Days  *int                 `json:"sessions_last_7_days,omitempty"`
+	SessionsLast28Days *int                 `json:"sessions_last_28_days,omitempty"`
+	Adherence28Days    *float64             `json:"adherence_28_days,omitempty"` // Logged / planned sessions over 28 days
+	DiaryDaysLast7Days *int                 `json:"diary_days_last_7_days,omitempty"`
+}
+
+// SessionComment is a note on a logged session by the client or their coach
+type SessionComment struct {
+	ID          int       `json:"id"`
+	SessionID   int       `json:"session_id"`
+	AuthorID    int       `json:"author_id"`
+	AuthorEmail string    `json:"author_email"`
+	Body        string    `json:"body"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// CommentPayload is the request body for commenting on a session
+type CommentPayload struct {
+	Body string `json:"body"`
+}
+
+// LoginSession is a device the user is signed in on
+type LoginSession struct {
+	ID         int       `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"` // The session making the request
+}
+
+// Identity is a provider account the user can sign in with
+type Identity struct {
+	ID          int        `json:"id"`
+	Provider    string     `json:"provider"`
+	Email       string     `json:"email"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty"`
+}
+
+// APIKey is a personal API key as listed to its owner; the key itself is only shown at creation
+type APIKey struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // First characters of the key, to tell keys apart
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreateAPIKeyPayload is the request body for creating an API key. ExpiresInDays defaults to 90;
+// 0 creates a key that never expires.
+type CreateAPIKeyPayload struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays *int     `json:"expires_in_days"`
+}
+
+// CreatedAPIKey is returned once, when the key is created
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// BodyWeight is one body weight measurement
+type BodyWeight struct {
+	ID         int       `json:"id"`
+	MeasuredAt time.Time `json:"measured_at"`
+	WeightKg   float64   `json:"weight_kg"`
+	Source     string    `json:"source"`
+}
+
+// BodyWeightPayload is the request body for recording a weight; MeasuredAt defaults to now
+type BodyWeightPayload struct {
+	MeasuredAt *time.Time `json:"measured_at"`
+	WeightKg   float64    `json:"weight_kg"`
+}
+
+// AuditEvent is one entry of the audit log. ActorID is nil when nobody was signed in, such as a
+// failed login for an unknown email; TargetType and TargetID name what the action was applied to.
+type AuditEvent struct {
+	ID         int       `json:"id"`
+	ActorID    *int      `json:"actor_id"`
+	AuthMethod string    `json:"auth_method,omitempty"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type,omitempty"`
+	TargetID   string    `json:"target_id,omitempty"`
+	Detail     string    `json:"detail,omitempty"`
+	IP         string    `json:"ip"`
+	RequestID  string    `json:"request_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditLogPage is a page of audit events, newest first. NextBeforeID is passed as before_id to get
+// the next page and is omitted on the last one.
+type AuditLogPage struct {
+	Events       []AuditEvent `json:"events"`
+	NextBeforeID int          `json:"next_before_id,omitempty"`
+}
@@ -0,0 +1,333 @@
+// --- diet-fitness-backend/internal/oidc/oidc.go ---
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"diet-fitness-backend/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// discoveryTTL is how long the provider's metadata and keys are cached. Unknown key IDs trigger an
+// earlier refresh, so rotations at the provider are picked up right away.
+const discoveryTTL = time.Hour
+
+// minRefreshInterval stops tokens with made-up key IDs from hammering the provider
+const minRefreshInterval = time.Minute
+
+// ErrInvalidIDToken is returned when the provider's ID token fails verification
+var ErrInvalidIDToken = errors.New("ID token is invalid")
+
+// Provider is an OpenID Connect provider we accept logins from, using the authorization code flow with PKCE
+type Provider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	RedirectURL  string // Our callback, registered with the provider
+
+	client *http.Client
+
+	mu        sync.Mutex
+	metadata  *metadata
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+// IDToken holds the verified claims we use from the provider's ID token
+type IDToken struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Nonce         string
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type idTokenClaims struct {
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"` // Some providers send "true" as a string
+	Name          string      `json:"name"`
+	Nonce         string      `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+// NewProvider returns a provider; its discovery document is fetched on first use
+func NewProvider(name, issuer, clientID, clientSecret string, scopes []string, redirectURL string) *Provider {
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	return &Provider{
+		Name:         name,
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       scopes,
+		RedirectURL:  redirectURL,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// NewPKCE returns a random code verifier and its S256 challenge
+func NewPKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString()
+	if err != nil {
+		return "", "", err
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
+
+// RandomString returns 256 random bits, URL-safe encoded, for states, nonces and verifiers
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// AuthURL is where to send the browser to sign in with the provider
+func (p *Provider) AuthURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	md, err := p.discover(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(md.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange redeems an authorization code and verifies the ID token that comes back.
+// The caller must still compare the nonce with the one it sent.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*IDToken, error) {
+	md, err := p.discover(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {codeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, md.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("error creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling token endpoint: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error reading token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.Unmarshal(body, &tokens); err != nil || tokens.IDToken == "" {
+		return nil, fmt.Errorf("token response has no id_token")
+	}
+	return p.verify(ctx, tokens.IDToken)
+}
+
+// verify checks the ID token's signature against the provider's published keys, and its issuer,
+// audience and lifetime
+func (p *Provider) verify(ctx context.Context, raw string) (*IDToken, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "EdDSA"}),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrInvalidIDToken)
+	}
+
+	verified := false
+	switch v := claims.EmailVerified.(type) {
+	case bool:
+		verified = v
+	case string:
+		verified = v == "true"
+	}
+	return &IDToken{
+		Subject:       claims.Subject,
+		Email:         strings.ToLower(strings.TrimSpace(claims.Email)),
+		EmailVerified: verified,
+		Name:          claims.Name,
+		Nonce:         claims.Nonce,
+	}, nil
+}
+
+// key returns the provider's public key with the given ID, refetching the key set once if it's unknown
+func (p *Provider) key(ctx context.Context, kid string) (interface{}, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		if _, err := p.discover(ctx, attempt > 0); err != nil {
+			return nil, err
+		}
+		p.mu.Lock()
+		keys := p.keys
+		p.mu.Unlock()
+
+		if kid == "" && len(keys) == 1 {
+			for _, k := range keys {
+				return k, nil
+			}
+		}
+		if k, ok := keys[kid]; ok {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// discover returns the cached metadata, fetching it and the key set when stale or when refresh is set
+func (p *Provider) discover(ctx context.Context, refresh bool) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	age := time.Since(p.fetchedAt)
+	if p.metadata != nil && age < discoveryTTL && (!refresh || age < minRefreshInterval) {
+		return p.metadata, nil
+	}
+
+	var md metadata
+	if err := p.getJSON(ctx, p.Issuer+"/.well-known/openid-configuration", &md); err != nil {
+		return nil, fmt.Errorf("error loading %s discovery document: %w", p.Name, err)
+	}
+	if strings.TrimSuffix(md.Issuer, "/") != p.Issuer {
+		return nil, fmt.Errorf("%s discovery document names issuer %q, expected %q", p.Name, md.Issuer, p.Issuer)
+	}
+	if md.AuthorizationEndpoint == "" || md.TokenEndpoint == "" || md.JWKSURI == "" {
+		return nil, fmt.Errorf("%s discovery document is missing endpoints", p.Name)
+	}
+
+	var set struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			Curve   string `json:"crv"`
+			X       string `json:"x"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.getJSON(ctx, md.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("error loading %s signing keys: %w", p.Name, err)
+	}
+	keys := make(map[string]interface{})
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch {
+		case k.KeyType == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.KeyType == "OKP" && k.Curve == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(k.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			keys[k.KeyID] = ed25519.PublicKey(x)
+		}
+	}
+
+	p.metadata, p.keys, p.fetchedAt = &md, keys, time.Now()
+	return p.metadata, nil
+}
+
+func (p *Provider) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// Providers are the configured providers by name
+type Providers map[string]*Provider
+
+// FromConfig creates the providers listed in the configuration
+func FromConfig(cfg *config.Config) (Providers, error) {
+	providers := make(Providers)
+	for _, pc := range cfg.OIDCProviders {
+		if pc.Issuer == "" || pc.ClientID == "" {
+			return nil, fmt.Errorf("OIDC provider %q needs an issuer and a client ID", pc.Name)
+		}
+		if _, dup := providers[pc.Name]; dup {
+			return nil, fmt.Errorf("OIDC provider %q is listed twice", pc.Name)
+		}
+		redirectURL := strings.TrimSuffix(cfg.APIBaseURL, "/") + "/api/auth/oidc/" + url.PathEscape(pc.Name) + "/callback"
+		providers[pc.Name] = NewProvider(pc.Name, pc.Issuer, pc.ClientID, pc.ClientSecret, pc.Scopes, redirectURL)
+	}
+	return providers, nil
+}
@@ -0,0 +1,74 @@
+// --- diet-fitness-backend/internal/password/breached.go ---
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PrefixLength is how many hex characters of a password's SHA-1 name the corpus file holding it
+const PrefixLength = 5
+
+// Corpus is a local copy of breached password hashes laid out like the Have I Been Pwned range API:
+// one file per SHA-1 prefix (5BAA6.txt) holding "SUFFIX:COUNT" lines for every hash starting with it.
+// A check reads only the one small file for the password's prefix, so the corpus can hold hundreds of
+// millions of hashes without being loaded into memory. Build one with cmd/breachedlist.
+type Corpus struct {
+	dir string
+}
+
+// OpenCorpus uses the corpus in dir. It fails if the directory doesn't exist.
+func OpenCorpus(dir string) (*Corpus, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &Corpus{dir: dir}, nil
+}
+
+// Hash returns the upper-case hex SHA-1 of a password, split into the corpus file prefix and the suffix
+// stored in it
+func Hash(password string) (prefix, suffix string) {
+	sum := sha1.Sum([]byte(password))
+	h := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return h[:PrefixLength], h[PrefixLength:]
+}
+
+// PrefixFile is the corpus file holding hashes that start with prefix
+func PrefixFile(dir, prefix string) string {
+	return filepath.Join(dir, prefix+".txt")
+}
+
+// Count reports how often the password appears in the corpus; 0 means it isn't in it
+func (c *Corpus) Count(password string) (int, error) {
+	prefix, suffix := Hash(password)
+	f, err := os.Open(PrefixFile(c.dir, prefix))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		s, count, _ := strings.Cut(line, ":")
+		if !strings.EqualFold(s, suffix) {
+			continue
+		}
+		n := 1
+		fmt.Sscanf(count, "%d", &n)
+		return max(n, 1), nil
+	}
+	return 0, scanner.Err()
+}
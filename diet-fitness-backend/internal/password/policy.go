@@ -0,0 +1,81 @@
+// --- diet-fitness-backend/internal/password/policy.go ---
+package password
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"diet-fitness-backend/config"
+)
+
+// MaxBytes is the longest password bcrypt hashes in full; it silently ignores anything after it
+const MaxBytes = 72
+
+// Policy decides which new passwords are acceptable
+type Policy struct {
+	MinLength int     // Minimum length in characters
+	MinScore  int     // Minimum strength score from Estimate, 0 to 4
+	Breached  *Corpus // Breached password hashes to reject; nil skips the check
+}
+
+// NewPolicy creates the policy from the configuration. A missing breached password corpus only
+// disables that check, so a fresh install without the data still starts.
+func NewPolicy(cfg *config.Config) (*Policy, error) {
+	if cfg.PasswordMinScore < 0 || cfg.PasswordMinScore > 4 {
+		return nil, fmt.Errorf("PASSWORD_MIN_SCORE must be between 0 and 4, not %d", cfg.PasswordMinScore)
+	}
+	if cfg.PasswordMinLength < 1 || cfg.PasswordMinLength > MaxBytes {
+		return nil, fmt.Errorf("PASSWORD_MIN_LENGTH must be between 1 and %d, not %d", MaxBytes, cfg.PasswordMinLength)
+	}
+	p := &Policy{MinLength: cfg.PasswordMinLength, MinScore: cfg.PasswordMinScore}
+	if cfg.BreachedPasswordsDir != "" {
+		corpus, err := OpenCorpus(cfg.BreachedPasswordsDir)
+		if err != nil {
+			slog.Warn("Breached password check disabled", "err", err)
+		} else {
+			p.Breached = corpus
+		}
+	}
+	return p, nil
+}
+
+// Check returns why a new password is rejected, or "" if it is acceptable. userInputs are the
+// account's own details, such as its email address, which make a password easier to guess.
+func (p *Policy) Check(password string, userInputs ...string) string {
+	if n := utf8.RuneCountInString(password); n < p.MinLength {
+		return fmt.Sprintf("Password must be at least %d characters", p.MinLength)
+	}
+	if len(password) > MaxBytes {
+		return fmt.Sprintf("Password must be at most %d bytes", MaxBytes)
+	}
+
+	if p.Breached != nil {
+		count, err := p.Breached.Count(password)
+		if err != nil {
+			// Don't lock people out of their accounts because the corpus is unreadable
+			slog.Error("Error checking breached password corpus", "err", err)
+		} else if count > 0 {
+			return "This password has appeared in a data breach and can't be used; please choose another"
+		}
+	}
+
+	if s := Estimate(password, userInputs...); s.Score < p.MinScore {
+		msg := "Password is too easy to guess"
+		if s.Warning != "" {
+			msg += ": " + strings.ToLower(s.Warning[:1]) + s.Warning[1:]
+		}
+		return msg
+	}
+	return ""
+}
+
+// CommonPasswords lists the common passwords the strength estimator knows, most common first
+func CommonPasswords() []string {
+	list := make([]string, len(commonPasswords))
+	for pw, rank := range commonPasswords {
+		list[rank-1] = pw
+	}
+	return list
+}
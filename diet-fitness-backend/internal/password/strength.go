@@ -0,0 +1,491 @@
+// --- diet-fitness-backend/internal/password/strength.go ---
+package password
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// The estimator follows zxcvbn: it finds the guessable patterns in a password (common passwords and
+// words, the user's own details, keyboard runs, sequences, repeats and years), picks the combination
+// of patterns and brute-forced gaps an attacker would need the fewest guesses for, and maps that
+// number of guesses to a score from 0 to 4.
+
+//go:embed passwords.txt
+var passwordList string
+
+//go:embed words.txt
+var wordList string
+
+var (
+	commonPasswords = rankedList(passwordList)
+	commonWords     = rankedList(wordList)
+)
+
+// Match kinds, used to explain a weak score
+const (
+	kindPassword   = "password"
+	kindWord       = "word"
+	kindUserInput  = "user_input"
+	kindSequence   = "sequence"
+	kindRepeat     = "repeat"
+	kindKeyboard   = "keyboard"
+	kindYear       = "year"
+	kindBruteforce = "bruteforce"
+)
+
+const (
+	bruteforceCardinality = 10 // Guesses per brute-forced character, as in zxcvbn
+	minSubmatchGuesses    = 50 // Floor for patterns inside a longer password
+	minYearSpace          = 20
+	referenceYear         = 2026
+)
+
+// l33t substitutions undone before dictionary lookups
+var l33t = map[rune]rune{'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i', '|': 'i', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z'}
+
+// qwertyRows describe key positions for keyboard-run detection; adjacent keys share a row or sit
+// diagonally next to each other on the next row
+var qwertyRows = []string{"`1234567890-=", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// Strength is the estimator's verdict
+type Strength struct {
+	Score   int     // 0 (too guessable) to 4 (very unguessable)
+	Guesses float64 // Estimated guesses needed
+	Warning string  // What makes the password weak; empty for scores of 3 and up
+}
+
+type match struct {
+	i, j    int // Inclusive rune positions
+	kind    string
+	guesses float64
+}
+
+// Estimate rates a password. userInputs are the user's own details, such as their email address,
+// which count as very guessable.
+func Estimate(password string, userInputs ...string) Strength {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return Strength{Score: 0, Guesses: 1, Warning: "Enter a password"}
+	}
+	inputs := userInputRanks(userInputs)
+	guesses, seq := bestSequence(runes, findMatches(runes, inputs))
+
+	s := Strength{Guesses: guesses, Score: score(guesses)}
+	if s.Score < 3 {
+		s.Warning = warningFor(seq, len(runes))
+	}
+	return s
+}
+
+func score(guesses float64) int {
+	switch {
+	case guesses < 1e3+5:
+		return 0
+	case guesses < 1e6+5:
+		return 1
+	case guesses < 1e8+5:
+		return 2
+	case guesses < 1e10+5:
+		return 3
+	}
+	return 4
+}
+
+// warningFor names the pattern that contributes most to the password being guessable
+func warningFor(seq []match, length int) string {
+	var worst *match
+	for k := range seq {
+		m := &seq[k]
+		if m.kind == kindBruteforce {
+			continue
+		}
+		if worst == nil || m.j-m.i > worst.j-worst.i {
+			worst = m
+		}
+	}
+	if worst == nil {
+		return "Use a longer password; a few uncommon words together work well"
+	}
+	whole := worst.i == 0 && worst.j == length-1
+	switch worst.kind {
+	case kindPassword:
+		if whole {
+			return "This is a very common password"
+		}
+		return "This contains a very common password"
+	case kindWord:
+		return "A single common word is easy to guess; add more words"
+	case kindUserInput:
+		return "Avoid using your email address or name"
+	case kindSequence:
+		return "Sequences like abc or 6543 are easy to guess"
+	case kindRepeat:
+		return "Repeated characters or patterns like aaa or abcabc are easy to guess"
+	case kindKeyboard:
+		return "Straight rows or short patterns of keys are easy to guess"
+	case kindYear:
+		return "Recent years are easy to guess"
+	}
+	return "Add another word or two; uncommon words are better"
+}
+
+// bestSequence finds the cheapest way to cover the password with matches and brute-forced gaps.
+// Like zxcvbn, a sequence of l patterns costs l! times the product of their guesses, so splitting a
+// password into many small pieces isn't free.
+func bestSequence(runes []rune, matches []match) (float64, []match) {
+	n := len(runes)
+	byEnd := make([][]match, n)
+	for _, m := range matches {
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// best[k][l] is the cheapest product of guesses covering runes[0:k] with l matches
+	type cell struct {
+		product float64
+		prev    int
+		m       match
+		ok      bool
+	}
+	best := make([][]cell, n+1)
+	for k := range best {
+		best[k] = make([]cell, n+1)
+	}
+	best[0][0] = cell{product: 1, ok: true}
+	for j := 0; j < n; j++ {
+		candidates := byEnd[j]
+		for i := 0; i <= j; i++ {
+			candidates = append(candidates, match{i: i, j: j, kind: kindBruteforce, guesses: bruteforceGuesses(j - i + 1)})
+		}
+		for _, m := range candidates {
+			for l := 0; l < n; l++ {
+				from := best[m.i][l]
+				if !from.ok {
+					continue
+				}
+				// Consecutive brute-force pieces would just be one longer piece
+				if m.kind == kindBruteforce && from.m.kind == kindBruteforce && l > 0 {
+					continue
+				}
+				p := from.product * m.guesses
+				if to := &best[j+1][l+1]; !to.ok || p < to.product {
+					*to = cell{product: p, prev: l, m: m, ok: true}
+				}
+			}
+		}
+	}
+
+	bestGuesses, bestLen := math.Inf(1), 0
+	for l := 1; l <= n; l++ {
+		c := best[n][l]
+		if !c.ok {
+			continue
+		}
+		g := factorial(l) * c.product
+		if l > 1 {
+			g += math.Pow(minSubmatchGuesses*200, float64(l-1)) // zxcvbn's additive penalty per extra piece
+		}
+		if g < bestGuesses {
+			bestGuesses, bestLen = g, l
+		}
+	}
+
+	seq := make([]match, bestLen)
+	for k, l := n, bestLen; l > 0; l-- {
+		c := best[k][l]
+		seq[l-1] = c.m
+		k = c.m.i
+	}
+	return bestGuesses, seq
+}
+
+func findMatches(runes []rune, inputs map[string]int) []match {
+	var matches []match
+	matches = append(matches, dictionaryMatches(runes, inputs)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+	for k := range matches {
+		if matches[k].j-matches[k].i+1 < len(runes) && matches[k].guesses < minSubmatchGuesses {
+			matches[k].guesses = minSubmatchGuesses
+		}
+	}
+	return matches
+}
+
+// dictionaryMatches looks every substring of three or more characters up in the common lists and the
+// user's inputs, also reversed and with l33t substitutions undone
+func dictionaryMatches(runes []rune, inputs map[string]int) []match {
+	var matches []match
+	lower := []rune(strings.ToLower(string(runes)))
+	for i := 0; i < len(runes); i++ {
+		for j := i + 2; j < len(runes); j++ {
+			word := string(lower[i : j+1])
+			variations := uppercaseVariations(runes[i : j+1])
+			for _, candidate := range []struct {
+				text       string
+				multiplier float64
+			}{
+				{word, 1},
+				{reverse(word), 2},
+				{unl33t(word), l33tVariations(lower[i : j+1])},
+			} {
+				if candidate.multiplier == 0 || (candidate.multiplier > 1 && candidate.text == word) {
+					continue
+				}
+				kind, rank := lookup(candidate.text, inputs)
+				if rank == 0 {
+					continue
+				}
+				matches = append(matches, match{i: i, j: j, kind: kind, guesses: float64(rank) * variations * candidate.multiplier})
+			}
+		}
+	}
+	return matches
+}
+
+func lookup(word string, inputs map[string]int) (string, int) {
+	if rank := inputs[word]; rank > 0 {
+		return kindUserInput, rank
+	}
+	if rank := commonPasswords[word]; rank > 0 {
+		return kindPassword, rank
+	}
+	if rank := commonWords[word]; rank > 0 {
+		return kindWord, rank
+	}
+	return "", 0
+}
+
+// sequenceMatches finds runs like abcd, 9753 or zyx with a constant step between characters
+func sequenceMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i < len(runes)-2; {
+		delta := runes[i+1] - runes[i]
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == delta {
+			j++
+		}
+		if j-i >= 2 && delta != 0 && delta >= -5 && delta <= 5 {
+			first := runes[i]
+			var base float64
+			switch {
+			case strings.ContainsRune("aAzZ019", first):
+				base = 4 // Obvious starting points
+			case unicode.IsDigit(first):
+				base = 10
+			default:
+				base = 26
+			}
+			if delta < 0 {
+				base *= 2
+			}
+			matches = append(matches, match{i: i, j: j, kind: kindSequence, guesses: base * float64(j-i+1)})
+		}
+		if j > i+1 {
+			i = j
+		} else {
+			i++
+		}
+	}
+	return matches
+}
+
+// repeatMatches finds a character or block repeated back to back, like aaaa or abcabc
+func repeatMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i < len(runes); i++ {
+		for size := 1; i+2*size <= len(runes); size++ {
+			block := runes[i : i+size]
+			count := 1
+			for k := i + size; k+size <= len(runes) && string(runes[k:k+size]) == string(block); k += size {
+				count++
+			}
+			if count < 2 || (size == 1 && count < 3) {
+				continue
+			}
+			blockGuesses := Estimate(string(block)).Guesses
+			matches = append(matches, match{i: i, j: i + size*count - 1, kind: kindRepeat, guesses: blockGuesses * float64(count)})
+		}
+	}
+	return matches
+}
+
+// keyboardMatches finds runs of four or more adjacent keys such as qwerty or zaq1
+func keyboardMatches(runes []rune) []match {
+	var matches []match
+	lower := []rune(strings.ToLower(string(runes)))
+	for i := 0; i < len(lower)-3; i++ {
+		j, turns := i, 0
+		lastDir := [2]int{}
+		for j+1 < len(lower) {
+			dir, ok := keyStep(lower[j], lower[j+1])
+			if !ok {
+				break
+			}
+			if j == i || dir != lastDir {
+				turns++
+			}
+			lastDir = dir
+			j++
+		}
+		if j-i >= 3 {
+			length := float64(j - i + 1)
+			matches = append(matches, match{i: i, j: j, kind: kindKeyboard, guesses: 47 * length * math.Pow(4, float64(turns))})
+			i = j
+		}
+	}
+	return matches
+}
+
+func keyStep(a, b rune) ([2]int, bool) {
+	ra, ca, okA := keyPosition(a)
+	rb, cb, okB := keyPosition(b)
+	if !okA || !okB {
+		return [2]int{}, false
+	}
+	dr, dc := rb-ra, cb-ca
+	if dr < -1 || dr > 1 || dc < -1 || dc > 1 || (dr == 0 && dc == 0) {
+		return [2]int{}, false
+	}
+	return [2]int{dr, dc}, true
+}
+
+func keyPosition(r rune) (row, col int, ok bool) {
+	for row, keys := range qwertyRows {
+		if col := strings.IndexRune(keys, r); col >= 0 {
+			return row, col, true
+		}
+	}
+	return 0, 0, false
+}
+
+// yearMatches finds years from 1900 to 2049; the closer to now, the easier to guess
+func yearMatches(runes []rune) []match {
+	var matches []match
+	for i := 0; i+4 <= len(runes); i++ {
+		s := string(runes[i : i+4])
+		if len(s) != 4 || strings.TrimLeft(s, "0123456789") != "" {
+			continue
+		}
+		year := int(s[0]-'0')*1000 + int(s[1]-'0')*100 + int(s[2]-'0')*10 + int(s[3]-'0')
+		if year < 1900 || year > 2049 {
+			continue
+		}
+		space := math.Abs(float64(year - referenceYear))
+		if space < minYearSpace {
+			space = minYearSpace
+		}
+		matches = append(matches, match{i: i, j: i + 3, kind: kindYear, guesses: space})
+	}
+	return matches
+}
+
+// userInputRanks splits the user's details into lower-cased words, each as guessable as the top
+// common password
+func userInputRanks(inputs []string) map[string]int {
+	ranks := make(map[string]int)
+	for _, input := range inputs {
+		input = strings.ToLower(input)
+		parts := strings.FieldsFunc(input, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		for _, p := range append(parts, strings.Split(input, "@")[0]) {
+			if len([]rune(p)) >= 3 {
+				ranks[p] = 1
+			}
+		}
+	}
+	return ranks
+}
+
+func rankedList(list string) map[string]int {
+	ranks := make(map[string]int)
+	for _, line := range strings.Split(list, "\n") {
+		if w := strings.TrimSpace(line); w != "" {
+			if _, dup := ranks[w]; !dup {
+				ranks[w] = len(ranks) + 1
+			}
+		}
+	}
+	return ranks
+}
+
+// uppercaseVariations is how many capitalizations an attacker tries before this one
+func uppercaseVariations(word []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range word {
+		if unicode.IsUpper(r) {
+			upper++
+		} else if unicode.IsLower(r) {
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 || (upper == 1 && (unicode.IsUpper(word[0]) || unicode.IsUpper(word[len(word)-1]))) {
+		return 2 // All caps, or only the first or last letter capitalized
+	}
+	variations := 0.0
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return variations
+}
+
+// l33tVariations is 0 when the word has no l33t substitutions, else the number of ways to undo them
+func l33tVariations(word []rune) float64 {
+	subs := 0
+	for _, r := range word {
+		if _, ok := l33t[r]; ok {
+			subs++
+		}
+	}
+	if subs == 0 {
+		return 0
+	}
+	return math.Max(2, float64(subs)*2)
+}
+
+func unl33t(word string) string {
+	return strings.Map(func(r rune) rune {
+		if plain, ok := l33t[r]; ok {
+			return plain
+		}
+		return r
+	}, word)
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func bruteforceGuesses(length int) float64 {
+	g := math.Pow(bruteforceCardinality, float64(length))
+	if length == 1 {
+		return g + 1
+	}
+	return math.Max(g, minSubmatchGuesses+1)
+}
+
+func factorial(n int) float64 {
+	f := 1.0
+	for k := 2; k <= n; k++ {
+		f *= float64(k)
+	}
+	return f
+}
+
+func binomial(n, k int) float64 {
+	r := 1.0
+	for d := 1; d <= k; d++ {
+		r = r * float64(n-k+d) / float64(d)
+	}
+	return r
+}
@@ -0,0 +1,257 @@
+// --- diet-fitness-backend/internal/physiology/physiology.go ---
+package physiology
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// Defaults substituted when the profile is incomplete; every substitution is reported as an assumption
+const (
+	DefaultAge       = 35
+	DefaultWeightKg  = 70.0
+	DefaultRestingHR = 60
+	DefaultMaxHR     = 185 // Tanaka for a 35-year-old, rounded
+	maxSampleGapS    = 60  // Longer gaps between HR samples are treated as pauses
+)
+
+// zoneNames and zoneBounds define the classic five-zone model as fractions of max HR (or heart-rate reserve)
+var (
+	zoneNames  = []string{"Recovery", "Endurance", "Tempo", "Threshold", "VO2 max"}
+	zoneBounds = []float64{0.50, 0.60, 0.70, 0.80, 0.90, 1.00}
+)
+
+// Age returns the age in whole years on the given day, or false when the birth date is missing or invalid
+func Age(birthDate string, at time.Time) (int, bool) {
+	if birthDate == "" {
+		return 0, false
+	}
+	b, err := time.Parse("2006-01-02", birthDate)
+	if err != nil || b.After(at) {
+		return 0, false
+	}
+	age := at.Year() - b.Year()
+	if at.YearDay() < b.YearDay() {
+		age--
+	}
+	return age, true
+}
+
+// MaxHR returns the user-tested max HR when known, otherwise an age-predicted value
+func MaxHR(p models.UserProfile, at time.Time) (int, string) {
+	if p.MaxHR > 0 {
+		return p.MaxHR, "tested"
+	}
+	age, ok := Age(p.BirthDate, at)
+	if !ok {
+		return DefaultMaxHR, "default"
+	}
+	if p.MaxHRFormula == "fox" {
+		return 220 - age, "fox"
+	}
+	return int(math.Round(208 - 0.7*float64(age))), "tanaka"
+}
+
+// Zones computes the five heart-rate zones, using Karvonen when a resting heart rate is known
+func Zones(p models.UserProfile, at time.Time) models.HeartRateZones {
+	maxHR, source := MaxHR(p, at)
+	z := models.HeartRateZones{MaxHR: maxHR, MaxHRSource: source, Method: "percent_max"}
+
+	bpm := func(f float64) float64 { return f * float64(maxHR) }
+	if p.RestingHR > 0 && p.RestingHR < maxHR {
+		z.Method = "karvonen"
+		z.RestingHR = p.RestingHR
+		bpm = func(f float64) float64 { return float64(p.RestingHR) + f*float64(maxHR-p.RestingHR) }
+	}
+
+	for i, name := range zoneNames {
+		zone := models.HeartRateZone{
+			Zone:   i + 1,
+			Name:   name,
+			MinBPM: int(math.Round(bpm(zoneBounds[i]))),
+			MaxBPM: int(math.Round(bpm(zoneBounds[i+1]))) - 1,
+		}
+		if i == len(zoneNames)-1 {
+			zone.MaxBPM = maxHR
+		}
+		z.Zones = append(z.Zones, zone)
+	}
+	return z
+}
+
+// zoneOf returns the zone for a heart rate; 0 means below zone 1
+func zoneOf(zones []models.HeartRateZone, bpm int) int {
+	zone := 0
+	for _, z := range zones {
+		if bpm >= z.MinBPM {
+			zone = z.Zone
+		}
+	}
+	return zone
+}
+
+// hrSegment is a stretch of time spent at a given heart rate
+type hrSegment struct {
+	bpm     int
+	seconds float64
+}
+
+// segments turns the samples into timed segments, or one segment at the average HR when only that is known
+func segments(a models.Activity) []hrSegment {
+	if len(a.HeartRate) == 0 {
+		if a.AvgHeartRate > 0 && a.DurationS > 0 {
+			return []hrSegment{{bpm: a.AvgHeartRate, seconds: a.DurationS}}
+		}
+		return nil
+	}
+
+	samples := append([]models.HeartRateSample(nil), a.HeartRate...)
+	sort.Slice(samples, func(i, j int) bool { return samples[i].OffsetS < samples[j].OffsetS })
+	var segs []hrSegment
+	for i, s := range samples {
+		dt := 1.0 // The final sample stands for roughly one second
+		if i+1 < len(samples) {
+			dt = float64(samples[i+1].OffsetS - s.OffsetS)
+		}
+		if dt <= 0 {
+			continue
+		}
+		segs = append(segs, hrSegment{bpm: s.BPM, seconds: math.Min(dt, maxSampleGapS)})
+	}
+	return segs
+}
+
+// Analyze computes zones, time in zone, TRIMP and calories for an activity
+func Analyze(p models.UserProfile, a models.Activity) models.ActivityAnalysis {
+	at := a.StartTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	result := models.ActivityAnalysis{HeartRateZones: Zones(p, at), TimeInZones: []models.ZoneTime{}, Assumptions: []string{}}
+	if result.MaxHRSource == "default" {
+		result.Assumptions = append(result.Assumptions, fmt.Sprintf("max heart rate of %d bpm assumed; add your birth date or a tested max HR", DefaultMaxHR))
+	}
+
+	age, ageKnown := Age(p.BirthDate, at)
+	if !ageKnown {
+		age = DefaultAge
+	}
+	weight := p.WeightKg
+	weightKnown := weight > 0
+	if !weightKnown {
+		weight = DefaultWeightKg
+	}
+
+	segs := segments(a)
+	if len(segs) > 0 {
+		// Time in zone
+		perZone := make([]float64, len(result.Zones)+1)
+		total := 0.0
+		for _, s := range segs {
+			perZone[zoneOf(result.Zones, s.bpm)] += s.seconds
+			total += s.seconds
+		}
+		for zone, secs := range perZone {
+			zt := models.ZoneTime{Zone: zone, Seconds: math.Round(secs)}
+			if total > 0 {
+				zt.Percent = math.Round(secs/total*1000) / 10
+			}
+			result.TimeInZones = append(result.TimeInZones, zt)
+		}
+
+		// Banister TRIMP over the heart-rate reserve
+		rest := p.RestingHR
+		if rest <= 0 || rest >= result.MaxHR {
+			rest = DefaultRestingHR
+			result.Assumptions = append(result.Assumptions, fmt.Sprintf("resting heart rate of %d bpm assumed for TRIMP", DefaultRestingHR))
+		}
+		k := 1.92
+		switch p.Sex {
+		case "female":
+			k = 1.67
+		case "male":
+		default:
+			k = (1.92 + 1.67) / 2
+			result.Assumptions = append(result.Assumptions, "sex unknown; TRIMP weighting averaged")
+		}
+		trimp := 0.0
+		for _, s := range segs {
+			hrr := math.Max(0, math.Min(1, float64(s.bpm-rest)/float64(result.MaxHR-rest)))
+			trimp += s.seconds / 60 * hrr * 0.64 * math.Exp(k*hrr)
+		}
+		trimp = math.Round(trimp*10) / 10
+		result.TRIMP = &trimp
+
+		// Keytel et al. (2005) heart-rate based energy expenditure
+		kcal := 0.0
+		for _, s := range segs {
+			kcal += keytelPerMinute(p.Sex, float64(s.bpm), weight, float64(age)) * s.seconds / 60
+		}
+		result.Calories = math.Round(kcal)
+		result.CalorieMethod = "heart_rate"
+		if !ageKnown {
+			result.Assumptions = append(result.Assumptions, fmt.Sprintf("age of %d assumed for calorie estimate", DefaultAge))
+		}
+	} else {
+		speedKmh := 0.0
+		if a.DurationS > 0 {
+			speedKmh = a.DistanceM / a.DurationS * 3.6
+		}
+		result.MET = MET(a.Sport, speedKmh)
+		result.Calories = math.Round(result.MET * weight * a.DurationS / 3600)
+		result.CalorieMethod = "met"
+	}
+	if !weightKnown {
+		result.Assumptions = append(result.Assumptions, fmt.Sprintf("body weight of %.0f kg assumed for calorie estimate", DefaultWeightKg))
+	}
+	return result
+}
+
+// keytelPerMinute returns kcal/min from heart rate; sex-specific when known, otherwise the mean of both equations
+func keytelPerMinute(sex string, hr, weightKg, age float64) float64 {
+	male := (-55.0969 + 0.6309*hr + 0.1988*weightKg + 0.2017*age) / 4.184
+	female := (-20.4022 + 0.4472*hr - 0.1263*weightKg + 0.074*age) / 4.184
+	var v float64
+	switch sex {
+	case "male":
+		v = male
+	case "female":
+		v = female
+	default:
+		v = (male + female) / 2
+	}
+	return math.Max(0, v)
+}
+
+// metStep maps a minimum speed (km/h) to a MET value, from the Compendium of Physical Activities
+type metStep struct {
+	minSpeed float64
+	met      float64
+}
+
+var metTables = map[string][]metStep{
+	"running":  {{0, 6.0}, {8.0, 8.3}, {9.7, 9.8}, {11.3, 11.0}, {12.9, 11.8}, {14.5, 12.8}, {16.1, 14.5}},
+	"cycling":  {{0, 4.0}, {16.1, 6.8}, {19.3, 8.0}, {22.5, 10.0}, {25.7, 12.0}, {30.6, 15.8}},
+	"walking":  {{0, 2.8}, {4.0, 3.5}, {5.6, 4.3}, {6.4, 5.0}, {7.2, 7.0}},
+	"hiking":   {{0, 6.0}},
+	"swimming": {{0, 5.8}},
+}
+
+// MET returns the metabolic equivalent for a sport at a given average speed; unknown sports count as moderate effort
+func MET(sport string, speedKmh float64) float64 {
+	table, ok := metTables[sport]
+	if !ok {
+		return 5.0
+	}
+	met := table[0].met
+	for _, step := range table {
+		if speedKmh >= step.minSpeed {
+			met = step.met
+		}
+	}
+	return met
+}
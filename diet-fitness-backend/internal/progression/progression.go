@@ -0,0 +1,326 @@
+// --- diet-fitness-backend/internal/progression/progression.go ---
+package progression
+
+import (
+	"fmt"
+	"math"
+)
+
+// Scheme identifies how an exercise's prescription evolves from one session to the next
+type Scheme string
+
+const (
+	Linear            Scheme = "linear"             // Add load every successful session
+	DoubleProgression Scheme = "double_progression" // Climb the rep range, then add load and reset reps
+	RPE               Scheme = "rpe"                // Autoregulate load from the reported RPE of the top set
+	PercentWave       Scheme = "percent_wave"       // 3-week percentage-of-1RM wave followed by a deload week
+)
+
+// Defaults applied when an exercise doesn't configure its own values
+const (
+	DefaultIncrementKg   = 2.5
+	DefaultDeloadAfter   = 3    // Consecutive failed sessions before an automatic deload
+	DefaultDeloadPercent = 0.10 // Load reduction applied on deload
+	DefaultTargetRPE     = 8.0
+)
+
+// trainingMaxFactor is the share of the 1RM that PercentWave percentages are applied to
+const trainingMaxFactor = 0.9
+
+// wave is the PercentWave cycle; the last week is a planned deload that always counts as a success
+var wave = []struct {
+	Percent float64
+	Reps    int
+}{
+	{0.75, 5},
+	{0.85, 3},
+	{0.95, 1},
+	{0.60, 5},
+}
+
+// IsValid reports whether s is one of the supported schemes
+func (s Scheme) IsValid() bool {
+	switch s {
+	case Linear, DoubleProgression, RPE, PercentWave:
+		return true
+	}
+	return false
+}
+
+// Prescription is what the lifter is asked to do for an exercise in the next session
+type Prescription struct {
+	Sets       int     `json:"sets"`
+	TargetReps int     `json:"target_reps"`
+	RepsMin    int     `json:"reps_min,omitempty"`
+	RepsMax    int     `json:"reps_max,omitempty"`
+	LoadKg     float64 `json:"load_kg"`
+	TargetRPE  float64 `json:"target_rpe,omitempty"`
+	WaveWeek   int     `json:"wave_week,omitempty"`
+}
+
+// State is the full progression state persisted for a plan exercise
+type State struct {
+	Scheme Scheme
+	Prescription
+	IncrementKg   float64
+	OneRepMaxKg   float64 // Only used by PercentWave
+	FailureStreak int
+	DeloadAfter   int
+	DeloadPercent float64
+}
+
+// SetResult is a single set as performed and logged by the user
+type SetResult struct {
+	Reps   int
+	LoadKg float64
+	RPE    float64 // 0 when not reported
+}
+
+// Outcome is the result of evaluating one logged session against the current state
+type Outcome struct {
+	Next    State
+	Success bool
+	Deload  bool
+	Reason  string
+}
+
+// Next evaluates the sets performed for an exercise and computes the prescription for the following session
+func Next(current State, sets []SetResult) Outcome {
+	s := withDefaults(current)
+	if len(sets) == 0 {
+		return Outcome{Next: s, Reason: "no sets logged; prescription unchanged"}
+	}
+
+	var out Outcome
+	switch s.Scheme {
+	case DoubleProgression:
+		out = nextDoubleProgression(s, sets)
+	case RPE:
+		out = nextRPE(s, sets)
+	case PercentWave:
+		out = nextPercentWave(s, sets)
+	default:
+		out = nextLinear(s, sets)
+	}
+
+	if out.Success {
+		out.Next.FailureStreak = 0
+		return out
+	}
+
+	out.Next.FailureStreak++
+	if out.Next.FailureStreak >= out.Next.DeloadAfter {
+		out = deload(out.Next)
+	}
+	return out
+}
+
+// Initial fills in a usable starting prescription for a freshly configured exercise
+func Initial(s State) State {
+	s = withDefaults(s)
+	if s.Scheme == PercentWave && s.OneRepMaxKg > 0 {
+		s.Prescription = waveWeek(s, s.WaveWeek)
+	}
+	return s
+}
+
+func withDefaults(s State) State {
+	if !s.Scheme.IsValid() {
+		s.Scheme = Linear
+	}
+	if s.IncrementKg <= 0 {
+		s.IncrementKg = DefaultIncrementKg
+	}
+	if s.DeloadAfter <= 0 {
+		s.DeloadAfter = DefaultDeloadAfter
+	}
+	if s.DeloadPercent <= 0 || s.DeloadPercent >= 1 {
+		s.DeloadPercent = DefaultDeloadPercent
+	}
+	if s.Sets <= 0 {
+		s.Sets = 3
+	}
+	switch s.Scheme {
+	case DoubleProgression:
+		if s.RepsMin <= 0 {
+			s.RepsMin = 8
+		}
+		if s.RepsMax < s.RepsMin {
+			s.RepsMax = s.RepsMin + 4
+		}
+		if s.TargetReps < s.RepsMin || s.TargetReps > s.RepsMax {
+			s.TargetReps = s.RepsMin
+		}
+	case RPE:
+		if s.TargetRPE <= 0 || s.TargetRPE > 10 {
+			s.TargetRPE = DefaultTargetRPE
+		}
+		if s.TargetReps <= 0 {
+			s.TargetReps = 5
+		}
+	case PercentWave:
+		if s.WaveWeek < 1 || s.WaveWeek > len(wave) {
+			s.WaveWeek = 1
+		}
+	default:
+		if s.TargetReps <= 0 {
+			s.TargetReps = 5
+		}
+	}
+	return s
+}
+
+// completedSets counts the sets done at (or above) the prescribed load with at least minReps reps
+func completedSets(load float64, minReps int, sets []SetResult) int {
+	n := 0
+	for _, set := range sets {
+		if set.LoadKg+1e-9 >= load && set.Reps >= minReps {
+			n++
+		}
+	}
+	return n
+}
+
+func nextLinear(s State, sets []SetResult) Outcome {
+	if completedSets(s.LoadKg, s.TargetReps, sets) < s.Sets {
+		return Outcome{Next: s, Reason: fmt.Sprintf("missed %d×%d at %.1f kg; repeating load", s.Sets, s.TargetReps, s.LoadKg)}
+	}
+	s.LoadKg = roundTo(s.LoadKg+s.IncrementKg, s.IncrementKg)
+	return Outcome{Next: s, Success: true, Reason: fmt.Sprintf("all sets completed; load increased to %.1f kg", s.LoadKg)}
+}
+
+func nextDoubleProgression(s State, sets []SetResult) Outcome {
+	if completedSets(s.LoadKg, s.RepsMin, sets) < s.Sets {
+		return Outcome{Next: s, Reason: fmt.Sprintf("fell below %d reps at %.1f kg", s.RepsMin, s.LoadKg)}
+	}
+
+	if completedSets(s.LoadKg, s.RepsMax, sets) >= s.Sets {
+		s.LoadKg = roundTo(s.LoadKg+s.IncrementKg, s.IncrementKg)
+		s.TargetReps = s.RepsMin
+		return Outcome{Next: s, Success: true, Reason: fmt.Sprintf("top of rep range reached; load increased to %.1f kg at %d reps", s.LoadKg, s.RepsMin)}
+	}
+
+	// Still inside the range: aim for one more rep than the weakest working set
+	weakest := s.RepsMax
+	counted := 0
+	for _, set := range sets {
+		if set.LoadKg+1e-9 >= s.LoadKg && counted < s.Sets {
+			counted++
+			if set.Reps < weakest {
+				weakest = set.Reps
+			}
+		}
+	}
+	s.TargetReps = min(weakest+1, s.RepsMax)
+	return Outcome{Next: s, Success: true, Reason: fmt.Sprintf("within rep range; target %d reps at %.1f kg", s.TargetReps, s.LoadKg)}
+}
+
+func nextRPE(s State, sets []SetResult) Outcome {
+	// The top set (highest estimated 1RM with an RPE reported) drives the next load
+	var best SetResult
+	bestE1RM := 0.0
+	for _, set := range sets {
+		if set.RPE <= 0 || set.Reps <= 0 {
+			continue
+		}
+		if e := EstimateOneRepMax(set.LoadKg, set.Reps, set.RPE); e > bestE1RM {
+			bestE1RM, best = e, set
+		}
+	}
+	if bestE1RM == 0 {
+		// Without RPE data fall back to a plain linear evaluation
+		return nextLinear(s, sets)
+	}
+
+	s.LoadKg = roundTo(LoadForRPE(bestE1RM, s.TargetReps, s.TargetRPE), s.IncrementKg)
+	if best.RPE > s.TargetRPE+1 || best.Reps < s.TargetReps {
+		return Outcome{Next: s, Reason: fmt.Sprintf("top set overshot (RPE %.1f for %d reps); load adjusted to %.1f kg", best.RPE, best.Reps, s.LoadKg)}
+	}
+	return Outcome{Next: s, Success: true, Reason: fmt.Sprintf("estimated 1RM %.1f kg; load set to %.1f kg for %d reps @ RPE %.1f", bestE1RM, s.LoadKg, s.TargetReps, s.TargetRPE)}
+}
+
+func nextPercentWave(s State, sets []SetResult) Outcome {
+	if s.OneRepMaxKg <= 0 {
+		// Seed the 1RM from the best set so the wave has something to work from
+		for _, set := range sets {
+			if e := EstimateOneRepMax(set.LoadKg, set.Reps, 10); e > s.OneRepMaxKg {
+				s.OneRepMaxKg = roundTo(e, s.IncrementKg)
+			}
+		}
+		s.Prescription = waveWeek(s, 1)
+		return Outcome{Next: s, Success: true, Reason: fmt.Sprintf("1RM estimated at %.1f kg; starting wave", s.OneRepMaxKg)}
+	}
+
+	current := waveWeek(s, s.WaveWeek)
+	deloadWeek := s.WaveWeek == len(wave)
+	if !deloadWeek && completedSets(current.LoadKg, current.TargetReps, sets) < s.Sets {
+		s.Prescription = current
+		return Outcome{Next: s, Reason: fmt.Sprintf("missed wave week %d; repeating it", s.WaveWeek)}
+	}
+
+	week := s.WaveWeek + 1
+	reason := fmt.Sprintf("wave week %d completed", s.WaveWeek)
+	if week > len(wave) {
+		week = 1
+		s.OneRepMaxKg = roundTo(s.OneRepMaxKg+s.IncrementKg, s.IncrementKg)
+		reason = fmt.Sprintf("wave completed; 1RM raised to %.1f kg", s.OneRepMaxKg)
+	}
+	s.Prescription = waveWeek(s, week)
+	return Outcome{Next: s, Success: true, Reason: reason}
+}
+
+// waveWeek builds the prescription for the given week of the PercentWave cycle
+func waveWeek(s State, week int) Prescription {
+	if week < 1 || week > len(wave) {
+		week = 1
+	}
+	w := wave[week-1]
+	p := s.Prescription
+	p.WaveWeek = week
+	p.TargetReps = w.Reps
+	p.LoadKg = roundTo(s.OneRepMaxKg*trainingMaxFactor*w.Percent, s.IncrementKg)
+	return p
+}
+
+func deload(s State) Outcome {
+	if s.Scheme == PercentWave {
+		s.OneRepMaxKg = roundTo(s.OneRepMaxKg*(1-s.DeloadPercent), s.IncrementKg)
+		s.Prescription = waveWeek(s, 1)
+	} else {
+		s.LoadKg = roundTo(s.LoadKg*(1-s.DeloadPercent), s.IncrementKg)
+	}
+	if s.Scheme == DoubleProgression {
+		s.TargetReps = s.RepsMin
+	}
+	streak := s.FailureStreak
+	s.FailureStreak = 0
+	return Outcome{
+		Next:   s,
+		Deload: true,
+		Reason: fmt.Sprintf("%d consecutive failed sessions; deloading by %.0f%% to %.1f kg", streak, s.DeloadPercent*100, s.LoadKg),
+	}
+}
+
+// EstimateOneRepMax uses the Epley formula, counting reps left in reserve (10 - RPE) as performable reps
+func EstimateOneRepMax(loadKg float64, reps int, rpe float64) float64 {
+	if loadKg <= 0 || reps <= 0 {
+		return 0
+	}
+	rir := 0.0
+	if rpe > 0 && rpe <= 10 {
+		rir = 10 - rpe
+	}
+	return loadKg * (1 + (float64(reps)+rir)/30)
+}
+
+// LoadForRPE inverts EstimateOneRepMax to find the load for a rep target at a given RPE
+func LoadForRPE(oneRepMaxKg float64, reps int, rpe float64) float64 {
+	return oneRepMaxKg / (1 + (float64(reps)+(10-rpe))/30)
+}
+
+func roundTo(v, step float64) float64 {
+	if step <= 0 {
+		return v
+	}
+	return math.Round(v/step) * step
+}
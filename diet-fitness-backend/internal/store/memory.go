@@ -0,0 +1,434 @@
+// --- diet-fitness-backend/internal/store/memory.go ---
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// Memory is a Store that keeps everything in process memory, for unit tests and local experiments.
+// It behaves like the SQLite store, including ID assignment and ordering, and is safe for
+// concurrent use. Records are copied in and out, so callers can't change stored data by accident.
+type Memory struct {
+	mu        sync.Mutex
+	nextID    map[string]int
+	users     map[int]models.User
+	plans     map[int]models.WorkoutPlan // Exercises are held separately in exercises
+	exercises map[int]models.PlanExercise
+	sessions  map[int]models.WorkoutSession
+	uploads   map[int]models.Upload
+	audit     []models.AuditEvent // Oldest first
+}
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{
+		nextID:    make(map[string]int),
+		users:     make(map[int]models.User),
+		plans:     make(map[int]models.WorkoutPlan),
+		exercises: make(map[int]models.PlanExercise),
+		sessions:  make(map[int]models.WorkoutSession),
+		uploads:   make(map[int]models.Upload),
+	}
+}
+
+func (m *Memory) Users() Users       { return memoryUsers{m} }
+func (m *Memory) Plans() Plans       { return memoryPlans{m} }
+func (m *Memory) Workouts() Workouts { return memoryWorkouts{m} }
+func (m *Memory) Uploads() Uploads   { return memoryUploads{m} }
+func (m *Memory) Audit() Audit       { return memoryAudit{m} }
+
+// id hands out increasing IDs per table, like AUTOINCREMENT. Callers hold m.mu.
+func (m *Memory) id(table string) int {
+	m.nextID[table]++
+	return m.nextID[table]
+}
+
+type memoryUsers struct{ m *Memory }
+
+func (u memoryUsers) Create(ctx context.Context, email, passwordHash string) (int, error) {
+	return u.create(models.User{Email: email, PasswordHash: passwordHash})
+}
+
+func (u memoryUsers) CreateVerified(ctx context.Context, tx *sql.Tx, email string, verifiedAt time.Time) (int, error) {
+	verifiedAt = verifiedAt.UTC()
+	return u.create(models.User{Email: email, EmailVerifiedAt: &verifiedAt})
+}
+
+func (u memoryUsers) create(user models.User) (int, error) {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	user.Email = strings.ToLower(user.Email)
+	for _, existing := range u.m.users {
+		if strings.ToLower(existing.Email) == user.Email {
+			return 0, ErrEmailTaken
+		}
+	}
+	user.ID = u.m.id("users")
+	user.CreatedAt = time.Now().UTC()
+	u.m.users[user.ID] = user
+	return user.ID, nil
+}
+
+func (u memoryUsers) SetPassword(ctx context.Context, tx *sql.Tx, userID int, passwordHash string) error {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	user, found := u.m.users[userID]
+	if !found {
+		return ErrNotFound
+	}
+	user.PasswordHash = passwordHash
+	u.m.users[userID] = user
+	return nil
+}
+
+func (u memoryUsers) MarkVerified(ctx context.Context, tx *sql.Tx, userID int, at time.Time) error {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	if user, found := u.m.users[userID]; found && user.EmailVerifiedAt == nil {
+		at = at.UTC()
+		user.EmailVerifiedAt = &at
+		u.m.users[userID] = user
+	}
+	return nil
+}
+
+func (u memoryUsers) ByID(ctx context.Context, id int) (models.User, error) {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	user, found := u.m.users[id]
+	if !found {
+		return models.User{}, ErrNotFound
+	}
+	return copyUser(user), nil
+}
+
+func (u memoryUsers) ByEmail(ctx context.Context, email string) (models.User, error) {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	email = strings.ToLower(email)
+	for _, user := range u.m.users {
+		if strings.ToLower(user.Email) == email {
+			return copyUser(user), nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (u memoryUsers) Search(ctx context.Context, emailContains string, limit int) ([]models.User, error) {
+	u.m.mu.Lock()
+	defer u.m.mu.Unlock()
+	emailContains = strings.ToLower(emailContains)
+	users := []models.User{}
+	for _, user := range u.m.users {
+		if strings.Contains(strings.ToLower(user.Email), emailContains) {
+			users = append(users, copyUser(user))
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func copyUser(u models.User) models.User {
+	if u.EmailVerifiedAt != nil {
+		t := *u.EmailVerifiedAt
+		u.EmailVerifiedAt = &t
+	}
+	return u
+}
+
+type memoryPlans struct{ m *Memory }
+
+func (p memoryPlans) Active(ctx context.Context, userID int) (*models.WorkoutPlan, error) {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+	return p.m.activePlan(userID), nil
+}
+
+func (p memoryPlans) CreateActive(ctx context.Context, plan models.WorkoutPlan) (*models.WorkoutPlan, error) {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+	for id, existing := range p.m.plans {
+		if existing.UserID == plan.UserID && existing.Active {
+			existing.Active = false
+			p.m.plans[id] = existing
+		}
+	}
+	exercises := plan.Exercises
+	plan.ID = p.m.id("workout_plans")
+	plan.Active = true
+	plan.CreatedAt = time.Now().UTC()
+	plan.Exercises = nil
+	if plan.AssignedBy != nil {
+		by := *plan.AssignedBy
+		plan.AssignedBy = &by
+	}
+	p.m.plans[plan.ID] = plan
+	p.m.insertExercises(plan.ID, exercises)
+	return p.m.activePlan(plan.UserID), nil
+}
+
+func (p memoryPlans) ReplaceExercises(ctx context.Context, planID int, title string, exercises []models.PlanExercise) error {
+	p.m.mu.Lock()
+	defer p.m.mu.Unlock()
+	plan, found := p.m.plans[planID]
+	if !found {
+		return ErrNotFound
+	}
+	if title != "" {
+		plan.Title = title
+		p.m.plans[planID] = plan
+	}
+	for id, e := range p.m.exercises {
+		if e.PlanID != planID {
+			continue
+		}
+		delete(p.m.exercises, id)
+		for sid, s := range p.m.sessions {
+			for i, set := range s.Sets {
+				if set.PlanExerciseID != nil && *set.PlanExerciseID == id {
+					s.Sets[i].PlanExerciseID = nil
+				}
+			}
+			p.m.sessions[sid] = s
+		}
+	}
+	p.m.insertExercises(planID, exercises)
+	return nil
+}
+
+// activePlan returns a copy of the user's active plan. Callers hold m.mu.
+func (m *Memory) activePlan(userID int) *models.WorkoutPlan {
+	var active *models.WorkoutPlan
+	for _, plan := range m.plans {
+		if plan.UserID == userID && plan.Active && (active == nil || plan.ID > active.ID) {
+			p := plan
+			active = &p
+		}
+	}
+	if active == nil {
+		return nil
+	}
+	active.Exercises = []models.PlanExercise{}
+	for _, e := range m.exercises {
+		if e.PlanID == active.ID {
+			active.Exercises = append(active.Exercises, e)
+		}
+	}
+	sort.Slice(active.Exercises, func(i, j int) bool {
+		a, b := active.Exercises[i], active.Exercises[j]
+		return a.Position < b.Position || (a.Position == b.Position && a.ID < b.ID)
+	})
+	return active
+}
+
+// insertExercises stores exercises in list order. Callers hold m.mu.
+func (m *Memory) insertExercises(planID int, exercises []models.PlanExercise) {
+	now := time.Now().UTC()
+	for i, e := range exercises {
+		e.ID = m.id("plan_exercises")
+		e.PlanID = planID
+		e.Position = i
+		e.UpdatedAt = now
+		m.exercises[e.ID] = e
+	}
+}
+
+type memoryWorkouts struct{ m *Memory }
+
+func (wk memoryWorkouts) Record(ctx context.Context, session *models.WorkoutSession, progressed []models.PlanExercise) error {
+	wk.m.mu.Lock()
+	defer wk.m.mu.Unlock()
+	wk.m.recordSession(session, progressed)
+	return nil
+}
+
+func (wk memoryWorkouts) Progress(ctx context.Context, userID int, build func(plan *models.WorkoutPlan) (*models.WorkoutSession, []models.PlanExercise, error)) (*models.WorkoutSession, error) {
+	wk.m.mu.Lock()
+	defer wk.m.mu.Unlock()
+	session, progressed, err := build(wk.m.activePlan(userID))
+	if err != nil {
+		return nil, err
+	}
+	wk.m.recordSession(session, progressed)
+	return session, nil
+}
+
+// recordSession stores a session and the progressed prescriptions. Callers hold m.mu.
+func (m *Memory) recordSession(session *models.WorkoutSession, progressed []models.PlanExercise) {
+	session.ID = m.id("workout_sessions")
+	for i := range session.Sets {
+		session.Sets[i].ID = m.id("workout_sets")
+	}
+	m.sessions[session.ID] = copySession(*session)
+
+	now := time.Now().UTC()
+	for _, e := range progressed {
+		stored, found := m.exercises[e.ID]
+		if !found {
+			continue // Like an UPDATE matching no rows
+		}
+		e.PlanID, e.Position, e.Name, e.MuscleGroup = stored.PlanID, stored.Position, stored.Name, stored.MuscleGroup
+		e.UpdatedAt = now
+		m.exercises[e.ID] = e
+	}
+}
+
+func (wk memoryWorkouts) Recent(ctx context.Context, userID, limit int) ([]models.WorkoutSession, error) {
+	wk.m.mu.Lock()
+	defer wk.m.mu.Unlock()
+	sessions := wk.m.userSessions(userID, time.Time{})
+	sort.Slice(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if !a.PerformedAt.Equal(b.PerformedAt) {
+			return a.PerformedAt.After(b.PerformedAt)
+		}
+		return a.ID > b.ID
+	})
+	if len(sessions) > limit {
+		sessions = sessions[:limit]
+	}
+	return sessions, nil
+}
+
+func (wk memoryWorkouts) Since(ctx context.Context, userID int, since time.Time) ([]models.WorkoutSession, error) {
+	wk.m.mu.Lock()
+	defer wk.m.mu.Unlock()
+	sessions := wk.m.userSessions(userID, since)
+	sort.Slice(sessions, func(i, j int) bool {
+		a, b := sessions[i], sessions[j]
+		if !a.PerformedAt.Equal(b.PerformedAt) {
+			return a.PerformedAt.Before(b.PerformedAt)
+		}
+		return a.ID < b.ID
+	})
+	return sessions, nil
+}
+
+func (wk memoryWorkouts) ByID(ctx context.Context, id int) (models.WorkoutSession, error) {
+	wk.m.mu.Lock()
+	defer wk.m.mu.Unlock()
+	s, found := wk.m.sessions[id]
+	if !found {
+		return models.WorkoutSession{}, ErrNotFound
+	}
+	s = copySession(s)
+	sortSets(s.Sets)
+	return s, nil
+}
+
+// userSessions returns copies of the user's sessions performed at or after since, with their sets
+// ordered by set number. Callers hold m.mu.
+func (m *Memory) userSessions(userID int, since time.Time) []models.WorkoutSession {
+	sessions := []models.WorkoutSession{}
+	for _, s := range m.sessions {
+		if s.UserID == userID && !s.PerformedAt.Before(since) {
+			s = copySession(s)
+			sortSets(s.Sets)
+			sessions = append(sessions, s)
+		}
+	}
+	return sessions
+}
+
+func sortSets(sets []models.WorkoutSet) {
+	sort.SliceStable(sets, func(i, j int) bool {
+		a, b := sets[i], sets[j]
+		return a.SetNumber < b.SetNumber || (a.SetNumber == b.SetNumber && a.ID < b.ID)
+	})
+}
+
+func copySession(s models.WorkoutSession) models.WorkoutSession {
+	if s.PlanID != nil {
+		id := *s.PlanID
+		s.PlanID = &id
+	}
+	sets := make([]models.WorkoutSet, len(s.Sets))
+	for i, set := range s.Sets {
+		if set.PlanExerciseID != nil {
+			id := *set.PlanExerciseID
+			set.PlanExerciseID = &id
+		}
+		sets[i] = set
+	}
+	s.Sets = sets
+	return s
+}
+
+type memoryUploads struct{ m *Memory }
+
+func (up memoryUploads) Add(ctx context.Context, u models.Upload) (int, error) {
+	up.m.mu.Lock()
+	defer up.m.mu.Unlock()
+	u.ID = up.m.id("uploads")
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+	up.m.uploads[u.ID] = u
+	return u.ID, nil
+}
+
+func (up memoryUploads) List(ctx context.Context, userID int) ([]models.Upload, error) {
+	up.m.mu.Lock()
+	defer up.m.mu.Unlock()
+	uploads := []models.Upload{}
+	for _, u := range up.m.uploads {
+		if u.UserID == userID {
+			uploads = append(uploads, u)
+		}
+	}
+	sort.Slice(uploads, func(i, j int) bool { return uploads[i].ID < uploads[j].ID })
+	return uploads, nil
+}
+
+type memoryAudit struct{ m *Memory }
+
+func (a memoryAudit) Record(ctx context.Context, e *models.AuditEvent) error {
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+	e.ID = a.m.id("audit_log")
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	stored := *e
+	if e.ActorID != nil {
+		actorID := *e.ActorID
+		stored.ActorID = &actorID
+	}
+	a.m.audit = append(a.m.audit, stored)
+	return nil
+}
+
+func (a memoryAudit) List(ctx context.Context, f AuditFilter) ([]models.AuditEvent, error) {
+	a.m.mu.Lock()
+	defer a.m.mu.Unlock()
+	events := []models.AuditEvent{}
+	for i := len(a.m.audit) - 1; i >= 0 && len(events) < f.Limit; i-- {
+		e := a.m.audit[i]
+		switch {
+		case f.ActorID != 0 && (e.ActorID == nil || *e.ActorID != f.ActorID),
+			f.Action != "" && e.Action != f.Action,
+			f.TargetType != "" && e.TargetType != f.TargetType,
+			f.TargetID != "" && e.TargetID != f.TargetID,
+			!f.Since.IsZero() && e.CreatedAt.Before(f.Since),
+			!f.Until.IsZero() && !e.CreatedAt.Before(f.Until),
+			f.BeforeID != 0 && e.ID >= f.BeforeID:
+			continue
+		}
+		if e.ActorID != nil {
+			actorID := *e.ActorID
+			e.ActorID = &actorID
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
@@ -0,0 +1,16 @@
+// --- diet-fitness-backend/internal/store/postgres.go ---
+package store
+
+import "database/sql"
+
+// Postgres is the Store backed by a PostgreSQL database opened by db.InitDB. Its connections rewrite
+// the ? placeholders of the queries shared with the SQLite store, and the schema is translated when
+// it is created, so the two only differ in the errors the engines report.
+type Postgres struct {
+	SQLite
+}
+
+// NewPostgres creates a store on an open PostgreSQL database whose schema is already in place
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{SQLite{db: db, read: db}}
+}
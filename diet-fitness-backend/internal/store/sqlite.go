@@ -0,0 +1,528 @@
+// --- diet-fitness-backend/internal/store/sqlite.go ---
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const planExerciseColumns = `id, plan_id, position, name, muscle_group, scheme, sets, target_reps, reps_min, reps_max,
+	load_kg, target_rpe, one_rep_max_kg, wave_week, increment_kg, failure_streak, deload_after, deload_percent, updated_at`
+
+// SQLite is the Store backed by the application database
+type SQLite struct {
+	db   *sql.DB
+	read *sql.DB // Plain reads outside transactions; db unless ReadFrom set a separate pool
+}
+
+// NewSQLite creates a store on an open database whose schema is already in place
+func NewSQLite(db *sql.DB) *SQLite {
+	return &SQLite{db: db, read: db}
+}
+
+// ReadFrom sends plain reads to a separate pool, such as the read-only pool from db.OpenReadPool.
+// Writes, and reads that are part of a transaction, keep using the main database.
+func (s *SQLite) ReadFrom(read *sql.DB) *SQLite {
+	if read != nil {
+		s.read = read
+	}
+	return s
+}
+
+func (s *SQLite) Users() Users       { return sqliteUsers{s.db, s.read} }
+func (s *SQLite) Plans() Plans       { return sqlitePlans{s.db, s.read} }
+func (s *SQLite) Workouts() Workouts { return sqliteWorkouts{s.db, s.read} }
+func (s *SQLite) Uploads() Uploads   { return sqliteUploads{s.db, s.read} }
+func (s *SQLite) Audit() Audit       { return sqliteAudit{s.db, s.read} }
+
+// isUniqueViolation reports whether err is a unique constraint failure, as reported by SQLite or PostgreSQL
+func isUniqueViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") || strings.Contains(msg, "SQLSTATE 23505")
+}
+
+// inTx runs fn in a transaction, committing if it returns nil
+func inTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+type sqliteUsers struct{ db, read *sql.DB }
+
+func (u sqliteUsers) Create(ctx context.Context, email, passwordHash string) (int, error) {
+	var id int
+	err := u.db.QueryRowContext(ctx, "INSERT INTO users (email, password_hash) VALUES (?, ?) RETURNING id",
+		strings.ToLower(email), passwordHash).Scan(&id)
+	if err != nil && isUniqueViolation(err) {
+		return 0, ErrEmailTaken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error creating user: %w", err)
+	}
+	return id, nil
+}
+
+func (u sqliteUsers) CreateVerified(ctx context.Context, tx *sql.Tx, email string, verifiedAt time.Time) (int, error) {
+	var id int
+	err := u.writer(tx).QueryRowContext(ctx, "INSERT INTO users (email, password_hash, email_verified_at) VALUES (?, '', ?) RETURNING id",
+		strings.ToLower(email), verifiedAt).Scan(&id)
+	if err != nil && isUniqueViolation(err) {
+		return 0, ErrEmailTaken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error creating user: %w", err)
+	}
+	return id, nil
+}
+
+func (u sqliteUsers) SetPassword(ctx context.Context, tx *sql.Tx, userID int, passwordHash string) error {
+	res, err := u.writer(tx).ExecContext(ctx, "UPDATE users SET password_hash = ? WHERE id = ?", passwordHash, userID)
+	if err != nil {
+		return fmt.Errorf("error updating password of user %d: %w", userID, err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (u sqliteUsers) MarkVerified(ctx context.Context, tx *sql.Tx, userID int, at time.Time) error {
+	if _, err := u.writer(tx).ExecContext(ctx, "UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL", at, userID); err != nil {
+		return fmt.Errorf("error marking email of user %d as verified: %w", userID, err)
+	}
+	return nil
+}
+
+// writer is the caller's transaction, or the main database when there is none
+func (u sqliteUsers) writer(tx *sql.Tx) querier {
+	if tx != nil {
+		return tx
+	}
+	return u.db
+}
+
+func (u sqliteUsers) ByID(ctx context.Context, id int) (models.User, error) {
+	return u.scan(u.read.QueryRowContext(ctx, "SELECT id, email, password_hash, email_verified_at, created_at FROM users WHERE id = ?", id))
+}
+
+func (u sqliteUsers) ByEmail(ctx context.Context, email string) (models.User, error) {
+	return u.scan(u.read.QueryRowContext(ctx, "SELECT id, email, password_hash, email_verified_at, created_at FROM users WHERE LOWER(email) = ?",
+		strings.ToLower(email)))
+}
+
+// likeEscaper makes LIKE treat wildcards in search text literally; queries using it need ESCAPE '\'
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (u sqliteUsers) Search(ctx context.Context, emailContains string, limit int) ([]models.User, error) {
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(emailContains)) + "%"
+	rows, err := u.read.QueryContext(ctx, `SELECT id, email, password_hash, email_verified_at, created_at FROM users
+		WHERE LOWER(email) LIKE ? ESCAPE '\' ORDER BY id LIMIT ?`, pattern, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error searching users: %w", err)
+	}
+	defer rows.Close()
+	users := []models.User{}
+	for rows.Next() {
+		user, err := u.scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (sqliteUsers) scan(row rowScanner) (models.User, error) {
+	var (
+		user       models.User
+		verifiedAt sql.NullTime
+	)
+	err := row.Scan(&user.ID, &user.Email, &user.PasswordHash, &verifiedAt, &user.CreatedAt)
+	if err == sql.ErrNoRows {
+		return user, ErrNotFound
+	}
+	if err != nil {
+		return user, fmt.Errorf("error loading user: %w", err)
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return user, nil
+}
+
+type sqlitePlans struct{ db, read *sql.DB }
+
+func (p sqlitePlans) Active(ctx context.Context, userID int) (*models.WorkoutPlan, error) {
+	return activePlan(ctx, p.read, userID)
+}
+
+func (p sqlitePlans) CreateActive(ctx context.Context, plan models.WorkoutPlan) (*models.WorkoutPlan, error) {
+	var created *models.WorkoutPlan
+	err := inTx(ctx, p.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "UPDATE workout_plans SET is_active = 0 WHERE user_id = ? AND is_active = 1", plan.UserID); err != nil {
+			return fmt.Errorf("error deactivating previous plans: %w", err)
+		}
+		var planID int
+		err := tx.QueryRowContext(ctx, `INSERT INTO workout_plans (user_id, title, is_active, assigned_by, sessions_per_week, created_at)
+			VALUES (?, ?, 1, ?, ?, ?) RETURNING id`, plan.UserID, plan.Title, plan.AssignedBy, plan.SessionsPerWeek, time.Now().UTC()).Scan(&planID)
+		if err != nil {
+			return fmt.Errorf("error creating plan: %w", err)
+		}
+		if err := insertPlanExercises(ctx, tx, planID, plan.Exercises); err != nil {
+			return err
+		}
+		created, err = activePlan(ctx, tx, plan.UserID)
+		return err
+	})
+	return created, err
+}
+
+func (p sqlitePlans) ReplaceExercises(ctx context.Context, planID int, title string, exercises []models.PlanExercise) error {
+	return inTx(ctx, p.db, func(tx *sql.Tx) error {
+		var exists int
+		err := tx.QueryRowContext(ctx, "SELECT 1 FROM workout_plans WHERE id = ?", planID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("error loading plan %d: %w", planID, err)
+		}
+		if title != "" {
+			if _, err := tx.ExecContext(ctx, "UPDATE workout_plans SET title = ? WHERE id = ?", title, planID); err != nil {
+				return fmt.Errorf("error renaming plan %d: %w", planID, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE workout_sets SET plan_exercise_id = NULL WHERE plan_exercise_id IN (SELECT id FROM plan_exercises WHERE plan_id = ?)", planID); err != nil {
+			return fmt.Errorf("error detaching logged sets from plan %d: %w", planID, err)
+		}
+		if _, err := tx.ExecContext(ctx, "DELETE FROM plan_exercises WHERE plan_id = ?", planID); err != nil {
+			return fmt.Errorf("error clearing exercises of plan %d: %w", planID, err)
+		}
+		return insertPlanExercises(ctx, tx, planID, exercises)
+	})
+}
+
+// activePlan returns the user's active plan with its exercises, or nil if the user has none
+func activePlan(ctx context.Context, q querier, userID int) (*models.WorkoutPlan, error) {
+	plan := models.WorkoutPlan{UserID: userID, Active: true}
+	var assignedBy sql.NullInt64
+	err := q.QueryRowContext(ctx, "SELECT id, title, assigned_by, sessions_per_week, created_at FROM workout_plans WHERE user_id = ? AND is_active = 1 ORDER BY id DESC LIMIT 1", userID).
+		Scan(&plan.ID, &plan.Title, &assignedBy, &plan.SessionsPerWeek, &plan.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error loading active plan: %w", err)
+	}
+	if assignedBy.Valid {
+		id := int(assignedBy.Int64)
+		plan.AssignedBy = &id
+	}
+
+	rows, err := q.QueryContext(ctx, "SELECT "+planExerciseColumns+" FROM plan_exercises WHERE plan_id = ? ORDER BY position, id", plan.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading plan exercises: %w", err)
+	}
+	defer rows.Close()
+
+	plan.Exercises = []models.PlanExercise{}
+	for rows.Next() {
+		var e models.PlanExercise
+		err := rows.Scan(&e.ID, &e.PlanID, &e.Position, &e.Name, &e.MuscleGroup, &e.Scheme, &e.Sets, &e.TargetReps, &e.RepsMin, &e.RepsMax,
+			&e.LoadKg, &e.TargetRPE, &e.OneRepMaxKg, &e.WaveWeek, &e.IncrementKg, &e.FailureStreak, &e.DeloadAfter, &e.DeloadPercent, &e.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning plan exercise: %w", err)
+		}
+		plan.Exercises = append(plan.Exercises, e)
+	}
+	return &plan, rows.Err()
+}
+
+func insertPlanExercises(ctx context.Context, q querier, planID int, exercises []models.PlanExercise) error {
+	now := time.Now().UTC()
+	for i, e := range exercises {
+		_, err := q.ExecContext(ctx, `INSERT INTO plan_exercises (plan_id, position, name, muscle_group, scheme, sets, target_reps, reps_min, reps_max,
+			load_kg, target_rpe, one_rep_max_kg, wave_week, increment_kg, failure_streak, deload_after, deload_percent, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			planID, i, e.Name, e.MuscleGroup, e.Scheme, e.Sets, e.TargetReps, e.RepsMin, e.RepsMax,
+			e.LoadKg, e.TargetRPE, e.OneRepMaxKg, e.WaveWeek, e.IncrementKg, e.FailureStreak, e.DeloadAfter, e.DeloadPercent, now)
+		if err != nil {
+			return fmt.Errorf("error inserting plan exercise %q: %w", e.Name, err)
+		}
+	}
+	return nil
+}
+
+type sqliteWorkouts struct{ db, read *sql.DB }
+
+func (wk sqliteWorkouts) Record(ctx context.Context, session *models.WorkoutSession, progressed []models.PlanExercise) error {
+	return inTx(ctx, wk.db, func(tx *sql.Tx) error {
+		return recordSession(ctx, tx, session, progressed)
+	})
+}
+
+func (wk sqliteWorkouts) Progress(ctx context.Context, userID int, build func(plan *models.WorkoutPlan) (*models.WorkoutSession, []models.PlanExercise, error)) (*models.WorkoutSession, error) {
+	var session *models.WorkoutSession
+	err := inTx(ctx, wk.db, func(tx *sql.Tx) error {
+		// SQLite transactions on the main pool already hold the write lock; on PostgreSQL this row lock
+		// makes sessions of the same user wait for each other before reading the plan
+		if _, err := tx.ExecContext(ctx, "UPDATE workout_plans SET is_active = is_active WHERE user_id = ? AND is_active = 1", userID); err != nil {
+			return fmt.Errorf("error locking active plan: %w", err)
+		}
+		plan, err := activePlan(ctx, tx, userID)
+		if err != nil {
+			return err
+		}
+		var progressed []models.PlanExercise
+		session, progressed, err = build(plan)
+		if err != nil {
+			return err
+		}
+		return recordSession(ctx, tx, session, progressed)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// recordSession inserts a session with its sets, filling in their IDs, and saves the progressed prescriptions
+func recordSession(ctx context.Context, tx *sql.Tx, session *models.WorkoutSession, progressed []models.PlanExercise) error {
+	err := tx.QueryRowContext(ctx, "INSERT INTO workout_sessions (user_id, plan_id, performed_at, session_rpe, duration_min, notes) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		session.UserID, session.PlanID, session.PerformedAt, session.SessionRPE, session.DurationMin, session.Notes).Scan(&session.ID)
+	if err != nil {
+		return fmt.Errorf("error inserting workout session: %w", err)
+	}
+	for i, s := range session.Sets {
+		err = tx.QueryRowContext(ctx, `INSERT INTO workout_sets (session_id, plan_exercise_id, exercise_name, muscle_group, set_number, reps, load_kg, rpe)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			session.ID, s.PlanExerciseID, s.ExerciseName, s.MuscleGroup, s.SetNumber, s.Reps, s.LoadKg, s.RPE).Scan(&session.Sets[i].ID)
+		if err != nil {
+			return fmt.Errorf("error inserting workout set: %w", err)
+		}
+	}
+	now := time.Now().UTC()
+	for _, e := range progressed {
+		_, err := tx.ExecContext(ctx, `UPDATE plan_exercises SET scheme = ?, sets = ?, target_reps = ?, reps_min = ?, reps_max = ?, load_kg = ?, target_rpe = ?,
+			one_rep_max_kg = ?, wave_week = ?, increment_kg = ?, failure_streak = ?, deload_after = ?, deload_percent = ?, updated_at = ?
+			WHERE id = ?`,
+			e.Scheme, e.Sets, e.TargetReps, e.RepsMin, e.RepsMax, e.LoadKg, e.TargetRPE,
+			e.OneRepMaxKg, e.WaveWeek, e.IncrementKg, e.FailureStreak, e.DeloadAfter, e.DeloadPercent, now, e.ID)
+		if err != nil {
+			return fmt.Errorf("error updating plan exercise %d: %w", e.ID, err)
+		}
+	}
+	return nil
+}
+
+func (wk sqliteWorkouts) Recent(ctx context.Context, userID, limit int) ([]models.WorkoutSession, error) {
+	return wk.sessions(ctx, "WHERE user_id = ? ORDER BY performed_at DESC, id DESC LIMIT ?", userID, limit)
+}
+
+func (wk sqliteWorkouts) Since(ctx context.Context, userID int, since time.Time) ([]models.WorkoutSession, error) {
+	return wk.sessions(ctx, "WHERE user_id = ? AND performed_at >= ? ORDER BY performed_at, id", userID, since)
+}
+
+func (wk sqliteWorkouts) ByID(ctx context.Context, id int) (models.WorkoutSession, error) {
+	sessions, err := wk.sessions(ctx, "WHERE id = ?", id)
+	if err != nil {
+		return models.WorkoutSession{}, err
+	}
+	if len(sessions) == 0 {
+		return models.WorkoutSession{}, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+// sessions loads the sessions picked by filter, a WHERE clause over workout_sessions with any ordering
+// and limit, together with their sets ordered by set number
+func (wk sqliteWorkouts) sessions(ctx context.Context, filter string, args ...interface{}) ([]models.WorkoutSession, error) {
+	rows, err := wk.read.QueryContext(ctx, "SELECT id, user_id, plan_id, performed_at, session_rpe, duration_min, notes FROM workout_sessions "+filter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading sessions: %w", err)
+	}
+	sessions := []models.WorkoutSession{}
+	index := map[int]int{}
+	for rows.Next() {
+		s := models.WorkoutSession{Sets: []models.WorkoutSet{}}
+		var planID sql.NullInt64
+		if err := rows.Scan(&s.ID, &s.UserID, &planID, &s.PerformedAt, &s.SessionRPE, &s.DurationMin, &s.Notes); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("error scanning session: %w", err)
+		}
+		if planID.Valid {
+			id := int(planID.Int64)
+			s.PlanID = &id
+		}
+		index[s.ID] = len(sessions)
+		sessions = append(sessions, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading sessions: %w", err)
+	}
+	if len(sessions) == 0 {
+		return sessions, nil
+	}
+
+	setRows, err := wk.read.QueryContext(ctx, `SELECT id, session_id, plan_exercise_id, exercise_name, muscle_group, set_number, reps, load_kg, rpe
+		FROM workout_sets WHERE session_id IN (SELECT id FROM workout_sessions `+filter+`)
+		ORDER BY session_id, set_number, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading sets: %w", err)
+	}
+	defer setRows.Close()
+	for setRows.Next() {
+		var set models.WorkoutSet
+		var sessionID int
+		var exerciseID sql.NullInt64
+		if err := setRows.Scan(&set.ID, &sessionID, &exerciseID, &set.ExerciseName, &set.MuscleGroup, &set.SetNumber, &set.Reps, &set.LoadKg, &set.RPE); err != nil {
+			return nil, fmt.Errorf("error scanning set: %w", err)
+		}
+		if exerciseID.Valid {
+			id := int(exerciseID.Int64)
+			set.PlanExerciseID = &id
+		}
+		if i, found := index[sessionID]; found {
+			sessions[i].Sets = append(sessions[i].Sets, set)
+		}
+	}
+	return sessions, setRows.Err()
+}
+
+type sqliteUploads struct{ db, read *sql.DB }
+
+func (up sqliteUploads) Add(ctx context.Context, u models.Upload) (int, error) {
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = time.Now().UTC()
+	}
+	var id int
+	err := up.db.QueryRowContext(ctx, "INSERT INTO uploads (user_id, filename, original_name, size_bytes, created_at) VALUES (?, ?, ?, ?, ?) RETURNING id",
+		u.UserID, u.Filename, u.OriginalName, u.SizeBytes, u.CreatedAt).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error recording upload: %w", err)
+	}
+	return id, nil
+}
+
+func (up sqliteUploads) List(ctx context.Context, userID int) ([]models.Upload, error) {
+	rows, err := up.read.QueryContext(ctx, "SELECT id, user_id, filename, original_name, size_bytes, created_at FROM uploads WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing uploads: %w", err)
+	}
+	defer rows.Close()
+	uploads := []models.Upload{}
+	for rows.Next() {
+		var u models.Upload
+		if err := rows.Scan(&u.ID, &u.UserID, &u.Filename, &u.OriginalName, &u.SizeBytes, &u.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning upload: %w", err)
+		}
+		uploads = append(uploads, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing uploads: %w", err)
+	}
+	return uploads, nil
+}
+
+type sqliteAudit struct{ db, read *sql.DB }
+
+func (a sqliteAudit) Record(ctx context.Context, e *models.AuditEvent) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now().UTC()
+	}
+	err := a.db.QueryRowContext(ctx, `INSERT INTO audit_log (actor_id, auth_method, action, target_type, target_id, detail, ip, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		e.ActorID, e.AuthMethod, e.Action, e.TargetType, e.TargetID, e.Detail, e.IP, e.RequestID, e.CreatedAt).Scan(&e.ID)
+	if err != nil {
+		return fmt.Errorf("error recording audit event: %w", err)
+	}
+	return nil
+}
+
+func (a sqliteAudit) List(ctx context.Context, f AuditFilter) ([]models.AuditEvent, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+	if f.ActorID != 0 {
+		add("actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = ?", f.TargetID)
+	}
+	if !f.Since.IsZero() {
+		add("created_at >= ?", f.Since.UTC())
+	}
+	if !f.Until.IsZero() {
+		add("created_at < ?", f.Until.UTC())
+	}
+	if f.BeforeID != 0 {
+		add("id < ?", f.BeforeID)
+	}
+	query := "SELECT id, actor_id, auth_method, action, target_type, target_id, detail, ip, request_id, created_at FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := a.read.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing audit events: %w", err)
+	}
+	defer rows.Close()
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var (
+			e       models.AuditEvent
+			actorID sql.NullInt64
+		)
+		if err := rows.Scan(&e.ID, &actorID, &e.AuthMethod, &e.Action, &e.TargetType, &e.TargetID, &e.Detail, &e.IP, &e.RequestID, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning audit event: %w", err)
+		}
+		if actorID.Valid {
+			id := int(actorID.Int64)
+			e.ActorID = &id
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error listing audit events: %w", err)
+	}
+	return events, nil
+}
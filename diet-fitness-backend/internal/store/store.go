@@ -0,0 +1,117 @@
+// --- diet-fitness-backend/internal/store/store.go ---
+// Package store is the persistence layer for users, workout plans, logged workouts, uploads and the
+// audit log. Handlers depend on the interfaces here rather than on *sql.DB, so they can run against
+// the in-memory implementation in unit tests and against SQLite or PostgreSQL in production.
+// Writes that must commit together with tables the store doesn't cover take the caller's transaction.
+// Account export and erasure still run on *sql.DB, as do the aggregates that have no repository yet:
+// tokens, sessions, two-factor, API keys, coaching, diary, weights, activities and jobs.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when the requested record doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrEmailTaken is returned when creating a user whose email is already registered
+	ErrEmailTaken = errors.New("email already registered")
+)
+
+// Store groups the repositories of each aggregate
+type Store interface {
+	Users() Users
+	Plans() Plans
+	Workouts() Workouts
+	Uploads() Uploads
+	Audit() Audit
+}
+
+// Users stores accounts. Emails are stored lower-cased and looked up case-insensitively, since
+// accounts registered before normalization may differ only in case. Methods taking a tx write in it,
+// so the change commits with the caller's other writes, or on their own when it is nil; the memory
+// store has no transactions and applies them at once.
+type Users interface {
+	// Create registers an account and returns its ID, or ErrEmailTaken
+	Create(ctx context.Context, email, passwordHash string) (int, error)
+	// CreateVerified registers an account without a password whose email a sign-in provider verified
+	// at the given time, and returns its ID, or ErrEmailTaken
+	CreateVerified(ctx context.Context, tx *sql.Tx, email string, verifiedAt time.Time) (int, error)
+	// SetPassword replaces the password hash; an empty hash leaves the account without a password.
+	// It returns ErrNotFound for unknown users.
+	SetPassword(ctx context.Context, tx *sql.Tx, userID int, passwordHash string) error
+	// MarkVerified records that the user proved they own their email, keeping an earlier time
+	MarkVerified(ctx context.Context, tx *sql.Tx, userID int, at time.Time) error
+	// ByID returns the account, or ErrNotFound
+	ByID(ctx context.Context, id int) (models.User, error)
+	// ByEmail returns the account registered under the email, or ErrNotFound
+	ByEmail(ctx context.Context, email string) (models.User, error)
+	// Search returns up to limit accounts whose email contains the text, ignoring case, by ID.
+	// Wildcard characters in the text match themselves.
+	Search(ctx context.Context, emailContains string, limit int) ([]models.User, error)
+}
+
+// Plans stores structured workout plans. A user has at most one active plan.
+type Plans interface {
+	// Active returns the user's active plan with its exercises, or nil if the user has none
+	Active(ctx context.Context, userID int) (*models.WorkoutPlan, error)
+	// CreateActive stores plan.Exercises as the user's new active plan, deactivating any previous one,
+	// and returns it as stored. Title, AssignedBy and SessionsPerWeek are taken from plan.
+	CreateActive(ctx context.Context, plan models.WorkoutPlan) (*models.WorkoutPlan, error)
+	// ReplaceExercises swaps the exercises of a plan, renaming it unless title is empty. Logged sets
+	// keep their exercise name but no longer point at the replaced exercises.
+	ReplaceExercises(ctx context.Context, planID int, title string, exercises []models.PlanExercise) error
+}
+
+// Workouts stores logged training sessions
+type Workouts interface {
+	// Record stores a session with its sets, filling in their IDs, and saves the recomputed
+	// prescriptions of the plan exercises it progressed, all at once
+	Record(ctx context.Context, session *models.WorkoutSession, progressed []models.PlanExercise) error
+	// Progress is Record for sessions that progress the plan: build gets the user's active plan (nil if
+	// none) as read inside the write, and returns the session and the recomputed plan exercises. Sessions
+	// logged at the same time take turns, so each progresses from the other's result. An error from
+	// build is returned as is, with nothing written.
+	Progress(ctx context.Context, userID int, build func(plan *models.WorkoutPlan) (*models.WorkoutSession, []models.PlanExercise, error)) (*models.WorkoutSession, error)
+	// Recent returns the user's latest sessions with their sets, newest first
+	Recent(ctx context.Context, userID, limit int) ([]models.WorkoutSession, error)
+	// Since returns the user's sessions performed at or after since with their sets, oldest first
+	Since(ctx context.Context, userID int, since time.Time) ([]models.WorkoutSession, error)
+	// ByID returns a session with its sets, or ErrNotFound
+	ByID(ctx context.Context, id int) (models.WorkoutSession, error)
+}
+
+// Uploads records the images users upload
+type Uploads interface {
+	// Add records an upload and returns its ID
+	Add(ctx context.Context, u models.Upload) (int, error)
+	// List returns the user's uploads, oldest first
+	List(ctx context.Context, userID int) ([]models.Upload, error)
+}
+
+// Audit stores the append-only audit log. Entries are never updated or deleted, not even with the
+// account of their actor.
+type Audit interface {
+	// Record appends an event, filling in its ID and, when zero, its time
+	Record(ctx context.Context, e *models.AuditEvent) error
+	// List returns the events matching the filter, newest first
+	List(ctx context.Context, f AuditFilter) ([]models.AuditEvent, error)
+}
+
+// AuditFilter selects audit events; zero fields match everything. BeforeID continues a listing from
+// the last ID of the previous page.
+type AuditFilter struct {
+	ActorID    int
+	Action     string
+	TargetType string
+	TargetID   string
+	Since      time.Time // Inclusive
+	Until      time.Time // Exclusive
+	BeforeID   int
+	Limit      int // Required
+}
@@ -0,0 +1,765 @@
+// --- diet-fitness-backend/internal/store/storetest/storetest.go ---
+// Package storetest is the behaviour every store.Store implementation has to share. The same checks run
+// against the in-memory, SQLite and PostgreSQL stores (see the store package tests and cmd/storecheck), so
+// the engines can't drift apart. Each check works on accounts of its own, which lets the checks run against a database that
+// already holds data, including the results of earlier runs.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"diet-fitness-backend/internal/models"
+	"diet-fitness-backend/internal/store"
+)
+
+// Check is one contract check
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, st store.Store, email func(name string) string) error
+}
+
+// Result is the outcome of a check; Err is nil when it passed
+type Result struct {
+	Name string
+	Err  error
+}
+
+// Checks lists the contract in the order it is run
+var Checks = []Check{
+	{"users/create-and-load", usersCreateAndLoad},
+	{"users/duplicate-email", usersDuplicateEmail},
+	{"users/not-found", usersNotFound},
+	{"users/search", usersSearch},
+	{"users/create-verified", usersCreateVerified},
+	{"users/set-password-and-verify", usersSetPasswordAndVerify},
+	{"plans/no-active-plan", plansNoActivePlan},
+	{"plans/create-active", plansCreateActive},
+	{"plans/replaces-active", plansReplacesActive},
+	{"plans/replace-exercises", plansReplaceExercises},
+	{"plans/replace-missing", plansReplaceMissing},
+	{"workouts/record-and-progress", workoutsRecordAndProgress},
+	{"workouts/recent-order-and-limit", workoutsRecentOrderAndLimit},
+	{"workouts/detached-sets", workoutsDetachedSets},
+	{"workouts/concurrent-progress", workoutsConcurrentProgress},
+	{"workouts/since-and-by-id", workoutsSinceAndByID},
+	{"uploads/add-and-list", uploadsAddAndList},
+	{"audit/record-and-filter", auditRecordAndFilter},
+	{"audit/pagination", auditPagination},
+}
+
+// Run runs every check against st and returns their results
+func Run(ctx context.Context, st store.Store) []Result {
+	run := time.Now().UnixNano()
+	results := make([]Result, 0, len(Checks))
+	for i, c := range Checks {
+		email := func(name string) string {
+			return fmt.Sprintf("%s.%d.%d@storecheck.test", name, run, i)
+		}
+		results = append(results, Result{Name: c.Name, Err: c.Run(ctx, st, email)})
+	}
+	return results
+}
+
+func newUser(ctx context.Context, st store.Store, email string) (int, error) {
+	id, err := st.Users().Create(ctx, email, "hash")
+	if err != nil {
+		return 0, fmt.Errorf("creating %s: %w", email, err)
+	}
+	return id, nil
+}
+
+func exercises(names ...string) []models.PlanExercise {
+	list := make([]models.PlanExercise, len(names))
+	for i, name := range names {
+		list[i] = models.PlanExercise{Name: name, MuscleGroup: "legs", Scheme: "linear", Sets: 3, TargetReps: 5, LoadKg: 100, IncrementKg: 5}
+	}
+	return list
+}
+
+func exerciseNames(plan *models.WorkoutPlan) string {
+	names := make([]string, len(plan.Exercises))
+	for i, e := range plan.Exercises {
+		names[i] = e.Name
+	}
+	return strings.Join(names, ",")
+}
+
+func usersCreateAndLoad(ctx context.Context, st store.Store, email func(string) string) error {
+	address := email("Mixed.Case")
+	id, err := st.Users().Create(ctx, address, "hash")
+	if err != nil {
+		return err
+	}
+	if id <= 0 {
+		return fmt.Errorf("got ID %d, want a positive ID", id)
+	}
+	user, err := st.Users().ByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("ByID: %w", err)
+	}
+	if user.Email != strings.ToLower(address) || user.PasswordHash != "hash" {
+		return fmt.Errorf("ByID returned %q/%q, want the lower-cased email and the hash", user.Email, user.PasswordHash)
+	}
+	if user.EmailVerifiedAt != nil {
+		return fmt.Errorf("a new account is already verified")
+	}
+	if user.CreatedAt.IsZero() {
+		return fmt.Errorf("CreatedAt is not set")
+	}
+	byEmail, err := st.Users().ByEmail(ctx, strings.ToUpper(address))
+	if err != nil {
+		return fmt.Errorf("ByEmail in another case: %w", err)
+	}
+	if byEmail.ID != id {
+		return fmt.Errorf("ByEmail returned user %d, want %d", byEmail.ID, id)
+	}
+	return nil
+}
+
+func usersDuplicateEmail(ctx context.Context, st store.Store, email func(string) string) error {
+	address := email("taken")
+	if _, err := newUser(ctx, st, address); err != nil {
+		return err
+	}
+	_, err := st.Users().Create(ctx, strings.ToUpper(address), "other")
+	if !errors.Is(err, store.ErrEmailTaken) {
+		return fmt.Errorf("registering the email again returned %v, want ErrEmailTaken", err)
+	}
+	return nil
+}
+
+func usersNotFound(ctx context.Context, st store.Store, email func(string) string) error {
+	if _, err := st.Users().ByEmail(ctx, email("nobody")); !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("ByEmail of an unknown address returned %v, want ErrNotFound", err)
+	}
+	if _, err := st.Users().ByID(ctx, -1); !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("ByID of an unknown ID returned %v, want ErrNotFound", err)
+	}
+	return nil
+}
+
+func usersSearch(ctx context.Context, st store.Store, email func(string) string) error {
+	literal, other := email("find_me"), email("findxme")
+	literalID, err := newUser(ctx, st, literal)
+	if err != nil {
+		return err
+	}
+	otherID, err := newUser(ctx, st, other)
+	if err != nil {
+		return err
+	}
+
+	// The underscore must not act as a wildcard matching the x
+	text := strings.ToUpper(strings.Split(literal, "@")[0])
+	found, err := st.Users().Search(ctx, text, 10)
+	if err != nil {
+		return err
+	}
+	if len(found) != 1 || found[0].ID != literalID || found[0].Email != literal {
+		return fmt.Errorf("Search(%q) returned %+v, want only %s", text, found, literal)
+	}
+
+	// Both share the run's suffix; the limit keeps the lower ID
+	suffix := other[strings.Index(other, "."):]
+	found, err = st.Users().Search(ctx, suffix, 1)
+	if err != nil {
+		return err
+	}
+	if len(found) != 1 || found[0].ID != min(literalID, otherID) {
+		return fmt.Errorf("Search(%q) with limit 1 returned %+v, want user %d", suffix, found, min(literalID, otherID))
+	}
+	found, err = st.Users().Search(ctx, "%"+suffix, 10)
+	if err != nil {
+		return err
+	}
+	if len(found) != 0 {
+		return fmt.Errorf("Search with a leading %% returned %d users, want none", len(found))
+	}
+	return nil
+}
+
+func usersCreateVerified(ctx context.Context, st store.Store, email func(string) string) error {
+	address := email("Provider")
+	verifiedAt := time.Now().UTC().Add(-time.Minute).Truncate(time.Second)
+	id, err := st.Users().CreateVerified(ctx, nil, address, verifiedAt)
+	if err != nil {
+		return err
+	}
+	user, err := st.Users().ByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("ByID: %w", err)
+	}
+	if user.Email != strings.ToLower(address) || user.PasswordHash != "" {
+		return fmt.Errorf("ByID returned %q/%q, want the lower-cased email and no password", user.Email, user.PasswordHash)
+	}
+	if user.EmailVerifiedAt == nil || !user.EmailVerifiedAt.Equal(verifiedAt) {
+		return fmt.Errorf("EmailVerifiedAt is %v, want %v", user.EmailVerifiedAt, verifiedAt)
+	}
+	if _, err := st.Users().CreateVerified(ctx, nil, strings.ToUpper(address), verifiedAt); !errors.Is(err, store.ErrEmailTaken) {
+		return fmt.Errorf("creating the email again returned %v, want ErrEmailTaken", err)
+	}
+	return nil
+}
+
+func usersSetPasswordAndVerify(ctx context.Context, st store.Store, email func(string) string) error {
+	id, err := newUser(ctx, st, email("changes"))
+	if err != nil {
+		return err
+	}
+	if err := st.Users().SetPassword(ctx, nil, id, "new-hash"); err != nil {
+		return fmt.Errorf("SetPassword: %w", err)
+	}
+	user, err := st.Users().ByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("ByID: %w", err)
+	}
+	if user.PasswordHash != "new-hash" {
+		return fmt.Errorf("password hash is %q after SetPassword, want new-hash", user.PasswordHash)
+	}
+	if err := st.Users().SetPassword(ctx, nil, id, ""); err != nil {
+		return fmt.Errorf("clearing the password: %w", err)
+	}
+	if user, err = st.Users().ByID(ctx, id); err != nil || user.PasswordHash != "" {
+		return fmt.Errorf("password hash is %q (%v) after clearing it, want none", user.PasswordHash, err)
+	}
+	if err := st.Users().SetPassword(ctx, nil, -1, "hash"); !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("SetPassword of an unknown ID returned %v, want ErrNotFound", err)
+	}
+
+	first := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	for _, at := range []time.Time{first, first.Add(time.Minute)} {
+		if err := st.Users().MarkVerified(ctx, nil, id, at); err != nil {
+			return fmt.Errorf("MarkVerified: %w", err)
+		}
+	}
+	if user, err = st.Users().ByID(ctx, id); err != nil {
+		return fmt.Errorf("ByID: %w", err)
+	}
+	if user.EmailVerifiedAt == nil || !user.EmailVerifiedAt.Equal(first) {
+		return fmt.Errorf("EmailVerifiedAt is %v after verifying twice, want the first time %v", user.EmailVerifiedAt, first)
+	}
+	return nil
+}
+
+func plansNoActivePlan(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("planless"))
+	if err != nil {
+		return err
+	}
+	plan, err := st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if plan != nil {
+		return fmt.Errorf("a new account has active plan %d", plan.ID)
+	}
+	return nil
+}
+
+func plansCreateActive(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("client"))
+	if err != nil {
+		return err
+	}
+	coachID, err := newUser(ctx, st, email("coach"))
+	if err != nil {
+		return err
+	}
+	plan, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: userID, Title: "Strength", AssignedBy: &coachID, SessionsPerWeek: 3,
+		Exercises: exercises("Squat", "Bench", "Row")})
+	if err != nil {
+		return err
+	}
+	if plan == nil || plan.ID <= 0 || !plan.Active || plan.UserID != userID {
+		return fmt.Errorf("CreateActive returned %+v, want the stored active plan", plan)
+	}
+	if plan.Title != "Strength" || plan.SessionsPerWeek != 3 || plan.AssignedBy == nil || *plan.AssignedBy != coachID {
+		return fmt.Errorf("title, sessions per week or coach were not stored: %+v", plan)
+	}
+	if got := exerciseNames(plan); got != "Squat,Bench,Row" {
+		return fmt.Errorf("exercises are %s, want them in the order given", got)
+	}
+	for i, e := range plan.Exercises {
+		if e.ID <= 0 || e.PlanID != plan.ID || e.Position != i || e.LoadKg != 100 {
+			return fmt.Errorf("exercise %d stored as %+v", i, e)
+		}
+	}
+	active, err := st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if active == nil || active.ID != plan.ID || exerciseNames(active) != exerciseNames(plan) {
+		return fmt.Errorf("Active returned %+v, want plan %d", active, plan.ID)
+	}
+	return nil
+}
+
+func plansReplacesActive(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("replanned"))
+	if err != nil {
+		return err
+	}
+	first, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: userID, Title: "First", Exercises: exercises("Squat")})
+	if err != nil {
+		return err
+	}
+	second, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: userID, Title: "Second", Exercises: exercises("Deadlift")})
+	if err != nil {
+		return err
+	}
+	if second.ID == first.ID {
+		return fmt.Errorf("both plans have ID %d", first.ID)
+	}
+	active, err := st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if active == nil || active.ID != second.ID || exerciseNames(active) != "Deadlift" {
+		return fmt.Errorf("Active returned %+v, want the second plan", active)
+	}
+	return nil
+}
+
+func plansReplaceExercises(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("edited"))
+	if err != nil {
+		return err
+	}
+	plan, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: userID, Title: "Original", Exercises: exercises("Squat", "Bench")})
+	if err != nil {
+		return err
+	}
+	if err := st.Plans().ReplaceExercises(ctx, plan.ID, "", exercises("Press")); err != nil {
+		return err
+	}
+	active, err := st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if active.Title != "Original" || exerciseNames(active) != "Press" {
+		return fmt.Errorf("after replacing without a title got %q with %s, want Original with Press", active.Title, exerciseNames(active))
+	}
+	if err := st.Plans().ReplaceExercises(ctx, plan.ID, "Renamed", exercises("Dip", "Chin")); err != nil {
+		return err
+	}
+	active, err = st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if active.ID != plan.ID || active.Title != "Renamed" || exerciseNames(active) != "Dip,Chin" {
+		return fmt.Errorf("after replacing with a title got plan %d %q with %s", active.ID, active.Title, exerciseNames(active))
+	}
+	return nil
+}
+
+func plansReplaceMissing(ctx context.Context, st store.Store, email func(string) string) error {
+	err := st.Plans().ReplaceExercises(ctx, -1, "Ghost", exercises("Squat"))
+	if !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("replacing the exercises of an unknown plan returned %v, want ErrNotFound", err)
+	}
+	return nil
+}
+
+func workoutsRecordAndProgress(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("lifter"))
+	if err != nil {
+		return err
+	}
+	plan, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: userID, Title: "Plan", Exercises: exercises("Squat", "Bench")})
+	if err != nil {
+		return err
+	}
+	squat := plan.Exercises[0]
+	session := models.WorkoutSession{UserID: userID, PlanID: &plan.ID, PerformedAt: time.Now().UTC().Truncate(time.Second),
+		SessionRPE: 7.5, DurationMin: 45, Notes: "felt good",
+		Sets: []models.WorkoutSet{
+			{PlanExerciseID: &squat.ID, ExerciseName: squat.Name, MuscleGroup: "legs", SetNumber: 1, Reps: 5, LoadKg: 100, RPE: 7},
+			{PlanExerciseID: &squat.ID, ExerciseName: squat.Name, MuscleGroup: "legs", SetNumber: 2, Reps: 5, LoadKg: 100, RPE: 8},
+			{ExerciseName: "Curl", MuscleGroup: "biceps", SetNumber: 3, Reps: 12, LoadKg: 15},
+		}}
+	progressed := squat
+	progressed.LoadKg = 105
+	progressed.FailureStreak = 0
+	if err := st.Workouts().Record(ctx, &session, []models.PlanExercise{progressed}); err != nil {
+		return err
+	}
+	if session.ID <= 0 {
+		return fmt.Errorf("Record didn't fill in the session ID")
+	}
+	for i, s := range session.Sets {
+		if s.ID <= 0 {
+			return fmt.Errorf("Record didn't fill in the ID of set %d", i)
+		}
+	}
+
+	active, err := st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if active.Exercises[0].LoadKg != 105 || active.Exercises[1].LoadKg != 100 {
+		return fmt.Errorf("after progressing the squat the loads are %v and %v, want 105 and 100", active.Exercises[0].LoadKg, active.Exercises[1].LoadKg)
+	}
+	if active.Exercises[0].Name != "Squat" || active.Exercises[0].Position != 0 {
+		return fmt.Errorf("progression changed the exercise's name or position: %+v", active.Exercises[0])
+	}
+
+	recent, err := st.Workouts().Recent(ctx, userID, 10)
+	if err != nil {
+		return err
+	}
+	if len(recent) != 1 {
+		return fmt.Errorf("Recent returned %d sessions, want 1", len(recent))
+	}
+	got := recent[0]
+	if got.ID != session.ID || got.UserID != userID || got.PlanID == nil || *got.PlanID != plan.ID ||
+		got.SessionRPE != 7.5 || got.DurationMin != 45 || got.Notes != "felt good" || !got.PerformedAt.Equal(session.PerformedAt) {
+		return fmt.Errorf("Recent returned %+v, want the recorded session", got)
+	}
+	if len(got.Sets) != 3 || got.Sets[2].PlanExerciseID != nil || got.Sets[0].PlanExerciseID == nil || *got.Sets[0].PlanExerciseID != squat.ID {
+		return fmt.Errorf("Recent returned sets %+v", got.Sets)
+	}
+	return nil
+}
+
+func workoutsRecentOrderAndLimit(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("regular"))
+	if err != nil {
+		return err
+	}
+	otherID, err := newUser(ctx, st, email("other"))
+	if err != nil {
+		return err
+	}
+	base := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	// Recorded out of order; the last two share a time, so the later ID comes first
+	for _, day := range []int{2, 0, 3, 3} {
+		session := models.WorkoutSession{UserID: userID, PerformedAt: base.AddDate(0, 0, day), Notes: fmt.Sprintf("day %d", day),
+			Sets: []models.WorkoutSet{
+				{ExerciseName: "Squat", SetNumber: 2, Reps: 5},
+				{ExerciseName: "Squat", SetNumber: 1, Reps: 5},
+			}}
+		if err := st.Workouts().Record(ctx, &session, nil); err != nil {
+			return err
+		}
+	}
+	if err := st.Workouts().Record(ctx, &models.WorkoutSession{UserID: otherID, PerformedAt: base.AddDate(0, 0, 9)}, nil); err != nil {
+		return err
+	}
+
+	recent, err := st.Workouts().Recent(ctx, userID, 3)
+	if err != nil {
+		return err
+	}
+	if len(recent) != 3 {
+		return fmt.Errorf("Recent with limit 3 returned %d sessions", len(recent))
+	}
+	if recent[0].Notes != "day 3" || recent[1].Notes != "day 3" || recent[2].Notes != "day 2" || recent[0].ID < recent[1].ID {
+		return fmt.Errorf("Recent returned %q, %q, %q; want newest first with ties broken by ID", recent[0].Notes, recent[1].Notes, recent[2].Notes)
+	}
+	for _, s := range recent {
+		if s.UserID != userID {
+			return fmt.Errorf("Recent returned session %d of user %d", s.ID, s.UserID)
+		}
+		if len(s.Sets) != 2 || s.Sets[0].SetNumber != 1 || s.Sets[1].SetNumber != 2 {
+			return fmt.Errorf("session %d has sets %+v, want them ordered by set number", s.ID, s.Sets)
+		}
+	}
+	return nil
+}
+
+func workoutsDetachedSets(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("rebuilt"))
+	if err != nil {
+		return err
+	}
+	plan, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: userID, Title: "Plan", Exercises: exercises("Squat")})
+	if err != nil {
+		return err
+	}
+	squat := plan.Exercises[0]
+	session := models.WorkoutSession{UserID: userID, PlanID: &plan.ID, PerformedAt: time.Now().UTC(),
+		Sets: []models.WorkoutSet{{PlanExerciseID: &squat.ID, ExerciseName: squat.Name, SetNumber: 1, Reps: 5, LoadKg: 100}}}
+	if err := st.Workouts().Record(ctx, &session, nil); err != nil {
+		return err
+	}
+	if err := st.Plans().ReplaceExercises(ctx, plan.ID, "", exercises("Front Squat")); err != nil {
+		return err
+	}
+	recent, err := st.Workouts().Recent(ctx, userID, 1)
+	if err != nil {
+		return err
+	}
+	if len(recent) != 1 || len(recent[0].Sets) != 1 {
+		return fmt.Errorf("Recent returned %+v, want the one session", recent)
+	}
+	set := recent[0].Sets[0]
+	if set.PlanExerciseID != nil || set.ExerciseName != "Squat" {
+		return fmt.Errorf("after replacing the exercises the logged set is %+v, want it detached but still named Squat", set)
+	}
+	return nil
+}
+
+// Sessions progressing the same exercise at once each build on the other's result, and a session
+// whose build fails writes nothing
+func workoutsConcurrentProgress(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("eager"))
+	if err != nil {
+		return err
+	}
+	if _, err := st.Plans().CreateActive(ctx, models.WorkoutPlan{UserID: userID, Title: "Plan", Exercises: exercises("Squat")}); err != nil {
+		return err
+	}
+	progress := func(plan *models.WorkoutPlan) (*models.WorkoutSession, []models.PlanExercise, error) {
+		if plan == nil {
+			return nil, nil, fmt.Errorf("Progress passed no plan")
+		}
+		time.Sleep(time.Millisecond) // Work between the read and the write, so sessions overlap
+		squat := plan.Exercises[0]
+		session := &models.WorkoutSession{UserID: userID, PlanID: &plan.ID, PerformedAt: time.Now().UTC(),
+			Sets: []models.WorkoutSet{{PlanExerciseID: &squat.ID, ExerciseName: squat.Name, SetNumber: 1, Reps: 5, LoadKg: squat.LoadKg}}}
+		squat.LoadKg += squat.IncrementKg
+		return session, []models.PlanExercise{squat}, nil
+	}
+
+	const sessions = 8
+	errs := make(chan error, sessions)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < sessions; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := st.Workouts().Progress(ctx, userID, progress)
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	errBuild := errors.New("rejected")
+	_, err = st.Workouts().Progress(ctx, userID, func(plan *models.WorkoutPlan) (*models.WorkoutSession, []models.PlanExercise, error) {
+		return nil, nil, errBuild
+	})
+	if !errors.Is(err, errBuild) {
+		return fmt.Errorf("Progress with a failing build returned %v, want its error", err)
+	}
+
+	active, err := st.Plans().Active(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if want := 100.0 + sessions*5; active.Exercises[0].LoadKg != want {
+		return fmt.Errorf("after %d sessions the load is %v, want %v: progressions were lost", sessions, active.Exercises[0].LoadKg, want)
+	}
+	recent, err := st.Workouts().Recent(ctx, userID, 2*sessions)
+	if err != nil {
+		return err
+	}
+	if len(recent) != sessions {
+		return fmt.Errorf("%d sessions recorded, want %d", len(recent), sessions)
+	}
+	return nil
+}
+
+func workoutsSinceAndByID(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("history"))
+	if err != nil {
+		return err
+	}
+	otherID, err := newUser(ctx, st, email("bystander"))
+	if err != nil {
+		return err
+	}
+	base := time.Date(2024, 5, 1, 7, 0, 0, 0, time.UTC)
+	ids := map[int]int{}
+	for _, day := range []int{3, 0, 1, 3} {
+		session := models.WorkoutSession{UserID: userID, PerformedAt: base.AddDate(0, 0, day), Notes: fmt.Sprintf("day %d", day),
+			Sets: []models.WorkoutSet{
+				{ExerciseName: "Row", SetNumber: 2, Reps: 8},
+				{ExerciseName: "Row", SetNumber: 1, Reps: 8},
+			}}
+		if err := st.Workouts().Record(ctx, &session, nil); err != nil {
+			return err
+		}
+		ids[day] = session.ID
+	}
+	if err := st.Workouts().Record(ctx, &models.WorkoutSession{UserID: otherID, PerformedAt: base.AddDate(0, 0, 2)}, nil); err != nil {
+		return err
+	}
+
+	since, err := st.Workouts().Since(ctx, userID, base.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	if len(since) != 3 || since[0].Notes != "day 1" || since[1].Notes != "day 3" || since[2].Notes != "day 3" || since[1].ID > since[2].ID {
+		return fmt.Errorf("Since returned %d sessions %+v, want days 1, 3, 3 oldest first with ties broken by ID", len(since), since)
+	}
+	for _, s := range since {
+		if s.UserID != userID || len(s.Sets) != 2 || s.Sets[0].SetNumber != 1 {
+			return fmt.Errorf("Since returned session %+v", s)
+		}
+	}
+
+	session, err := st.Workouts().ByID(ctx, ids[0])
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID || session.Notes != "day 0" || len(session.Sets) != 2 || session.Sets[1].SetNumber != 2 {
+		return fmt.Errorf("ByID returned %+v", session)
+	}
+	if _, err := st.Workouts().ByID(ctx, -1); !errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("ByID of an unknown session returned %v, want ErrNotFound", err)
+	}
+	return nil
+}
+
+func uploadsAddAndList(ctx context.Context, st store.Store, email func(string) string) error {
+	userID, err := newUser(ctx, st, email("uploader"))
+	if err != nil {
+		return err
+	}
+	otherID, err := newUser(ctx, st, email("bystander"))
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for _, name := range []string{"before.jpg", "after.jpg"} {
+		id, err := st.Uploads().Add(ctx, models.Upload{UserID: userID, Filename: "stored-" + name, OriginalName: name, SizeBytes: 2048})
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if _, err := st.Uploads().Add(ctx, models.Upload{UserID: otherID, Filename: "other.jpg"}); err != nil {
+		return err
+	}
+
+	uploads, err := st.Uploads().List(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if len(uploads) != 2 || uploads[0].ID != ids[0] || uploads[1].ID != ids[1] {
+		return fmt.Errorf("List returned %+v, want the user's two uploads oldest first", uploads)
+	}
+	u := uploads[0]
+	if u.UserID != userID || u.Filename != "stored-before.jpg" || u.OriginalName != "before.jpg" || u.SizeBytes != 2048 || u.CreatedAt.IsZero() {
+		return fmt.Errorf("List returned %+v, want the upload as added", u)
+	}
+	return nil
+}
+
+func auditRecordAndFilter(ctx context.Context, st store.Store, email func(string) string) error {
+	actorID, err := newUser(ctx, st, email("auditor"))
+	if err != nil {
+		return err
+	}
+	// Other checks write to the same log, so every listing is narrowed to this run's actor or target
+	target := fmt.Sprint(actorID)
+	at := time.Now().UTC().Truncate(time.Second)
+	events := []models.AuditEvent{
+		{ActorID: &actorID, AuthMethod: "token", Action: "login", TargetType: "user", TargetID: target, IP: "192.0.2.1", RequestID: "req-1", CreatedAt: at.Add(-time.Hour)},
+		{ActorID: &actorID, AuthMethod: "token", Action: "password_changed", TargetType: "user", TargetID: target, IP: "192.0.2.1", CreatedAt: at},
+		{Action: "login_failed", TargetType: "user", TargetID: target, Detail: "bad_password", IP: "198.51.100.7"},
+	}
+	for i := range events {
+		if err := st.Audit().Record(ctx, &events[i]); err != nil {
+			return err
+		}
+		if events[i].ID == 0 || events[i].CreatedAt.IsZero() {
+			return fmt.Errorf("Record left %+v without an ID or time", events[i])
+		}
+	}
+
+	got, err := st.Audit().List(ctx, store.AuditFilter{TargetType: "user", TargetID: target, Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(got) != 3 || got[0].ID != events[2].ID || got[2].ID != events[0].ID {
+		return fmt.Errorf("List by target returned %+v, want the three events newest first", got)
+	}
+	if got[0].ActorID != nil || got[0].Detail != "bad_password" || got[0].IP != "198.51.100.7" {
+		return fmt.Errorf("List returned %+v, want the anonymous failed login as recorded", got[0])
+	}
+	first := got[2]
+	if first.ActorID == nil || *first.ActorID != actorID || first.AuthMethod != "token" || first.Action != "login" || first.RequestID != "req-1" || !first.CreatedAt.Equal(at.Add(-time.Hour)) {
+		return fmt.Errorf("List returned %+v, want the login as recorded", first)
+	}
+
+	got, err = st.Audit().List(ctx, store.AuditFilter{ActorID: actorID, Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(got) != 2 {
+		return fmt.Errorf("List by actor returned %d events, want the 2 with an actor", len(got))
+	}
+	got, err = st.Audit().List(ctx, store.AuditFilter{ActorID: actorID, Action: "login", Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(got) != 1 || got[0].ID != events[0].ID {
+		return fmt.Errorf("List by actor and action returned %+v, want only the login", got)
+	}
+	got, err = st.Audit().List(ctx, store.AuditFilter{ActorID: actorID, Since: at.Add(-time.Minute), Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(got) != 1 || got[0].ID != events[1].ID {
+		return fmt.Errorf("List since %s returned %+v, want only the password change", at.Add(-time.Minute), got)
+	}
+	got, err = st.Audit().List(ctx, store.AuditFilter{ActorID: actorID, Until: at, Limit: 10})
+	if err != nil {
+		return err
+	}
+	if len(got) != 1 || got[0].ID != events[0].ID {
+		return fmt.Errorf("List until %s returned %+v, want only the earlier login", at, got)
+	}
+	return nil
+}
+
+func auditPagination(ctx context.Context, st store.Store, email func(string) string) error {
+	actorID, err := newUser(ctx, st, email("paged"))
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for i := 0; i < 5; i++ {
+		e := models.AuditEvent{ActorID: &actorID, Action: "upload", TargetType: "upload", TargetID: fmt.Sprint(i)}
+		if err := st.Audit().Record(ctx, &e); err != nil {
+			return err
+		}
+		ids = append(ids, e.ID)
+	}
+
+	var seen []int
+	filter := store.AuditFilter{ActorID: actorID, Limit: 2}
+	for page := 0; page < 4; page++ {
+		events, err := st.Audit().List(ctx, filter)
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			break
+		}
+		for _, e := range events {
+			seen = append(seen, e.ID)
+		}
+		filter.BeforeID = events[len(events)-1].ID
+	}
+	want := []int{ids[4], ids[3], ids[2], ids[1], ids[0]}
+	if fmt.Sprint(seen) != fmt.Sprint(want) {
+		return fmt.Errorf("paging by 2 returned IDs %v, want %v", seen, want)
+	}
+	return nil
+}
@@ -6,34 +6,157 @@ import (
 	"net/http"
 
 	"diet-fitness-backend/config"
+	"diet-fitness-backend/internal/audit"
 	"diet-fitness-backend/internal/auth"
+	"diet-fitness-backend/internal/backup"
 	"diet-fitness-backend/internal/handlers"
+	"diet-fitness-backend/internal/jobs"
+	"diet-fitness-backend/internal/mail"
 	"diet-fitness-backend/internal/middleware"
+	"diet-fitness-backend/internal/oidc"
+	"diet-fitness-backend/internal/password"
+	"diet-fitness-backend/internal/store"
 
 	"github.com/gorilla/mux"
 )
 
 // RegisterAPIRoutes sets up all the API endpoints
-func RegisterAPIRoutes(r *mux.Router, db *sql.DB, cfg *config.Config) {
+func RegisterAPIRoutes(r *mux.Router, db *sql.DB, st store.Store, cfg *config.Config, runner *jobs.Runner, mailer mail.Mailer, keys *auth.KeyManager, providers oidc.Providers, policy *password.Policy, backups *backup.Manager) {
+	// Public keys for verifying our JWTs
+	r.HandleFunc("/.well-known/jwks.json", handlers.JWKS(keys)).Methods("GET")
+
 	// Create a subrouter for API endpoints
 	api := r.PathPrefix("/api").Subrouter()
 
 	// Add CORS middleware to allow frontend to access
 	api.Use(middleware.CORSMiddleware)
 
+	// Security-relevant actions go to the append-only audit log
+	auditLog := audit.New(st.Audit(), cfg)
+
 	// Public routes (no authentication required)
-	api.HandleFunc("/register", handlers.RegisterUser(db)).Methods("POST")
-	api.HandleFunc("/login", handlers.LoginUser(db, cfg)).Methods("POST")
+	api.HandleFunc("/register", handlers.RegisterUser(db, st.Users(), cfg, mailer, policy)).Methods("POST")
+	api.HandleFunc("/login", handlers.LoginUser(db, st.Users(), cfg, keys, auditLog)).Methods("POST")
+	api.HandleFunc("/login/mfa", handlers.CompleteMFALogin(db, st.Users(), cfg, keys, auditLog)).Methods("POST")
+
+	// OpenID Connect sign-in; the callback redirects to the frontend with our token
+	api.HandleFunc("/auth/oidc/providers", handlers.ListOIDCProviders(providers)).Methods("GET")
+	api.HandleFunc("/auth/oidc/{provider}/login", handlers.StartOIDCLogin(db, cfg, providers)).Methods("GET")
+	api.HandleFunc("/auth/oidc/{provider}/callback", handlers.OIDCCallback(db, st.Users(), cfg, keys, providers, auditLog)).Methods("GET")
+	api.HandleFunc("/password/forgot", handlers.ForgotPassword(db, st.Users(), cfg, mailer)).Methods("POST")
+	api.HandleFunc("/password/reset", handlers.ResetPassword(db, st.Users(), policy, auditLog)).Methods("POST")
+	api.HandleFunc("/email/verify", handlers.VerifyEmail(db, st.Users())).Methods("POST")
 
 	// Protected routes (require JWT authentication)
 	// We'll create a subrouter specifically for protected routes
 	protected := api.PathPrefix("/").Subrouter()
-	protected.Use(auth.JWTMiddleware(cfg.JWTSecret)) // Apply JWT middleware to all routes in this subrouter
+	protected.Use(auth.APIKeyMiddleware(db, auth.JWTMiddleware(db, keys))) // Accept a JWT or a personal API key on all routes in this subrouter
+
+	protected.HandleFunc("/email/verify/resend", handlers.ResendVerification(db, st.Users(), cfg, mailer)).Methods("POST")
+	protected.HandleFunc("/dashboard", handlers.GetDashboardData(st.Workouts())).Methods("GET")
+	protected.HandleFunc("/upload", handlers.UploadImage(st.Uploads(), cfg.UploadDir, auditLog)).Methods("POST")
+	protected.HandleFunc("/generate-plan", handlers.GenerateFitnessPlan(st, cfg, auditLog)).Methods("POST")
+	protected.HandleFunc("/plan", handlers.GetFitnessPlan(st.Plans())).Methods("GET")
+	protected.HandleFunc("/plan/exercises", handlers.UpdatePlanExercises(st.Plans(), auditLog)).Methods("PUT")
+
+	// Workout log; logging a session runs the progression engine against the active plan
+	protected.HandleFunc("/workouts", handlers.LogWorkout(st)).Methods("POST")
+	protected.HandleFunc("/workouts", handlers.ListWorkouts(st.Workouts())).Methods("GET")
+	protected.HandleFunc("/analytics/training-load", handlers.GetTrainingLoad(st.Workouts())).Methods("GET")
+
+	// Body weight measurements
+	protected.HandleFunc("/weights", handlers.ListWeights(db)).Methods("GET")
+	protected.HandleFunc("/weights", handlers.AddWeight(db)).Methods("POST")
+
+	// Physiological profile used for heart-rate zones and calorie estimates
+	protected.HandleFunc("/profile", handlers.GetProfile(db)).Methods("GET")
+	protected.HandleFunc("/profile", handlers.UpdateProfile(db)).Methods("PUT")
+	protected.HandleFunc("/profile/heart-rate-zones", handlers.GetHeartRateZones(db)).Methods("GET")
+
+	// Cardio activities imported from GPX, TCX or FIT files, or entered manually
+	protected.HandleFunc("/activities/import", handlers.ImportActivity(db, auditLog)).Methods("POST")
+	protected.HandleFunc("/activities", handlers.CreateActivity(db)).Methods("POST")
+	protected.HandleFunc("/activities", handlers.ListActivities(db)).Methods("GET")
+	protected.HandleFunc("/activities/{id:[0-9]+}", handlers.GetActivity(db)).Methods("GET")
+
+	// Meal diary, food catalog and MyFitnessPal / generic CSV import and export
+	protected.HandleFunc("/diary", handlers.ListDiary(db)).Methods("GET")
+	protected.HandleFunc("/diary", handlers.AddDiaryEntry(db)).Methods("POST")
+	protected.HandleFunc("/diary/import", handlers.ImportDiary(db, auditLog)).Methods("POST")
+	protected.HandleFunc("/diary/export", handlers.ExportDiary(db, auditLog)).Methods("GET")
+	protected.HandleFunc("/foods", handlers.SearchFoods(db)).Methods("GET")
+
+	// Personal data export (background job) and account deletion
+	protected.HandleFunc("/account/export", handlers.ExportAccount(db, cfg, runner, auditLog)).Methods("POST")
+	protected.HandleFunc("/account/export/{id:[0-9]+}/download", handlers.DownloadAccountExport(db, cfg, auditLog)).Methods("GET")
+	protected.HandleFunc("/account", handlers.DeleteAccount(db, st.Users(), cfg, auditLog)).Methods("DELETE")
+
+	// Password change; signs out other devices
+	protected.HandleFunc("/password", handlers.ChangePassword(db, st.Users(), cfg, keys, policy, auditLog)).Methods("PUT")
+
+	// Signed-in devices
+	protected.HandleFunc("/sessions", handlers.ListSessions(db)).Methods("GET")
+	protected.HandleFunc("/sessions/{id:[0-9]+}", handlers.RevokeSession(db, auditLog)).Methods("DELETE")
+
+	// Personal API keys for scripts and integrations; managing them takes a login token
+	protected.HandleFunc("/api-keys/scopes", handlers.ListAPIKeyScopes()).Methods("GET")
+	protected.HandleFunc("/api-keys", handlers.ListAPIKeys(db)).Methods("GET")
+	protected.HandleFunc("/api-keys", handlers.CreateAPIKey(db, auditLog)).Methods("POST")
+	protected.HandleFunc("/api-keys/{id:[0-9]+}", handlers.RevokeAPIKey(db, auditLog)).Methods("DELETE")
+
+	// Sign-in providers linked to the account
+	protected.HandleFunc("/identities", handlers.ListIdentities(db)).Methods("GET")
+	protected.HandleFunc("/identities/{id:[0-9]+}", handlers.UnlinkIdentity(db, auditLog)).Methods("DELETE")
+	protected.HandleFunc("/auth/oidc/{provider}/link", handlers.StartOIDCLink(db, cfg, providers)).Methods("POST")
+
+	// TOTP two-factor authentication and recovery codes
+	protected.HandleFunc("/mfa", handlers.GetMFAStatus(db)).Methods("GET")
+	protected.HandleFunc("/mfa/totp/enroll", handlers.EnrollTOTP(db, auditLog)).Methods("POST")
+	protected.HandleFunc("/mfa/totp/qr.png", handlers.TOTPQRCode(db)).Methods("GET")
+	protected.HandleFunc("/mfa/totp/confirm", handlers.ConfirmTOTP(db, auditLog)).Methods("POST")
+	protected.HandleFunc("/mfa/totp/disable", handlers.DisableTOTP(db, st.Users(), cfg, auditLog)).Methods("POST")
+	protected.HandleFunc("/mfa/recovery-codes", handlers.RegenerateRecoveryCodes(db, st.Users(), cfg, auditLog)).Methods("POST")
+
+	// Apple Health / Google Fit export imports run as background jobs; poll /jobs/{id} for progress
+	protected.HandleFunc("/imports/health", handlers.ImportHealthData(db, cfg, runner, auditLog)).Methods("POST")
+	protected.HandleFunc("/jobs", handlers.ListJobs(db)).Methods("GET")
+	protected.HandleFunc("/jobs/{id:[0-9]+}", handlers.GetJob(db)).Methods("GET")
+
+	// Coaching, client side: invitations addressed to the user, what they share, and session comments
+	protected.HandleFunc("/coaching", handlers.ListCoaching(db)).Methods("GET")
+	protected.HandleFunc("/coaching/{id:[0-9]+}/accept", handlers.AcceptCoaching(db, st.Users())).Methods("POST")
+	protected.HandleFunc("/coaching/{id:[0-9]+}/decline", handlers.DeclineCoaching(db, st.Users())).Methods("POST")
+	protected.HandleFunc("/coaching/{id:[0-9]+}/scopes", handlers.UpdateCoachingScopes(db)).Methods("PUT")
+	protected.HandleFunc("/coaching/{id:[0-9]+}", handlers.EndCoaching(db)).Methods("DELETE")
+	protected.HandleFunc("/workouts/{id:[0-9]+}/comments", handlers.ListSessionComments(db, st.Workouts())).Methods("GET")
+	protected.HandleFunc("/workouts/{id:[0-9]+}/comments", handlers.AddSessionComment(db, st.Workouts())).Methods("POST")
+
+	// Coaching, coach side; {id} is the coaching relationship and each view needs the matching scope from the client
+	coach := protected.PathPrefix("/coach").Subrouter()
+	coach.Use(auth.RequirePermission(auth.PermClientsManage))
+	coach.HandleFunc("/invitations", handlers.InviteClient(db, cfg, mailer)).Methods("POST")
+	coach.HandleFunc("/invitations", handlers.ListCoachInvitations(db)).Methods("GET")
+	coach.HandleFunc("/clients", handlers.GetRoster(db, st)).Methods("GET")
+	coach.HandleFunc("/clients/{id:[0-9]+}", handlers.EndClientRelationship(db)).Methods("DELETE")
+	coach.HandleFunc("/clients/{id:[0-9]+}/workouts", handlers.ClientWorkouts(db, st.Workouts())).Methods("GET")
+	coach.HandleFunc("/clients/{id:[0-9]+}/training-load", handlers.ClientTrainingLoad(db, st.Workouts())).Methods("GET")
+	coach.HandleFunc("/clients/{id:[0-9]+}/diary", handlers.ClientDiary(db)).Methods("GET")
+	coach.HandleFunc("/clients/{id:[0-9]+}/activities", handlers.ClientActivities(db)).Methods("GET")
+	coach.HandleFunc("/clients/{id:[0-9]+}/plan", handlers.GetClientPlan(db, st.Plans())).Methods("GET")
+	coach.Handle("/clients/{id:[0-9]+}/plan", auth.RequirePermission(auth.PermPlansAssign)(handlers.AssignClientPlan(db, st.Plans(), auditLog))).Methods("PUT")
 
-	protected.HandleFunc("/dashboard", handlers.GetDashboardData).Methods("GET")
-	protected.HandleFunc("/upload", handlers.UploadImage(cfg.UploadDir)).Methods("POST")
-	protected.HandleFunc("/generate-plan", handlers.GenerateFitnessPlan).Methods("POST")
-	protected.HandleFunc("/plan", handlers.GetFitnessPlan).Methods("GET")
+	// Administration; RequireRole/RequirePermission run after the JWT middleware inherited from protected
+	admin := protected.PathPrefix("/admin").Subrouter()
+	admin.Use(auth.RequireRole(auth.RoleAdmin))
+	admin.Handle("/roles", auth.RequirePermission(auth.PermRolesAssign)(handlers.ListRoles(db))).Methods("GET")
+	admin.Handle("/users", auth.RequirePermission(auth.PermUsersRead)(handlers.ListUsers(db, st.Users()))).Methods("GET")
+	admin.Handle("/users/{id:[0-9]+}", auth.RequirePermission(auth.PermUsersRead)(handlers.GetUser(db, st.Users()))).Methods("GET")
+	admin.Handle("/users/{id:[0-9]+}/roles/{role}", auth.RequirePermission(auth.PermRolesAssign)(handlers.AssignRole(db, st.Users(), auditLog))).Methods("PUT")
+	admin.Handle("/users/{id:[0-9]+}/roles/{role}", auth.RequirePermission(auth.PermRolesAssign)(handlers.RemoveRole(db, st.Users(), auditLog))).Methods("DELETE")
+	admin.Handle("/keys/rotate", auth.RequirePermission(auth.PermKeysRotate)(handlers.RotateSigningKey(keys, auditLog))).Methods("POST")
+	admin.Handle("/backups", auth.RequirePermission(auth.PermBackupsManage)(handlers.ListBackups(backups))).Methods("GET")
+	admin.Handle("/backups", auth.RequirePermission(auth.PermBackupsManage)(handlers.CreateBackup(backups, auditLog))).Methods("POST")
+	admin.Handle("/audit", auth.RequirePermission(auth.PermAuditRead)(handlers.ListAuditLog(st.Audit()))).Methods("GET")
 
 	// Serve static files for uploads (for demonstration/testing)
 	// In a real production setup, you might serve these via a CDN or a dedicated static file server.